@@ -1,9 +1,8 @@
 module github.com/m4tthewde/swell
 
-go 1.22
+go 1.24
 
 require (
-	github.com/google/uuid v1.6.0
 	github.com/stretchr/testify v1.10.0
 	go.uber.org/zap v1.27.0
 )
@@ -51,6 +51,8 @@ func NewAttribute(reader *bufio.Reader, cp *ConstantPool) (Attribute, error) {
 		return NewCodeAttribute(reader, cp)
 	case "LineNumberTable":
 		return NewLineNumberTableAttribute(reader)
+	case "LocalVariableTable":
+		return NewLocalVariableTableAttribute(reader)
 	case "SourceFile":
 		return NewSourceFileAttribute(reader)
 	case "ConstantValue":
@@ -110,6 +112,39 @@ type CodeAttribute struct {
 	Attributes []Attribute `json:"attributes"`
 }
 
+// LineNumber returns the source line of the instruction at pc from the LineNumberTable.
+func (c CodeAttribute) LineNumber(pc int) (int, bool) {
+	line, found, start := 0, false, -1
+	for _, attribute := range c.Attributes {
+		table, ok := attribute.(LineNumberTableAttribute)
+		if !ok {
+			continue
+		}
+
+		for _, entry := range table.Table {
+			if int(entry.StartPc) <= pc && int(entry.StartPc) > start {
+				line, found, start = int(entry.LineNumber), true, int(entry.StartPc)
+			}
+		}
+	}
+
+	return line, found
+}
+
+// LocalVariables returns the entries of all LocalVariableTable attributes, ok is false if there are none.
+func (c CodeAttribute) LocalVariables() ([]LocalVariableTableEntry, bool) {
+	var entries []LocalVariableTableEntry
+	found := false
+	for _, attribute := range c.Attributes {
+		if table, ok := attribute.(LocalVariableTableAttribute); ok {
+			entries = append(entries, table.Table...)
+			found = true
+		}
+	}
+
+	return entries, found
+}
+
 func (c CodeAttribute) Name() string {
 	return "Code"
 }
@@ -211,6 +246,52 @@ func NewLineNumberTableAttribute(reader *bufio.Reader) (Attribute, error) {
 	return LineNumberTableAttribute{Table: table}, nil
 }
 
+type LocalVariableTableAttribute struct {
+	Table []LocalVariableTableEntry `json:"table"`
+}
+
+func (l LocalVariableTableAttribute) Name() string {
+	return "LocalVariableTable"
+}
+
+// LocalVariableTableEntry names the local variable at Index while the pc is in [StartPc, StartPc+Length).
+type LocalVariableTableEntry struct {
+	StartPc         uint16 `json:"start_pc"`
+	Length          uint16 `json:"length"`
+	NameIndex       uint16 `json:"name_index"`
+	DescriptorIndex uint16 `json:"descriptor_index"`
+	Index           uint16 `json:"index"`
+}
+
+func NewLocalVariableTableAttribute(reader *bufio.Reader) (Attribute, error) {
+	length, err := readUint16(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	table := make([]LocalVariableTableEntry, length)
+
+	for i := range length {
+		values := make([]uint16, 5)
+		for j := range values {
+			values[j], err = readUint16(reader)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		table[i] = LocalVariableTableEntry{
+			StartPc:         values[0],
+			Length:          values[1],
+			NameIndex:       values[2],
+			DescriptorIndex: values[3],
+			Index:           values[4],
+		}
+	}
+
+	return LocalVariableTableAttribute{Table: table}, nil
+}
+
 type SourceFileAttribute struct {
 	SourceFileIndex uint16 `json:"source_file_index"`
 }
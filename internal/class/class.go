@@ -6,12 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 )
 
 var MAGIC = []byte{0xCA, 0xFE, 0xBA, 0xBE}
 
 type Class struct {
 	Name         string       `json:"name"`
+	AccessFlags  uint16       `json:"access_flags"`
+	SuperClass   uint16       `json:"super_class"`
 	ConstantPool ConstantPool `json:"constant_pool"`
 	Methods      []Method     `json:"methods"`
 	Interfaces   []uint16     `json:"interfaces"`
@@ -69,16 +72,19 @@ func (c *Class) GetMethodByName(methodName string) (*Method, bool, error) {
 	return nil, false, nil
 }
 
-// TODO: name is not enough to find the correct field
-// will have to use descriptor in the future
-func (c *Class) GetField(fieldName string) (*Field, bool, error) {
+func (c *Class) GetField(fieldName string, descriptor string) (*Field, bool, error) {
 	for _, f := range c.Fields {
 		name, err := c.ConstantPool.GetUtf8(f.NameIndex)
 		if err != nil {
 			return nil, false, err
 		}
 
-		if name == fieldName {
+		fieldDescriptor, err := c.ConstantPool.GetUtf8(f.DescriptorIndex)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if name == fieldName && fieldDescriptor == descriptor {
 			return &f, true, nil
 		}
 	}
@@ -86,6 +92,99 @@ func (c *Class) GetField(fieldName string) (*Field, bool, error) {
 	return nil, false, nil
 }
 
+// SuperClassName returns the name of the direct superclass.
+// Only java/lang/Object has none.
+func (c *Class) SuperClassName() (string, bool, error) {
+	if c.SuperClass == 0 {
+		return "", false, nil
+	}
+
+	info, err := c.ConstantPool.Class(c.SuperClass)
+	if err != nil {
+		return "", false, err
+	}
+
+	name, err := c.ConstantPool.GetUtf8(info.NameIndex)
+	if err != nil {
+		return "", false, err
+	}
+
+	return name, true, nil
+}
+
+func (c *Class) IsInterface() bool {
+	return (c.AccessFlags & AccInterface) != 0
+}
+
+func (c *Class) IsArray() bool {
+	return strings.HasPrefix(c.Name, "[")
+}
+
+// NewArrayClass creates the class of an array type like [I or [Ljava/lang/String;, which has no class file.
+// Every array class extends Object and implements Cloneable and Serializable (JVMS §4.10.1.2).
+func NewArrayClass(name string, accessFlags uint16) *Class {
+	pool := ConstantPool{Infos: []CpInfo{
+		ReservedInfo{},
+		Utf8Info{Content: "java/lang/Object"},
+		ClassInfo{NameIndex: 1},
+		Utf8Info{Content: "java/lang/Cloneable"},
+		ClassInfo{NameIndex: 3},
+		Utf8Info{Content: "java/io/Serializable"},
+		ClassInfo{NameIndex: 5},
+	}}
+
+	return &Class{
+		Name:         name,
+		AccessFlags:  accessFlags,
+		SuperClass:   2,
+		ConstantPool: pool,
+		Interfaces:   []uint16{4, 6},
+	}
+}
+
+// DeclaresDefaultMethods reports whether the class declares a non-abstract instance method,
+// which makes an interface part of the initialization of its implementing classes.
+func (c *Class) DeclaresDefaultMethods() bool {
+	for _, method := range c.Methods {
+		if !method.IsAbstract() && !method.IsStatic() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SourceFile returns the name of the source file from the SourceFile attribute.
+func (c *Class) SourceFile() (string, bool) {
+	for _, attribute := range c.Attributes {
+		if sourceFile, ok := attribute.(SourceFileAttribute); ok {
+			name, err := c.ConstantPool.GetUtf8(sourceFile.SourceFileIndex)
+			return name, err == nil
+		}
+	}
+
+	return "", false
+}
+
+func (c *Class) InterfaceNames() ([]string, error) {
+	names := make([]string, len(c.Interfaces))
+	for i, index := range c.Interfaces {
+		info, err := c.ConstantPool.Class(index)
+		if err != nil {
+			return nil, err
+		}
+
+		name, err := c.ConstantPool.GetUtf8(info.NameIndex)
+		if err != nil {
+			return nil, err
+		}
+
+		names[i] = name
+	}
+
+	return names, nil
+}
+
 func NewClass(reader *bufio.Reader, name string) (*Class, error) {
 	magic := make([]byte, 4)
 	_, err := io.ReadFull(reader, magic)
@@ -113,8 +212,18 @@ func NewClass(reader *bufio.Reader, name string) (*Class, error) {
 		return nil, fmt.Errorf("constant pool in %s: %v", name, err)
 	}
 
-	// skip to interfaces
-	_, err = reader.Discard(6)
+	accessFlags, err := readUint16(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// skip this_class, the name is already known
+	_, err = reader.Discard(2)
+	if err != nil {
+		return nil, err
+	}
+
+	superClass, err := readUint16(reader)
 	if err != nil {
 		return nil, err
 	}
@@ -165,6 +274,8 @@ func NewClass(reader *bufio.Reader, name string) (*Class, error) {
 
 	return &Class{
 		Name:         name,
+		AccessFlags:  accessFlags,
+		SuperClass:   superClass,
 		ConstantPool: *constantPool,
 		Methods:      methods,
 		Fields:       fields,
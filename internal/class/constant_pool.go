@@ -22,11 +22,13 @@ func NewConstantPool(reader *bufio.Reader, count int) (*ConstantPool, error) {
 			return nil, err
 		}
 
-		if _, ok := cpInfo.(LongInfo); ok {
+		infos[i] = cpInfo
+
+		// longs and doubles take up two entries
+		switch cpInfo.(type) {
+		case LongInfo, DoubleInfo:
 			i += 1
 		}
-
-		infos[i] = cpInfo
 	}
 
 	return &ConstantPool{Infos: infos}, nil
@@ -76,6 +78,7 @@ const Utf8Tag = 1
 const IntegerTag = 3
 const FloatTag = 4
 const LongTag = 5
+const DoubleTag = 6
 const ClassTag = 7
 const StringTag = 8
 const FieldrefTag = 9
@@ -111,6 +114,8 @@ func NewCpInfo(reader *bufio.Reader) (CpInfo, error) {
 		return NewFloatInfo(reader)
 	case LongTag:
 		return NewLongInfo(reader)
+	case DoubleTag:
+		return NewDoubleInfo(reader)
 	case ClassTag:
 		return NewClassInfo(reader)
 	case StringTag:
@@ -217,7 +222,17 @@ func NewUtf8Info(reader *bufio.Reader) (CpInfo, error) {
 		return nil, err
 	}
 
-	return Utf8Info{Content: string(bytes)}, nil
+	content, err := DecodeModifiedUTF8(bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modified UTF-8: %v", err)
+	}
+
+	return Utf8Info{Content: content}, nil
+}
+
+// Bytes returns the content in modified UTF-8, as stored in a class file.
+func (c Utf8Info) Bytes() []byte {
+	return EncodeModifiedUTF8(c.Content)
 }
 
 type StringInfo struct {
@@ -280,6 +295,23 @@ func NewLongInfo(reader *bufio.Reader) (CpInfo, error) {
 	return LongInfo{Value: value}, nil
 }
 
+type DoubleInfo struct {
+	Value float64 `json:"value"`
+}
+
+func (c DoubleInfo) String() string {
+	return fmt.Sprintf("DoubleInfo[%f]", c.Value)
+}
+
+func NewDoubleInfo(reader *bufio.Reader) (CpInfo, error) {
+	value, err := readUint64(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return DoubleInfo{Value: math.Float64frombits(value)}, nil
+}
+
 type IntegerInfo struct {
 	Value uint32 `json:"value"`
 }
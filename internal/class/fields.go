@@ -56,3 +56,21 @@ func NewField(reader *bufio.Reader, cp *ConstantPool) (*Field, error) {
 			Attributes:      attributes},
 		nil
 }
+
+func (f Field) IsStatic() bool {
+	return (f.AccessFlags & AccStatic) != 0
+}
+
+func (f Field) IsFinal() bool {
+	return (f.AccessFlags & AccFinal) != 0
+}
+
+func (f Field) ConstantValueAttribute() (*ConstantValueAttribute, bool) {
+	for _, attribute := range f.Attributes {
+		if constantValue, ok := attribute.(ConstantValueAttribute); ok {
+			return &constantValue, true
+		}
+	}
+
+	return nil, false
+}
@@ -10,8 +10,13 @@ const MainDescriptor = "([Ljava/lang/String;)V"
 
 const AccPublic = 0x0001
 const AccStatic = 0x0008
+const AccFinal = 0x0010
+const AccSuper = 0x0020
+const AccSynchronized = 0x0020
 const AccVarargs = 0x0080
 const AccNative = 0x0100
+const AccInterface = 0x0200
+const AccAbstract = 0x0400
 
 type Method struct {
 	AccessFlags     uint16      `json:"access_flags"`
@@ -30,7 +35,7 @@ func (m Method) IsMain(cp *ConstantPool) (bool, error) {
 		return false, nil
 	}
 
-	if !m.isPublic() || !m.isStatic() {
+	if !m.isPublic() || !m.IsStatic() {
 		return false, nil
 	}
 
@@ -46,7 +51,7 @@ func (m Method) isPublic() bool {
 	return (m.AccessFlags & AccPublic) != 0
 }
 
-func (m Method) isStatic() bool {
+func (m Method) IsStatic() bool {
 	return (m.AccessFlags & AccStatic) != 0
 }
 
@@ -54,6 +59,14 @@ func (m Method) IsNative() bool {
 	return (m.AccessFlags & AccNative) != 0
 }
 
+func (m Method) IsSynchronized() bool {
+	return (m.AccessFlags & AccSynchronized) != 0
+}
+
+func (m Method) IsAbstract() bool {
+	return (m.AccessFlags & AccAbstract) != 0
+}
+
 func (m Method) IsVarargs() bool {
 	return (m.AccessFlags & AccVarargs) != 0
 }
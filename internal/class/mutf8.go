@@ -0,0 +1,119 @@
+package class
+
+import (
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Class files encode strings in modified UTF-8 (JVMS §4.4.7): NUL takes two bytes,
+// so the encoding never contains a zero byte, and characters outside the BMP are
+// stored as two encoded UTF-16 surrogates instead of a four byte sequence.
+
+// DecodeModifiedUTF8ToUTF16 decodes modified UTF-8 into UTF-16 code units.
+func DecodeModifiedUTF8ToUTF16(b []byte) ([]uint16, error) {
+	units := make([]uint16, 0, len(b))
+
+	for i := 0; i < len(b); {
+		switch {
+		case b[i] == 0:
+			return nil, fmt.Errorf("invalid zero byte at %d", i)
+		case b[i] < 0x80:
+			units = append(units, uint16(b[i]))
+			i += 1
+		case b[i]&0xe0 == 0xc0:
+			if i+1 >= len(b) || b[i+1]&0xc0 != 0x80 {
+				return nil, fmt.Errorf("truncated two byte sequence at %d", i)
+			}
+
+			units = append(units, uint16(b[i]&0x1f)<<6|uint16(b[i+1]&0x3f))
+			i += 2
+		case b[i]&0xf0 == 0xe0:
+			if i+2 >= len(b) || b[i+1]&0xc0 != 0x80 || b[i+2]&0xc0 != 0x80 {
+				return nil, fmt.Errorf("truncated three byte sequence at %d", i)
+			}
+
+			units = append(units, uint16(b[i]&0x0f)<<12|uint16(b[i+1]&0x3f)<<6|uint16(b[i+2]&0x3f))
+			i += 3
+		default:
+			return nil, fmt.Errorf("invalid byte %#x at %d", b[i], i)
+		}
+	}
+
+	return units, nil
+}
+
+// DecodeModifiedUTF8 decodes modified UTF-8 into a Go string.
+// Unpaired surrogates are kept as in DecodeUTF16, so the content encodes back to the same bytes.
+func DecodeModifiedUTF8(b []byte) (string, error) {
+	units, err := DecodeModifiedUTF8ToUTF16(b)
+	if err != nil {
+		return "", err
+	}
+
+	return DecodeUTF16(units), nil
+}
+
+// DecodeUTF16 converts UTF-16 code units into a Go string. Unlike utf16.Decode it keeps
+// unpaired surrogates, as their three byte encoding (WTF-8), instead of replacing them with U+FFFD.
+func DecodeUTF16(units []uint16) string {
+	b := make([]byte, 0, len(units))
+
+	for i := 0; i < len(units); i++ {
+		unit := units[i]
+		switch {
+		case utf16.IsSurrogate(rune(unit)) && i+1 < len(units) && utf16.DecodeRune(rune(unit), rune(units[i+1])) != utf8.RuneError:
+			b = utf8.AppendRune(b, utf16.DecodeRune(rune(unit), rune(units[i+1])))
+			i++
+		case utf16.IsSurrogate(rune(unit)):
+			b = append(b, 0xe0|byte(unit>>12), 0x80|byte(unit>>6&0x3f), 0x80|byte(unit&0x3f))
+		default:
+			b = utf8.AppendRune(b, rune(unit))
+		}
+	}
+
+	return string(b)
+}
+
+// EncodeUTF16 converts a Go string into UTF-16 code units, the inverse of DecodeUTF16.
+// Invalid UTF-8 other than encoded surrogates becomes U+FFFD.
+func EncodeUTF16(s string) []uint16 {
+	units := make([]uint16, 0, len(s))
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 && i+2 < len(s) && s[i] == 0xed && s[i+1]&0xe0 == 0xa0 && s[i+2]&0xc0 == 0x80 {
+			units = append(units, 0xd000|uint16(s[i+1]&0x3f)<<6|uint16(s[i+2]&0x3f))
+			i += 3
+			continue
+		}
+
+		units = utf16.AppendRune(units, r)
+		i += size
+	}
+
+	return units
+}
+
+// EncodeModifiedUTF8FromUTF16 encodes UTF-16 code units in modified UTF-8.
+func EncodeModifiedUTF8FromUTF16(units []uint16) []byte {
+	b := make([]byte, 0, len(units))
+
+	for _, unit := range units {
+		switch {
+		case unit != 0 && unit < 0x80:
+			b = append(b, byte(unit))
+		case unit < 0x800:
+			b = append(b, 0xc0|byte(unit>>6), 0x80|byte(unit&0x3f))
+		default:
+			b = append(b, 0xe0|byte(unit>>12), 0x80|byte(unit>>6&0x3f), 0x80|byte(unit&0x3f))
+		}
+	}
+
+	return b
+}
+
+// EncodeModifiedUTF8 encodes a Go string in modified UTF-8, as written to class files.
+func EncodeModifiedUTF8(s string) []byte {
+	return EncodeModifiedUTF8FromUTF16(EncodeUTF16(s))
+}
@@ -0,0 +1,90 @@
+package class
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModifiedUTF8(t *testing.T) {
+	tests := []struct {
+		content string
+		encoded []byte
+	}{
+		{"Hello", []byte("Hello")},
+		{"a\x00b", []byte{'a', 0xc0, 0x80, 'b'}},
+		{"ü€", []byte{0xc3, 0xbc, 0xe2, 0x82, 0xac}},
+		// U+1F600 is stored as the surrogates D83D and DE00
+		{"😀!", []byte{0xed, 0xa0, 0xbd, 0xed, 0xb8, 0x80, '!'}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.encoded, EncodeModifiedUTF8(test.content))
+
+		content, err := DecodeModifiedUTF8(test.encoded)
+		assert.Nil(t, err)
+		assert.Equal(t, test.content, content)
+	}
+}
+
+func TestModifiedUTF8Units(t *testing.T) {
+	// an unpaired surrogate can't be a Go string, but survives as UTF-16
+	encoded := []byte{0xed, 0xa0, 0xbd, 'x'}
+
+	units, err := DecodeModifiedUTF8ToUTF16(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint16{0xd83d, 'x'}, units)
+	assert.Equal(t, encoded, EncodeModifiedUTF8FromUTF16(units))
+
+	content, err := DecodeModifiedUTF8(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, "\xed\xa0\xbdx", content)
+	assert.Equal(t, units, EncodeUTF16(content))
+}
+
+func TestModifiedUTF8Invalid(t *testing.T) {
+	for _, encoded := range [][]byte{
+		{0x00},
+		{0xc0},
+		{0xe2, 0x82},
+		// four byte sequences of standard UTF-8 are not allowed
+		{0xf0, 0x9f, 0x98, 0x80},
+	} {
+		_, err := DecodeModifiedUTF8(encoded)
+		assert.NotNil(t, err, "%x", encoded)
+	}
+}
+
+func TestUtf8InfoLiteral(t *testing.T) {
+	literal := "nul\x00 and 🙂"
+	encoded := EncodeModifiedUTF8(literal)
+
+	data := append([]byte{Utf8Tag, byte(len(encoded) >> 8), byte(len(encoded))}, encoded...)
+	info, err := NewCpInfo(bufio.NewReader(bytes.NewReader(data)))
+	assert.Nil(t, err)
+	assert.Equal(t, Utf8Info{Content: literal}, info)
+	assert.Equal(t, encoded, info.(Utf8Info).Bytes())
+	assert.NotContains(t, encoded, byte(0))
+}
+
+func TestUtf8InfoLoneSurrogate(t *testing.T) {
+	contents := map[string]bool{}
+
+	for _, encoded := range [][]byte{
+		{0xed, 0xa0, 0x80},
+		{0xed, 0xa0, 0x81},
+		{'a', 0xed, 0xbf, 0xbf},
+	} {
+		data := append([]byte{Utf8Tag, 0, byte(len(encoded))}, encoded...)
+		info, err := NewCpInfo(bufio.NewReader(bytes.NewReader(data)))
+		assert.Nil(t, err)
+		assert.Equal(t, encoded, info.(Utf8Info).Bytes())
+
+		contents[info.(Utf8Info).Content] = true
+	}
+
+	// lone surrogates don't collapse into the same content
+	assert.Len(t, contents, 3)
+}
@@ -0,0 +1,80 @@
+package jvm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+)
+
+// arrayElement returns the array and checked index an array load or store instruction works on.
+func (r *Runner) arrayElement(ctx context.Context, arrayRef stack.Value, index stack.Value) (*Array, int, error) {
+	ref := referenceOf(arrayRef)
+	if ref == stack.Null {
+		return nil, 0, r.newThrowable(ctx, "java/lang/NullPointerException", "", nil)
+	}
+
+	array, err := r.heap.GetArray(ref)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	i, ok := index.(stack.IntValue)
+	if !ok {
+		return nil, 0, fmt.Errorf("index has to be int, is %s", index)
+	}
+
+	if i.Value < 0 || int(i.Value) >= len(array.items) {
+		message := fmt.Sprintf("Index %d out of bounds for length %d", i.Value, len(array.items))
+		return nil, 0, r.newThrowable(ctx, "java/lang/ArrayIndexOutOfBoundsException", message, nil)
+	}
+
+	return array, int(i.Value), nil
+}
+
+func aaload(ctx context.Context, r *Runner) error {
+	r.pc += 1
+	operands, err := r.stack.PopOperands(2)
+	if err != nil {
+		return err
+	}
+
+	array, index, err := r.arrayElement(ctx, operands[0], operands[1])
+	if err != nil {
+		return err
+	}
+
+	return r.stack.PushOperand(ctx, array.items[index])
+}
+
+// aastore checks the runtime type of the value against the component type of the array, since arrays are
+// covariant: a String[] can be passed as Object[] but must not receive an Integer.
+func aastore(ctx context.Context, r *Runner) error {
+	r.pc += 1
+	operands, err := r.stack.PopOperands(3)
+	if err != nil {
+		return err
+	}
+
+	array, index, err := r.arrayElement(ctx, operands[0], operands[1])
+	if err != nil {
+		return err
+	}
+
+	ok, err := r.isArrayElement(ctx, operands[2], array)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		className, err := r.runtimeClassName(referenceOf(operands[2]))
+		if err != nil {
+			return err
+		}
+
+		return r.newThrowable(ctx, "java/lang/ArrayStoreException", dotted(className), nil)
+	}
+
+	array.items[index] = operands[2]
+	return nil
+}
@@ -19,8 +19,6 @@ func aload(ctx context.Context, r *Runner, n int) error {
 		return r.stack.PushOperand(ctx, val)
 	case stack.ClassReferenceValue:
 		return r.stack.PushOperand(ctx, val)
-	case Array:
-		return r.stack.PushOperand(ctx, val)
 	default:
 		return fmt.Errorf("invalid variable type: %s", val)
 	}
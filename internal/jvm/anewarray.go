@@ -42,17 +42,12 @@ func anewarray(r *Runner, ctx context.Context, code []byte) error {
 			return err
 		}
 
-		_, err = r.loader.Load(ctx, className)
+		ref, err := r.allocateDefaultArray(ctx, arrayClassOf(className), int(count.Value))
 		if err != nil {
 			return err
 		}
 
-		id, err := r.heap.AllocateDefaultArray(ctx, int(count.Value), stack.ReferenceValue{Value: nil})
-		if err != nil {
-			return err
-		}
-
-		return r.stack.PushOperand(ctx, stack.ReferenceValue{Value: id})
+		return r.stack.PushOperand(ctx, stack.ReferenceValue{Value: ref})
 	default:
 		return fmt.Errorf("anewarray not implemented for %s", cpInfo)
 	}
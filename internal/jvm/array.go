@@ -0,0 +1,70 @@
+package jvm
+
+import (
+	"context"
+	"strings"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+)
+
+// allocateArray allocates an array of the array class className, loading the class if necessary.
+func (r *Runner) allocateArray(ctx context.Context, className string, items []stack.Value) (stack.Reference, error) {
+	_, err := r.loader.Load(ctx, className)
+	if err != nil {
+		return stack.Null, err
+	}
+
+	return r.heap.AllocateArray(ctx, className, items)
+}
+
+// allocateDefaultArray allocates an array of the array class className holding the default value of its component type.
+func (r *Runner) allocateDefaultArray(ctx context.Context, className string, length int) (stack.Reference, error) {
+	_, err := r.loader.Load(ctx, className)
+	if err != nil {
+		return stack.Null, err
+	}
+
+	componentType, err := class.NewFieldType(className[1:])
+	if err != nil {
+		return stack.Null, err
+	}
+
+	defaultValue, err := stack.DefaultValue(componentType)
+	if err != nil {
+		return stack.Null, err
+	}
+
+	return r.heap.AllocateDefaultArray(ctx, className, length, defaultValue)
+}
+
+// arrayClassOf returns the name of the array class with the given component type, e.g. [I for int
+// and [Ljava/lang/String; for java/lang/String.
+func arrayClassOf(componentName string) string {
+	for descriptor, primitive := range primitiveDescriptors {
+		if primitive == componentName {
+			return "[" + descriptor
+		}
+	}
+
+	if strings.HasPrefix(componentName, "[") {
+		return "[" + componentName
+	}
+
+	return "[L" + componentName + ";"
+}
+
+// isArrayElement reports whether value can be stored in the reference array, which is what aastore checks.
+func (r *Runner) isArrayElement(ctx context.Context, value stack.Value, array *Array) (bool, error) {
+	ref := referenceOf(value)
+	if ref == stack.Null {
+		return true, nil
+	}
+
+	className, err := r.runtimeClassName(ref)
+	if err != nil {
+		return false, err
+	}
+
+	return r.isAssignable(ctx, className, componentName(array.ClassName()))
+}
@@ -0,0 +1,152 @@
+package jvm
+
+import (
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func arrayClasses() []*class.Class {
+	classes := throwableClasses()
+	for _, names := range [][2]string{
+		{"java/lang/ArrayStoreException", "java/lang/RuntimeException"},
+		{"java/lang/ClassCastException", "java/lang/RuntimeException"},
+	} {
+		c := newTestClass(names[0], names[1]).
+			method(class.AccPublic, "<init>", "()V", RetOp)
+		classes = append(classes, c.build())
+	}
+
+	shape := newTestClass("Shape", "java/lang/Object").flags(class.AccPublic | class.AccInterface | class.AccAbstract)
+	square := newTestClass("Square", "java/lang/Object").flags(class.AccSuper).interfaces("Shape")
+
+	main := newTestClass("Main", "java/lang/Object")
+	objects := main.classRef("[Ljava/lang/Object;")
+	shapes := main.classRef("[LShape;")
+	main.method(class.AccStatic, "store", "(Ljava/lang/Object;Ljava/lang/Object;)V",
+		append(append([]byte{Aload0, CheckCast}, u2(objects)...), IConst0, Aload1, AAStore, RetOp)...)
+	main.method(class.AccStatic, "isShapes", "(Ljava/lang/Object;)I",
+		append(append([]byte{Aload0, InstanceOf}, u2(shapes)...), IReturn)...)
+	main.method(class.AccStatic, "toShapes", "(Ljava/lang/Object;)V",
+		append(append([]byte{Aload0, CheckCast}, u2(shapes)...), RetOp)...)
+
+	return append(classes, shape.build(), square.build(), main.build())
+}
+
+func TestArrayClass(t *testing.T) {
+	r, ctx := newTestRunner(t, arrayClasses()...)
+
+	c, err := r.loader.Load(ctx, "[[LSquare;")
+	assert.Nil(t, err)
+	assert.True(t, c.IsArray())
+
+	superName, _, err := c.SuperClassName()
+	assert.Nil(t, err)
+	assert.Equal(t, "java/lang/Object", superName)
+
+	interfaces, err := c.InterfaceNames()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"java/lang/Cloneable", "java/io/Serializable"}, interfaces)
+
+	// Square is package private, and so are its array classes
+	assert.Equal(t, uint16(class.AccFinal|class.AccAbstract), c.AccessFlags)
+
+	_, err = r.loader.Load(ctx, "[Q")
+	assert.NotNil(t, err)
+
+	ref, err := r.allocateDefaultArray(ctx, "[I", 0)
+	assert.Nil(t, err)
+	name, err := r.runtimeClassName(ref)
+	assert.Nil(t, err)
+	assert.Equal(t, "[I", name)
+}
+
+func TestArrayCovariance(t *testing.T) {
+	r, ctx := newTestRunner(t, arrayClasses()...)
+
+	squares, err := r.allocateDefaultArray(ctx, "[LSquare;", 1)
+	assert.Nil(t, err)
+	layout, err := r.loader.Layout(ctx, "Square")
+	assert.Nil(t, err)
+	square, err := r.heap.AllocateObject(ctx, layout)
+	assert.Nil(t, err)
+	str, err := r.newString(ctx, "not a square")
+	assert.Nil(t, err)
+
+	_, err = invokeTestMethod(t, ctx, r, "Main", "store", "(Ljava/lang/Object;Ljava/lang/Object;)V",
+		stack.ReferenceValue{Value: squares}, stack.ReferenceValue{Value: square})
+	assert.Nil(t, err)
+
+	array, err := r.heap.GetArray(squares)
+	assert.Nil(t, err)
+	assert.Equal(t, stack.ReferenceValue{Value: square}, array.items[0])
+
+	_, err = invokeTestMethod(t, ctx, r, "Main", "store", "(Ljava/lang/Object;Ljava/lang/Object;)V",
+		stack.ReferenceValue{Value: squares}, stack.ReferenceValue{Value: str})
+	assert.Equal(t, "java.lang.ArrayStoreException: java.lang.String\n\tMain.store()", err.Error())
+
+	for _, test := range []struct {
+		value    stack.Reference
+		expected int32
+	}{
+		{squares, 1},
+		{square, 0},
+		{stack.Null, 0},
+	} {
+		value, err := invokeTestMethod(t, ctx, r, "Main", "isShapes", "(Ljava/lang/Object;)I", stack.ReferenceValue{Value: test.value})
+		assert.Nil(t, err)
+		assert.Equal(t, stack.IntValue{Value: test.expected}, value)
+	}
+
+	_, err = invokeTestMethod(t, ctx, r, "Main", "toShapes", "(Ljava/lang/Object;)V", stack.ReferenceValue{Value: square})
+	assert.Equal(t, "java.lang.ClassCastException: class Square cannot be cast to class [LShape;\n\tMain.toShapes()", err.Error())
+}
+
+func TestClone(t *testing.T) {
+	cloneable := newTestClass("java/lang/Cloneable", "java/lang/Object").flags(class.AccPublic | class.AccInterface | class.AccAbstract)
+	sheep := newTestClass("Sheep", "java/lang/Object").interfaces("java/lang/Cloneable").field(0, "name", "Ljava/lang/String;")
+	exception := newTestClass("java/lang/CloneNotSupportedException", "java/lang/Exception").
+		method(class.AccPublic, "<init>", "()V", RetOp)
+
+	main := newTestClass("Main", "java/lang/Object")
+	clone := main.ref("[I", "clone", "()Ljava/lang/Object;")
+	main.method(class.AccStatic, "copy", "([I)Ljava/lang/Object;", append(append([]byte{Aload0, InvokeVirtual}, u2(clone)...), AReturn)...)
+
+	r, ctx := newTestRunner(t, append(throwableClasses(), cloneable.build(), sheep.build(), exception.build(), main.build())...)
+
+	original := intArray(t, r, 1, 2, 3)
+	copied, err := invokeTestMethod(t, ctx, r, "Main", "copy", "([I)Ljava/lang/Object;", stack.ReferenceValue{Value: original})
+	assert.Nil(t, err)
+	assert.NotEqual(t, original, referenceOf(copied))
+
+	array, err := r.heap.GetArray(referenceOf(copied))
+	assert.Nil(t, err)
+	assert.Equal(t, "[I", array.ClassName())
+	assert.Equal(t, []stack.Value{stack.IntValue{Value: 1}, stack.IntValue{Value: 2}, stack.IntValue{Value: 3}}, array.items)
+
+	layout, err := r.loader.Layout(ctx, "Sheep")
+	assert.Nil(t, err)
+	dolly, err := r.heap.AllocateObject(ctx, layout)
+	assert.Nil(t, err)
+	name := stack.ReferenceValue{Value: original}
+	nameField := loader.FieldKey{Class: "Sheep", Name: "name", Descriptor: "Ljava/lang/String;"}
+	assert.Nil(t, r.heap.SetField(dolly, nameField, name))
+
+	copied, err = objectClone(ctx, r, []stack.Value{stack.ReferenceValue{Value: dolly}})
+	assert.Nil(t, err)
+	assert.NotEqual(t, dolly, referenceOf(copied))
+
+	object, err := r.heap.GetObject(referenceOf(copied))
+	assert.Nil(t, err)
+	value, err := object.GetFieldValue(nameField)
+	assert.Nil(t, err)
+	assert.Equal(t, name, value)
+
+	str, err := r.newString(ctx, "not cloneable")
+	assert.Nil(t, err)
+	_, err = objectClone(ctx, r, []stack.Value{stack.ReferenceValue{Value: str}})
+	assert.Equal(t, "java.lang.CloneNotSupportedException: java.lang.String", err.Error())
+}
@@ -14,7 +14,12 @@ func arrayLength(ctx context.Context, r *Runner) error {
 		return err
 	}
 
-	if array, ok := operands[0].(Array); ok {
+	if arrayRef, ok := operands[0].(stack.ReferenceValue); ok {
+		array, err := r.heap.GetArray(arrayRef.Value)
+		if err != nil {
+			return err
+		}
+
 		return r.stack.PushOperand(ctx, stack.IntValue{Value: int32(len(array.items))})
 	}
 
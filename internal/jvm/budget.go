@@ -0,0 +1,149 @@
+package jvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+)
+
+// Budget is a resource whose use can be limited, see WithInstructionBudget, WithTimeBudget,
+// WithHeapBudget and WithStackDepthBudget.
+type Budget int
+
+const (
+	BudgetInstructions Budget = iota + 1
+	BudgetWallTime
+	BudgetHeap
+	BudgetStackDepth
+)
+
+func (b Budget) String() string {
+	switch b {
+	case BudgetInstructions:
+		return "instruction"
+	case BudgetWallTime:
+		return "wall time"
+	case BudgetHeap:
+		return "heap"
+	case BudgetStackDepth:
+		return "stack depth"
+	default:
+		return "unknown"
+	}
+}
+
+// BudgetExceededError terminates execution once a budget is used up. Unlike an exception it
+// can not be caught by the program, and it stops all threads of the VM.
+type BudgetExceededError struct {
+	Budget Budget
+	// Limit is the configured budget, in nanoseconds for the wall time and in bytes for the heap
+	Limit int64
+}
+
+func (e *BudgetExceededError) Error() string {
+	limit := strconv.FormatInt(e.Limit, 10)
+	switch e.Budget {
+	case BudgetWallTime:
+		limit = time.Duration(e.Limit).String()
+	case BudgetHeap:
+		limit += " bytes"
+	}
+
+	return fmt.Sprintf("%s budget of %s exceeded", e.Budget, limit)
+}
+
+// budgets are the limits configured for a VM, 0 means unlimited. The heap budget is kept by the heap.
+type budgets struct {
+	instructions int64
+	wallTime     time.Duration
+	stackDepth   int
+	// executed counts the instructions executed by all threads during the current call
+	executed int64
+	// stop cancels the context of all threads, it is set while RunMain runs
+	stop context.CancelCauseFunc
+}
+
+// start derives the context the threads of the VM run with for a call from the host, and
+// starts counting the instructions of the call. The context is cancelled with a
+// BudgetExceededError once the wall time budget is used up or another budget stops the VM.
+func (r *Runner) start(ctx context.Context) (context.Context, context.CancelFunc) {
+	r.budgets.executed = 0
+
+	ctx, stop := context.WithCancelCause(ctx)
+	r.budgets.stop = stop
+
+	cancel := func() {
+		stop(nil)
+	}
+
+	if r.budgets.wallTime > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, r.budgets.wallTime,
+			&BudgetExceededError{Budget: BudgetWallTime, Limit: int64(r.budgets.wallTime)})
+
+		cancel = func() {
+			cancelTimeout()
+			stop(nil)
+		}
+	}
+
+	return ctx, cancel
+}
+
+// stopAll stops the other threads of the VM because of err, which is returned.
+func (r *Runner) stopAll(err error) error {
+	if r.budgets.stop != nil {
+		r.budgets.stop(err)
+	}
+
+	return err
+}
+
+// stopping reports whether err ends the VM rather than just the thread: a budget that has been
+// used up, which stops the other threads too, or the context of the VM being cancelled.
+func (r *Runner) stopping(ctx context.Context, err error) bool {
+	var budgetErr *BudgetExceededError
+	if errors.As(err, &budgetErr) {
+		r.stopAll(err)
+		return true
+	}
+
+	return ctx.Err() != nil
+}
+
+// step accounts for the next instruction. Every yieldInterval instructions other threads get
+// a turn and the context is checked, so that a cancelled VM stops even in an endless loop.
+func (r *Runner) step(ctx context.Context) error {
+	r.budgets.executed++
+	if r.budgets.instructions > 0 && r.budgets.executed > r.budgets.instructions {
+		return r.stopAll(&BudgetExceededError{Budget: BudgetInstructions, Limit: r.budgets.instructions})
+	}
+
+	r.steps++
+	if r.steps < yieldInterval {
+		return nil
+	}
+
+	r.yield()
+
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
+	return nil
+}
+
+// pushFrame pushes the frame of a method, unless the stack depth budget is used up.
+func (r *Runner) pushFrame(c *class.Class, method class.Method, args []stack.Value) error {
+	if r.budgets.stackDepth > 0 && r.stack.Depth() >= r.budgets.stackDepth {
+		return r.stopAll(&BudgetExceededError{Budget: BudgetStackDepth, Limit: int64(r.budgets.stackDepth)})
+	}
+
+	r.stack.Push(c.Name, method, c.ConstantPool, args)
+	return nil
+}
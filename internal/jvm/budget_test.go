@@ -0,0 +1,114 @@
+package jvm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/stretchr/testify/assert"
+)
+
+const mainDescriptor = "([Ljava/lang/String;)V"
+
+// endlessClass has a main method that loops forever, after calling the static native Endless.spawn.
+func endlessClass() *class.Class {
+	endless := newTestClass("Endless", "java/lang/Object").
+		method(class.AccStatic|class.AccNative, "spawn", "()V")
+	spawn := endless.ref("Endless", "spawn", "()V")
+	endless.method(class.AccPublic|class.AccStatic, "main", mainDescriptor,
+		append(append([]byte{InvokeStaticOp}, u2(spawn)...), GoTo, 0, 0)...)
+
+	return endless.build()
+}
+
+func newEndlessRunner(t *testing.T, options ...Option) (*Runner, context.Context) {
+	r, ctx := newTestRunner(t, append(threadClasses(), endlessClass())...)
+	r.natives.Register("Endless", "spawn", "()V", func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+		return nil, nil
+	})
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r, ctx
+}
+
+// assertBudgetExceeded checks that err stopped the VM because of budget and returns its message.
+func assertBudgetExceeded(t *testing.T, err error, budget Budget) string {
+	var budgetErr *BudgetExceededError
+	if !assert.True(t, errors.As(err, &budgetErr), "%v", err) {
+		return ""
+	}
+
+	assert.Equal(t, budget, budgetErr.Budget)
+	return budgetErr.Error()
+}
+
+func TestInstructionBudget(t *testing.T) {
+	r, ctx := newEndlessRunner(t, WithInstructionBudget(10_000))
+
+	err := r.RunMain(ctx, "Endless")
+	assert.Equal(t, "instruction budget of 10000 exceeded", assertBudgetExceeded(t, err, BudgetInstructions))
+}
+
+func TestTimeBudget(t *testing.T) {
+	r, ctx := newEndlessRunner(t, WithTimeBudget(20*time.Millisecond))
+
+	err := r.RunMain(ctx, "Endless")
+	assert.Equal(t, "wall time budget of 20ms exceeded", assertBudgetExceeded(t, err, BudgetWallTime))
+}
+
+func TestCancelStopsEndlessLoop(t *testing.T) {
+	r, ctx := newEndlessRunner(t)
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+
+	err := r.RunMain(ctx, "Endless")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestStackDepthBudget(t *testing.T) {
+	recursive := newTestClass("Recursive", "java/lang/Object")
+	recurse := append(append([]byte{InvokeStaticOp}, u2(recursive.ref("Recursive", "recurse", "()V"))...), RetOp)
+	recursive.method(class.AccPublic|class.AccStatic, "main", mainDescriptor, recurse...).
+		method(class.AccStatic, "recurse", "()V", recurse...)
+	r, ctx := newTestRunner(t, recursive.build())
+	WithStackDepthBudget(50)(r)
+
+	err := r.RunMain(ctx, "Recursive")
+	assertBudgetExceeded(t, err, BudgetStackDepth)
+}
+
+func TestHeapBudget(t *testing.T) {
+	greedy := newTestClass("Greedy", "java/lang/Object").
+		method(class.AccPublic|class.AccStatic, "main", mainDescriptor, BiPush, 100, NewArray, 10, Astore0, RetOp)
+	r, ctx := newTestRunner(t, greedy.build())
+	WithHeapBudget(256)(r)
+
+	err := r.RunMain(ctx, "Greedy")
+	assert.Equal(t, "heap budget of 256 bytes exceeded", assertBudgetExceeded(t, err, BudgetHeap))
+}
+
+// TestBudgetStopsOtherThreads checks that a thread blocked forever does not keep the VM alive once a budget is used up.
+func TestBudgetStopsOtherThreads(t *testing.T) {
+	r, ctx := newEndlessRunner(t, WithInstructionBudget(10_000))
+	r.natives.Register("Endless", "spawn", "()V", func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+		thread := newThreadObject(t, ctx, r, "Blocked", "sleeper")
+		return threadStart0(ctx, r, []stack.Value{stack.ReferenceValue{Value: thread}})
+	})
+
+	stopped := make(chan error, 1)
+	r.natives.Register("Blocked", "run", "()V", func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+		err := r.sleep(ctx, time.Hour)
+		stopped <- err
+		return nil, err
+	})
+
+	err := r.RunMain(ctx, "Endless")
+	assertBudgetExceeded(t, err, BudgetInstructions)
+	assertBudgetExceeded(t, <-stopped, BudgetInstructions)
+}
@@ -0,0 +1,75 @@
+package jvm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+)
+
+// classOperand returns the class name the two byte constant pool index after the current instruction refers to.
+func (r *Runner) classOperand(code []byte) (string, error) {
+	index := uint16(code[r.pc+1])<<8 | uint16(code[r.pc+2])
+
+	pool, err := r.stack.CurrentConstantPool()
+	if err != nil {
+		return "", err
+	}
+
+	info, err := pool.Class(index)
+	if err != nil {
+		return "", err
+	}
+
+	return pool.GetUtf8(info.NameIndex)
+}
+
+// isInstanceOf reports whether the object at ref is an instance of className, null never is.
+func (r *Runner) isInstanceOf(ctx context.Context, ref stack.Reference, className string) (bool, error) {
+	if ref == stack.Null {
+		return false, nil
+	}
+
+	runtimeName, err := r.runtimeClassName(ref)
+	if err != nil {
+		return false, err
+	}
+
+	return r.isAssignable(ctx, runtimeName, className)
+}
+
+func checkCast(r *Runner, ctx context.Context, code []byte) error {
+	className, err := r.classOperand(code)
+	if err != nil {
+		return err
+	}
+
+	r.pc += 3
+
+	operand, err := r.stack.GetOperand()
+	if err != nil {
+		return err
+	}
+
+	ref := referenceOf(operand)
+	if ref == stack.Null {
+		return nil
+	}
+
+	ok, err := r.isInstanceOf(ctx, ref, className)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		runtimeName, err := r.runtimeClassName(ref)
+		if err != nil {
+			return err
+		}
+
+		message := fmt.Sprintf("class %s cannot be cast to class %s", dotted(runtimeName), dotted(className))
+		return r.newThrowable(ctx, "java/lang/ClassCastException", message, nil)
+	}
+
+	return nil
+}
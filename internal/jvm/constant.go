@@ -0,0 +1,78 @@
+package jvm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+)
+
+// initializeConstants sets the final static fields that have a ConstantValue attribute (JVMS §5.5, step 6).
+func (r *Runner) initializeConstants(ctx context.Context, c *class.Class) error {
+	for _, field := range c.Fields {
+		if !field.IsStatic() || !field.IsFinal() {
+			continue
+		}
+
+		attribute, ok := field.ConstantValueAttribute()
+		if !ok {
+			continue
+		}
+
+		name, err := c.ConstantPool.GetUtf8(field.NameIndex)
+		if err != nil {
+			return err
+		}
+
+		descriptor, err := c.ConstantPool.GetUtf8(field.DescriptorIndex)
+		if err != nil {
+			return err
+		}
+
+		value, err := r.constantValue(ctx, &c.ConstantPool, attribute.ConstantValueIndex)
+		if err != nil {
+			return fmt.Errorf("constant value of %s.%s: %w", c.Name, name, err)
+		}
+
+		err = r.loader.SetField(loader.FieldKey{Class: c.Name, Name: name, Descriptor: descriptor}, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Runner) constantValue(ctx context.Context, pool *class.ConstantPool, index uint16) (stack.Value, error) {
+	cpInfo, err := pool.Get(int(index))
+	if err != nil {
+		return nil, err
+	}
+
+	switch info := cpInfo.(type) {
+	case class.IntegerInfo:
+		return stack.IntValue{Value: int32(info.Value)}, nil
+	case class.LongInfo:
+		return stack.LongValue{Value: info.Value}, nil
+	case class.FloatInfo:
+		return stack.FloatValue{Value: info.Value}, nil
+	case class.DoubleInfo:
+		return stack.DoubleValue{Value: info.Value}, nil
+	case class.StringInfo:
+		value, err := pool.GetUtf8(info.StringIndex)
+		if err != nil {
+			return nil, err
+		}
+
+		ref, err := r.newString(ctx, value)
+		if err != nil {
+			return nil, err
+		}
+
+		return stack.ReferenceValue{Value: ref}, nil
+	default:
+		return nil, fmt.Errorf("invalid constant value %s", cpInfo)
+	}
+}
@@ -0,0 +1,517 @@
+package jvm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+)
+
+// ErrDebuggerQuit is returned by RunMain when the program has been stopped with the quit command of the debugger.
+var ErrDebuggerQuit = errors.New("quit by the debugger")
+
+// maxPrintedItems limits the array items print shows.
+const maxPrintedItems = 100
+
+const debuggerHelp = `break Class.method[@offset]  stop before the instruction at offset, the method entry by default
+delete <n>                   delete breakpoint n
+breakpoints                  list the breakpoints
+continue                     run until the next breakpoint
+step                         run until the line changes or a method is entered or left
+next                         like step, but run called methods to completion
+stepi                        execute a single instruction
+where                        print the stack of the current thread
+locals [frame]               print the local variables of a frame, 0 being the current one
+stack [frame]                print the operand stack of a frame
+disassemble [frame]          print the bytecode of the method of a frame
+print <reference>            print the object or array a reference like 0x2a points to
+quit                         stop the program
+`
+
+type stepKind int
+
+const (
+	stepNone stepKind = iota
+	// stepInstruction stops at the next instruction
+	stepInstruction
+	// stepLine stops when the line or the frame changes
+	stepLine
+	// stepOver stops when the line changes, but not in called methods
+	stepOver
+)
+
+// debugBreakpoint stops every thread about to execute the instruction at pc of a method.
+type debugBreakpoint struct {
+	id         int
+	className  string
+	methodName string
+	pc         int
+}
+
+func (b debugBreakpoint) String() string {
+	return fmt.Sprintf("%s.%s@%d", dotted(b.className), b.methodName, b.pc)
+}
+
+// debugger is an interactive command-line debugger. The thread that hits a breakpoint or finishes
+// a step reads commands while it holds the interpreter lock, so all other threads stand still.
+type debugger struct {
+	in               *bufio.Scanner
+	out              io.Writer
+	breakpoints      []debugBreakpoint
+	nextBreakpointID int
+	// step is what stepThread runs until, stepDepth and stepLine are where the step started
+	step        stepKind
+	stepThread  *Runner
+	stepDepth   int
+	stepLine    int
+	stepHasLine bool
+	// detached is set once the input is exhausted, the program then runs without stopping
+	detached bool
+}
+
+func newDebugger(in io.Reader, out io.Writer) *debugger {
+	return &debugger{in: bufio.NewScanner(in), out: out}
+}
+
+// start lets the user set breakpoints before the main method runs.
+func (d *debugger) start(ctx context.Context, r *Runner, className string) error {
+	fmt.Fprintf(d.out, "Stopped before %s.main, type help for the commands\n", dotted(className))
+	return d.repl(ctx, r)
+}
+
+// instruction is called before r executes the instruction at r.pc. It stops at breakpoints and
+// completed steps until the user continues.
+func (d *debugger) instruction(ctx context.Context, r *Runner, code *class.CodeAttribute) error {
+	if d.detached {
+		return nil
+	}
+
+	reason := ""
+	if d.stepCompleted(r, code) {
+		reason = "Step completed"
+	}
+
+	if b, ok := d.breakpointAt(r); ok {
+		reason = fmt.Sprintf("Breakpoint %d", b.id)
+	}
+
+	if reason == "" {
+		return nil
+	}
+
+	d.step = stepNone
+
+	frame := r.stack.Trace()[0]
+	fmt.Fprintf(d.out, "%s in thread %s: %s\n", reason, r.threadName(), d.frameText(ctx, r, frame))
+
+	pool, err := r.stack.CurrentConstantPool()
+	if err == nil {
+		text, _, _ := decodeInstruction(pool, code.Code, r.pc)
+		fmt.Fprintf(d.out, "=> %4d: %s\n", r.pc, text)
+	}
+
+	return d.repl(ctx, r)
+}
+
+func (d *debugger) stepCompleted(r *Runner, code *class.CodeAttribute) bool {
+	if d.step == stepNone || d.stepThread != r {
+		return false
+	}
+
+	depth := r.stack.Depth()
+	line, hasLine := code.LineNumber(r.pc)
+	lineChanged := !hasLine || !d.stepHasLine || line != d.stepLine
+
+	switch d.step {
+	case stepLine:
+		return depth != d.stepDepth || lineChanged
+	case stepOver:
+		return depth < d.stepDepth || depth == d.stepDepth && lineChanged
+	default:
+		return true
+	}
+}
+
+func (d *debugger) breakpointAt(r *Runner) (debugBreakpoint, bool) {
+	for _, b := range d.breakpoints {
+		if b.pc != r.pc || b.className != r.stack.CurrentClassName() {
+			continue
+		}
+
+		pool, err := r.stack.CurrentConstantPool()
+		if err != nil {
+			continue
+		}
+
+		method, err := r.stack.CurrentMethod()
+		if err != nil {
+			continue
+		}
+
+		name, err := pool.GetUtf8(method.NameIndex)
+		if err == nil && name == b.methodName {
+			return b, true
+		}
+	}
+
+	return debugBreakpoint{}, false
+}
+
+// repl reads and runs commands until one resumes the program.
+func (d *debugger) repl(ctx context.Context, r *Runner) error {
+	for {
+		fmt.Fprint(d.out, "(swell) ")
+		if !d.in.Scan() {
+			fmt.Fprintln(d.out)
+			d.detached = true
+			return d.in.Err()
+		}
+
+		fields := strings.Fields(d.in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		resume, err := d.command(ctx, r, fields[0], fields[1:])
+		if errors.Is(err, ErrDebuggerQuit) {
+			return err
+		}
+
+		if err != nil {
+			fmt.Fprintln(d.out, err)
+			continue
+		}
+
+		if resume {
+			return nil
+		}
+	}
+}
+
+// command runs a command and reports whether the program is to be resumed.
+func (d *debugger) command(ctx context.Context, r *Runner, name string, args []string) (bool, error) {
+	switch name {
+	case "help", "h":
+		fmt.Fprint(d.out, debuggerHelp)
+	case "break", "b":
+		return false, d.addBreakpoint(args)
+	case "delete", "d":
+		return false, d.deleteBreakpoint(args)
+	case "breakpoints":
+		for _, b := range d.breakpoints {
+			fmt.Fprintf(d.out, "%d: %s\n", b.id, b)
+		}
+	case "continue", "c":
+		return true, nil
+	case "step", "s":
+		d.startStep(r, stepLine)
+		return true, nil
+	case "next", "n":
+		d.startStep(r, stepOver)
+		return true, nil
+	case "stepi", "si":
+		d.startStep(r, stepInstruction)
+		return true, nil
+	case "where", "bt":
+		for i, frame := range r.stack.Trace() {
+			fmt.Fprintf(d.out, "#%d %s\n", i, d.frameText(ctx, r, frame))
+		}
+	case "locals":
+		return false, d.printLocals(ctx, r, args)
+	case "stack":
+		return false, d.printOperands(r, args)
+	case "disassemble", "disas":
+		return false, d.disassemble(ctx, r, args)
+	case "print", "p":
+		return false, d.print(r, args)
+	case "quit", "q":
+		return false, r.stopAll(ErrDebuggerQuit)
+	default:
+		return false, fmt.Errorf("unknown command %s, type help for the commands", name)
+	}
+
+	return false, nil
+}
+
+func (d *debugger) addBreakpoint(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: break Class.method[@offset]")
+	}
+
+	location, offset, hasOffset := strings.Cut(args[0], "@")
+	dot := strings.LastIndex(location, ".")
+	if dot <= 0 || dot == len(location)-1 {
+		return errors.New("usage: break Class.method[@offset]")
+	}
+
+	pc := 0
+	if hasOffset {
+		var err error
+		pc, err = strconv.Atoi(offset)
+		if err != nil || pc < 0 {
+			return fmt.Errorf("invalid offset %s", offset)
+		}
+	}
+
+	d.nextBreakpointID++
+	b := debugBreakpoint{
+		id:         d.nextBreakpointID,
+		className:  strings.ReplaceAll(location[:dot], ".", "/"),
+		methodName: location[dot+1:],
+		pc:         pc,
+	}
+	d.breakpoints = append(d.breakpoints, b)
+
+	fmt.Fprintf(d.out, "Breakpoint %d at %s\n", b.id, b)
+	return nil
+}
+
+func (d *debugger) deleteBreakpoint(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: delete <n>")
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid breakpoint %s", args[0])
+	}
+
+	for i, b := range d.breakpoints {
+		if b.id == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no breakpoint %d", id)
+}
+
+func (d *debugger) startStep(r *Runner, step stepKind) {
+	d.step = step
+	d.stepThread = r
+	d.stepDepth = r.stack.Depth()
+	d.stepLine, d.stepHasLine = r.currentLine()
+}
+
+// frame returns the frame selected by the optional argument of a command, 0 being the current one.
+func (d *debugger) frame(r *Runner, args []string) (stack.TraceFrame, error) {
+	trace := r.stack.Trace()
+	if len(trace) == 0 {
+		return stack.TraceFrame{}, errors.New("the thread has no frames yet")
+	}
+
+	if len(args) == 0 {
+		return trace[0], nil
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 || n >= len(trace) {
+		return stack.TraceFrame{}, fmt.Errorf("no frame %s, the thread has %d", args[0], len(trace))
+	}
+
+	return trace[n], nil
+}
+
+func (d *debugger) frameText(ctx context.Context, r *Runner, frame stack.TraceFrame) string {
+	return fmt.Sprintf("%s.%s@%d (%s)", dotted(frame.ClassName), frame.MethodName, frame.Pc, r.frameLocation(ctx, frame))
+}
+
+// printLocals prints the local variables of a frame, with their names if the method has a LocalVariableTable.
+func (d *debugger) printLocals(ctx context.Context, r *Runner, args []string) error {
+	frame, err := d.frame(r, args)
+	if err != nil {
+		return err
+	}
+
+	var variables []class.LocalVariableTableEntry
+	code, err := frame.Method.CodeAttribute()
+	if err == nil {
+		variables, _ = code.LocalVariables()
+	}
+
+	c, err := r.loader.Load(ctx, frame.ClassName)
+	if err != nil {
+		return err
+	}
+
+	for slot, value := range frame.LocalVariables {
+		name := ""
+		for _, variable := range variables {
+			if int(variable.Index) == slot && int(variable.StartPc) <= frame.Pc && frame.Pc < int(variable.StartPc)+int(variable.Length) {
+				name, _ = c.ConstantPool.GetUtf8(variable.NameIndex)
+			}
+		}
+
+		fmt.Fprintf(d.out, "%3d %-12s %s\n", slot, name, r.describeValue(value))
+	}
+
+	return nil
+}
+
+// printOperands prints the operand stack of a frame from the bottom to the top.
+func (d *debugger) printOperands(r *Runner, args []string) error {
+	frame, err := d.frame(r, args)
+	if err != nil {
+		return err
+	}
+
+	if len(frame.Operands) == 0 {
+		fmt.Fprintln(d.out, "the operand stack is empty")
+	}
+
+	for i, value := range frame.Operands {
+		fmt.Fprintf(d.out, "%3d %s\n", i, r.describeValue(value))
+	}
+
+	return nil
+}
+
+func (d *debugger) disassemble(ctx context.Context, r *Runner, args []string) error {
+	frame, err := d.frame(r, args)
+	if err != nil {
+		return err
+	}
+
+	if frame.Method.IsNative() {
+		return errors.New("the method is native")
+	}
+
+	code, err := frame.Method.CodeAttribute()
+	if err != nil {
+		return err
+	}
+
+	c, err := r.loader.Load(ctx, frame.ClassName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(d.out, "%s.%s\n", dotted(frame.ClassName), frame.MethodName)
+	disassemble(d.out, &c.ConstantPool, code.Code, frame.Pc)
+	return nil
+}
+
+// print prints the fields of an object or the items of an array.
+func (d *debugger) print(r *Runner, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: print <reference>")
+	}
+
+	n, err := strconv.ParseUint(strings.TrimPrefix(args[0], "@"), 0, 32)
+	if err != nil || n == 0 {
+		return fmt.Errorf("invalid reference %s", args[0])
+	}
+
+	ref := stack.Reference(n)
+	item, err := r.heap.get(ref)
+	if err != nil {
+		return fmt.Errorf("no object at %s", ref)
+	}
+
+	fmt.Fprintln(d.out, r.describeReference(ref))
+
+	switch item := item.(type) {
+	case *Object:
+		for i, field := range item.layout.Fields {
+			fmt.Fprintf(d.out, "  %s.%s = %s\n", dotted(field.Class), field.Name, r.describeValue(item.fields[i]))
+		}
+	case *Array:
+		for i, value := range item.items[:min(len(item.items), maxPrintedItems)] {
+			fmt.Fprintf(d.out, "  [%d] = %s\n", i, r.describeValue(value))
+		}
+
+		if len(item.items) > maxPrintedItems {
+			fmt.Fprintf(d.out, "  ... %d more\n", len(item.items)-maxPrintedItems)
+		}
+	}
+
+	return nil
+}
+
+// describeValue renders a value for the debugger, - standing for a local variable that has not been set.
+func (r *Runner) describeValue(value stack.Value) string {
+	switch v := value.(type) {
+	case nil:
+		return "-"
+	case stack.BooleanValue:
+		return strconv.FormatBool(v.Value)
+	case stack.CharValue:
+		return strconv.QuoteRune(v.Value)
+	case stack.FloatValue:
+		return strconv.FormatFloat(float64(v.Value), 'g', -1, 32)
+	case stack.DoubleValue:
+		return strconv.FormatFloat(v.Value, 'g', -1, 64)
+	case stack.ReferenceValue, stack.ClassReferenceValue:
+		return r.describeReference(referenceOf(value))
+	}
+
+	if n, ok := intOf(value); ok {
+		return strconv.FormatInt(n, 10)
+	}
+
+	return fmt.Sprint(value)
+}
+
+// describeReference renders a reference with the class of its object, e.g. 0x2a java.lang.String "hello".
+func (r *Runner) describeReference(ref stack.Reference) string {
+	if ref == stack.Null {
+		return "null"
+	}
+
+	item, err := r.heap.get(ref)
+	if err != nil {
+		return fmt.Sprintf("%s <invalid>", ref)
+	}
+
+	if object, ok := item.(*Object); ok {
+		switch object.ClassName() {
+		case "java/lang/String":
+			s, err := r.goString(ref)
+			if err == nil {
+				return fmt.Sprintf("%s java.lang.String %q", ref, s)
+			}
+		case "java/lang/Class":
+			if name, ok := r.mirrorNames[ref]; ok {
+				return fmt.Sprintf("%s java.lang.Class %s", ref, dotted(name))
+			}
+		}
+	}
+
+	return fmt.Sprintf("%s %s", ref, describeType(item))
+}
+
+// describeType returns the Java type of a heap item, with the length for arrays, e.g. int[3][].
+func describeType(item HeapItem) string {
+	switch item := item.(type) {
+	case *Array:
+		element := typeName(item.ClassName()[1:])
+		if i := strings.Index(element, "["); i >= 0 {
+			return fmt.Sprintf("%s[%d]%s", element[:i], len(item.items), element[i:])
+		}
+
+		return fmt.Sprintf("%s[%d]", element, len(item.items))
+	case *Object:
+		return dotted(item.ClassName())
+	default:
+		return fmt.Sprintf("%T", item)
+	}
+}
+
+// typeName returns the Java name of a field descriptor, e.g. int[] for [I.
+func typeName(descriptor string) string {
+	dimensions := strings.Count(descriptor, "[")
+	element := descriptor[dimensions:]
+
+	name, ok := primitiveDescriptors[element]
+	if !ok {
+		name = dotted(strings.TrimSuffix(strings.TrimPrefix(element, "L"), ";"))
+	}
+
+	return name + strings.Repeat("[]", dimensions)
+}
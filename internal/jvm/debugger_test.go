@@ -0,0 +1,68 @@
+package jvm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDebuggeeRunner(t *testing.T) (*Runner, context.Context) {
+	r, ctx := newTestRunner(t, debuggeeClass())
+	r.natives.Register("Debuggee", "load", "()V", func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+		return nil, nil
+	})
+
+	return r, ctx
+}
+
+func TestDebugger(t *testing.T) {
+	r, ctx := newDebuggeeRunner(t)
+	array, err := r.heap.AllocateArray(ctx, "[I", []stack.Value{stack.IntValue{Value: 1}, stack.IntValue{Value: 2}})
+	assert.NoError(t, err)
+
+	commands := []string{
+		"break Debuggee.main@5",
+		"continue",
+		"where",
+		"locals",
+		"stepi",
+		"stack",
+		"disassemble",
+		"print " + array.String(),
+		"next",
+		"locals",
+		"continue",
+	}
+	out := &strings.Builder{}
+	WithDebugger(strings.NewReader(strings.Join(commands, "\n")), out)(r)
+
+	assert.NoError(t, r.RunMain(ctx, "Debuggee"))
+
+	output := out.String()
+	for _, expected := range []string{
+		"Breakpoint 1 at Debuggee.main@5\n",
+		"Breakpoint 1 in thread main: Debuggee.main@5 (Unknown Source)\n=>    5: iconst_2\n",
+		"#0 Debuggee.main@5 (Unknown Source)\n",
+		"  2 x            5\n",
+		"Step completed in thread main: Debuggee.main@6 (Unknown Source)\n",
+		"  0 2\n",
+		"     0: invokestatic   #8     // Debuggee.load:()V\n",
+		"=>    6: istore_2\n",
+		fmt.Sprintf("%s int[2]\n  [0] = 1\n  [1] = 2\n", array),
+		"Step completed in thread main: Debuggee.main@7 (Unknown Source)\n",
+		"  2 x            2\n",
+	} {
+		assert.Contains(t, output, expected)
+	}
+}
+
+func TestDebuggerQuit(t *testing.T) {
+	r, ctx := newDebuggeeRunner(t)
+	WithDebugger(strings.NewReader("quit\n"), &strings.Builder{})(r)
+
+	assert.ErrorIs(t, r.RunMain(ctx, "Debuggee"), ErrDebuggerQuit)
+}
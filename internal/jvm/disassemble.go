@@ -0,0 +1,185 @@
+package jvm
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/m4tthewde/swell/internal/class"
+)
+
+// Operand kinds of instructions.
+const (
+	operandNone = iota
+	// operandByte is a signed byte, like the value of bipush
+	operandByte
+	// operandArrayType is the atype of newarray
+	operandArrayType
+	// operandConstant is a one byte constant pool index, as of ldc
+	operandConstant
+	// operandWideConstant is a two byte constant pool index
+	operandWideConstant
+	// operandBranch is a two byte signed offset relative to the instruction
+	operandBranch
+)
+
+type opcode struct {
+	name    string
+	operand int
+}
+
+// opcodes are the instructions the interpreter implements, by opcode.
+var opcodes = map[byte]opcode{
+	Nop:             {"nop", operandNone},
+	IConstM1:        {"iconst_m1", operandNone},
+	IConst0:         {"iconst_0", operandNone},
+	IConst1:         {"iconst_1", operandNone},
+	IConst2:         {"iconst_2", operandNone},
+	IConst3:         {"iconst_3", operandNone},
+	IConst4:         {"iconst_4", operandNone},
+	IConst5:         {"iconst_5", operandNone},
+	BiPush:          {"bipush", operandByte},
+	LdcOp:           {"ldc", operandConstant},
+	LdcWide:         {"ldc_w", operandWideConstant},
+	ILoad0:          {"iload_0", operandNone},
+	ILoad1:          {"iload_1", operandNone},
+	ILoad2:          {"iload_2", operandNone},
+	Aload0:          {"aload_0", operandNone},
+	Aload1:          {"aload_1", operandNone},
+	Aload2:          {"aload_2", operandNone},
+	Aload3:          {"aload_3", operandNone},
+	AALoad:          {"aaload", operandNone},
+	IStore2:         {"istore_2", operandNone},
+	Astore0:         {"astore_0", operandNone},
+	Astore1:         {"astore_1", operandNone},
+	Astore2:         {"astore_2", operandNone},
+	Astore3:         {"astore_3", operandNone},
+	AAStore:         {"aastore", operandNone},
+	DupOp:           {"dup", operandNone},
+	ISub:            {"isub", operandNone},
+	IntShiftRight:   {"ishr", operandNone},
+	IfEq:            {"ifeq", operandBranch},
+	IfNe:            {"ifne", operandBranch},
+	IfLt:            {"iflt", operandBranch},
+	IfICmpLt:        {"if_icmplt", operandBranch},
+	GoTo:            {"goto", operandBranch},
+	IReturn:         {"ireturn", operandNone},
+	AReturn:         {"areturn", operandNone},
+	RetOp:           {"return", operandNone},
+	GetStaticOp:     {"getstatic", operandWideConstant},
+	PutStatic:       {"putstatic", operandWideConstant},
+	GetField:        {"getfield", operandWideConstant},
+	PutField:        {"putfield", operandWideConstant},
+	InvokeVirtual:   {"invokevirtual", operandWideConstant},
+	InvokeSpecialOp: {"invokespecial", operandWideConstant},
+	InvokeStaticOp:  {"invokestatic", operandWideConstant},
+	NewOp:           {"new", operandWideConstant},
+	NewArray:        {"newarray", operandArrayType},
+	ANewArray:       {"anewarray", operandWideConstant},
+	ArrayLength:     {"arraylength", operandNone},
+	AThrow:          {"athrow", operandNone},
+	CheckCast:       {"checkcast", operandWideConstant},
+	InstanceOf:      {"instanceof", operandWideConstant},
+	MonitorEnter:    {"monitorenter", operandNone},
+	MonitorExit:     {"monitorexit", operandNone},
+}
+
+// operandLengths are the bytes following the opcode for each operand kind.
+var operandLengths = map[int]int{
+	operandNone:         0,
+	operandByte:         1,
+	operandArrayType:    1,
+	operandConstant:     1,
+	operandWideConstant: 2,
+	operandBranch:       2,
+}
+
+// disassemble writes the instructions of code like javap -c does, marking the one at pc with =>.
+// It stops at the first instruction the interpreter does not implement.
+func disassemble(w io.Writer, pool *class.ConstantPool, code []byte, pc int) {
+	for offset := 0; offset < len(code); {
+		marker := "  "
+		if offset == pc {
+			marker = "=>"
+		}
+
+		text, length, ok := decodeInstruction(pool, code, offset)
+		fmt.Fprintf(w, "%s %4d: %s\n", marker, offset, text)
+		if !ok {
+			return
+		}
+
+		offset += length
+	}
+}
+
+// decodeInstruction renders the instruction at offset and returns its length including the operand.
+// ok is false for unknown and truncated instructions, whose length is unknown.
+func decodeInstruction(pool *class.ConstantPool, code []byte, offset int) (string, int, bool) {
+	op, ok := opcodes[code[offset]]
+	if !ok {
+		return fmt.Sprintf("<unknown opcode %#x>", code[offset]), 0, false
+	}
+
+	length := operandLengths[op.operand]
+	if offset+length >= len(code) {
+		return op.name + " <truncated>", 0, false
+	}
+
+	return instructionText(pool, op, code[offset+1:offset+1+length], offset), 1 + length, true
+}
+
+// instructionText renders an instruction with its operand, e.g. invokestatic #7 // Main.add:(II)I.
+func instructionText(pool *class.ConstantPool, op opcode, operand []byte, offset int) string {
+	switch op.operand {
+	case operandByte:
+		return fmt.Sprintf("%-14s %d", op.name, int8(operand[0]))
+	case operandArrayType:
+		className, ok := arrayTypes[operand[0]]
+		if !ok {
+			return fmt.Sprintf("%-14s %d", op.name, operand[0])
+		}
+
+		return fmt.Sprintf("%-14s %s", op.name, typeName(className[1:]))
+	case operandConstant:
+		return constantText(pool, op.name, uint16(operand[0]))
+	case operandWideConstant:
+		return constantText(pool, op.name, uint16(operand[0])<<8|uint16(operand[1]))
+	case operandBranch:
+		return fmt.Sprintf("%-14s %d", op.name, offset+int(int16(uint16(operand[0])<<8|uint16(operand[1]))))
+	default:
+		return op.name
+	}
+}
+
+func constantText(pool *class.ConstantPool, name string, index uint16) string {
+	return fmt.Sprintf("%-14s #%-5d // %s", name, index, constantString(pool, index))
+}
+
+// constantString describes a constant pool entry, e.g. java/lang/String.length:()I for a method reference.
+func constantString(pool *class.ConstantPool, index uint16) string {
+	info, err := pool.Get(int(index))
+	if err != nil {
+		return "<invalid>"
+	}
+
+	switch info := info.(type) {
+	case class.ClassInfo:
+		name, _ := pool.GetUtf8(info.NameIndex)
+		return name
+	case class.StringInfo:
+		s, _ := pool.GetUtf8(info.StringIndex)
+		return fmt.Sprintf("%q", s)
+	case class.RefInfo:
+		owner := constantString(pool, info.ClassIndex)
+		nameAndType, err := pool.NameAndType(info.NameAndTypeIndex)
+		if err != nil {
+			return owner
+		}
+
+		name, _ := pool.GetUtf8(nameAndType.NameIndex)
+		descriptor, _ := pool.GetUtf8(nameAndType.DescriptorIndex)
+		return fmt.Sprintf("%s.%s:%s", owner, name, descriptor)
+	default:
+		return info.String()
+	}
+}
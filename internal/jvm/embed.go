@@ -0,0 +1,434 @@
+package jvm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+)
+
+// GoNative is a native method working on Go values, converted like the arguments and result of InvokeStatic.
+// The receiver of an instance method is not passed.
+type GoNative func(ctx context.Context, args []any) (any, error)
+
+// WithGoNative registers a GoNative as the native method className.name with descriptor.
+func WithGoNative(className string, name string, descriptor string, fn GoNative) Option {
+	return func(r *Runner) {
+		r.natives.Register(className, name, descriptor, goNative(descriptor, fn))
+	}
+}
+
+func goNative(descriptor string, fn GoNative) NativeMethod {
+	method, descriptorErr := class.NewMethodDescriptor(descriptor)
+
+	return func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+		if descriptorErr != nil {
+			return nil, descriptorErr
+		}
+
+		// the receiver of instance methods comes first
+		args = args[len(args)-len(method.Parameters):]
+
+		values := make([]any, len(args))
+		for i, arg := range args {
+			var err error
+			values[i], err = r.toGo(arg, method.Parameters[i])
+			if err != nil {
+				return nil, fmt.Errorf("argument %d: %w", i, err)
+			}
+		}
+
+		result, err := fn(ctx, values)
+		if err != nil {
+			return nil, err
+		}
+
+		returnType, ok := method.ReturnDescriptor.(class.FieldType)
+		if !ok {
+			return nil, nil
+		}
+
+		return r.toJava(ctx, result, returnType)
+	}
+}
+
+// DefineClass parses a class file and defines it as className, so that it is found without
+// looking at the class path.
+func (r *Runner) DefineClass(ctx context.Context, className string, data []byte) error {
+	c, err := class.NewClass(bufio.NewReader(bytes.NewReader(data)), className)
+	if err != nil {
+		return err
+	}
+
+	leave := r.enter()
+	defer leave()
+
+	return r.loader.Define(r.withLogger(ctx), c)
+}
+
+// InvokeStatic calls a static method from Go and waits for the non-daemon threads it started, like RunMain.
+// Go ints, floats, bools, strings, slices and nil are converted to the parameter types of the descriptor,
+// the result is converted back, void methods return nil. Arrays of references are returned as []any.
+func (r *Runner) InvokeStatic(ctx context.Context, className string, name string, descriptor string, args ...any) (any, error) {
+	leave := r.enter()
+	defer leave()
+
+	var result any
+	err := r.execute(r.withLogger(ctx), func(ctx context.Context) error {
+		var err error
+		result, err = r.invokeStatic(ctx, className, name, descriptor, args)
+		return err
+	})
+
+	return result, err
+}
+
+func (r *Runner) invokeStatic(ctx context.Context, className string, name string, descriptor string, args []any) (any, error) {
+	method, err := class.NewMethodDescriptor(descriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) != len(method.Parameters) {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", methodSignature(className, name, descriptor), len(method.Parameters), len(args))
+	}
+
+	c, resolved, err := r.loader.ResolveMethod(ctx, className, name, descriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	if !resolved.IsStatic() {
+		return nil, fmt.Errorf("%s is not static", methodSignature(className, name, descriptor))
+	}
+
+	// the frame of the caller receives the result
+	r.stack.Push(className, class.Method{}, class.ConstantPool{Infos: []class.CpInfo{class.Utf8Info{Content: "<go>"}}}, nil)
+	defer r.stack.Pop()
+
+	// the converted arguments are only reachable through the handles until the method runs
+	handleMark := r.heap.HandleMark()
+	defer r.heap.ReleaseHandles(handleMark)
+
+	values := make([]stack.Value, len(args))
+	for i, arg := range args {
+		values[i], err = r.toJavaOperand(ctx, arg, method.Parameters[i])
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+	}
+
+	err = r.initializeClass(ctx, c.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.invokeMethod(ctx, c, resolved, values)
+	if err != nil {
+		return nil, err
+	}
+
+	returnType, ok := method.ReturnDescriptor.(class.FieldType)
+	if !ok {
+		return nil, nil
+	}
+
+	operands, err := r.stack.PopOperands(1)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.toGo(operands[0], returnType)
+}
+
+// toJavaOperand converts a Go value like toJava, but passes boolean, byte, char and short as int like the JVM does.
+func (r *Runner) toJavaOperand(ctx context.Context, value any, typ class.FieldType) (stack.Value, error) {
+	switch typ {
+	case class.BaseType(class.BOOLEAN), class.BaseType(class.BYTE), class.BaseType(class.CHAR), class.BaseType(class.SHORT):
+		converted, err := r.toJava(ctx, value, typ)
+		if err != nil {
+			return nil, err
+		}
+
+		n, _ := intOf(converted)
+		return stack.IntValue{Value: int32(n)}, nil
+	default:
+		return r.toJava(ctx, value, typ)
+	}
+}
+
+// toJava converts a Go value to a Java value of type typ, as stored in fields and arrays.
+func (r *Runner) toJava(ctx context.Context, value any, typ class.FieldType) (stack.Value, error) {
+	switch typ := typ.(type) {
+	case class.BaseType:
+		return toJavaPrimitive(value, typ)
+	case class.ObjectType:
+		if value == nil {
+			return stack.ReferenceValue{Value: stack.Null}, nil
+		}
+
+		s, ok := value.(string)
+		if !ok || (typ.ClassName != "java/lang/String" && typ.ClassName != "java/lang/Object") {
+			return nil, fmt.Errorf("can not convert %T to %s", value, dotted(typ.ClassName))
+		}
+
+		ref, err := r.newString(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+
+		return stack.ReferenceValue{Value: ref}, nil
+	case class.ArrayType:
+		v := reflect.ValueOf(value)
+		if value == nil || (v.Kind() == reflect.Slice && v.IsNil()) {
+			return stack.ReferenceValue{Value: stack.Null}, nil
+		}
+
+		if v.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("can not convert %T to an array", value)
+		}
+
+		items := make([]stack.Value, v.Len())
+		for i := range items {
+			item, err := r.toJava(ctx, v.Index(i).Interface(), typ.FieldType)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+
+			items[i] = item
+			if ref := referenceOf(item); ref != stack.Null {
+				r.heap.AddHandle(ref)
+			}
+		}
+
+		ref, err := r.allocateArray(ctx, fieldDescriptor(typ), items)
+		if err != nil {
+			return nil, err
+		}
+
+		return stack.ReferenceValue{Value: ref}, nil
+	default:
+		return nil, fmt.Errorf("unknown field type %s", typ)
+	}
+}
+
+func toJavaPrimitive(value any, typ class.BaseType) (stack.Value, error) {
+	if typ == class.BOOLEAN {
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("can not convert %T to boolean", value)
+		}
+
+		return stack.BooleanValue{Value: b}, nil
+	}
+
+	if typ == class.FLOAT || typ == class.DOUBLE {
+		var f float64
+		switch v := value.(type) {
+		case float32:
+			f = float64(v)
+		case float64:
+			f = v
+		default:
+			n, ok := goInt(value)
+			if !ok {
+				return nil, fmt.Errorf("can not convert %T to %s", value, primitiveDescriptors[string(typ)])
+			}
+
+			f = float64(n)
+		}
+
+		if typ == class.FLOAT {
+			return stack.FloatValue{Value: float32(f)}, nil
+		}
+
+		return stack.DoubleValue{Value: f}, nil
+	}
+
+	n, ok := goInt(value)
+	if !ok {
+		return nil, fmt.Errorf("can not convert %T to %s", value, primitiveDescriptors[string(typ)])
+	}
+
+	switch typ {
+	case class.BYTE:
+		if n < math.MinInt8 || n > math.MaxInt8 {
+			return nil, fmt.Errorf("%d does not fit into a byte", n)
+		}
+
+		return stack.ByteValue{Value: uint8(n)}, nil
+	case class.CHAR:
+		if n < 0 || n > math.MaxUint16 {
+			return nil, fmt.Errorf("%d does not fit into a char", n)
+		}
+
+		return stack.CharValue{Value: rune(n)}, nil
+	case class.SHORT:
+		if n < math.MinInt16 || n > math.MaxInt16 {
+			return nil, fmt.Errorf("%d does not fit into a short", n)
+		}
+
+		return stack.ShortValue{Value: uint16(n)}, nil
+	case class.INT:
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			return nil, fmt.Errorf("%d does not fit into an int", n)
+		}
+
+		return stack.IntValue{Value: int32(n)}, nil
+	default:
+		return stack.LongValue{Value: uint64(n)}, nil
+	}
+}
+
+// goInt returns the value of any Go integer type.
+func goInt(value any) (int64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+
+		return int64(v.Uint()), true
+	default:
+		return 0, false
+	}
+}
+
+// toGo converts a Java value of type typ to Go: int32, int64, bool, int8, uint16, int16, float32 and
+// float64 for the primitive types, nil, string, typed slices for primitive arrays and []any for
+// other arrays. Other objects can not be converted.
+func (r *Runner) toGo(value stack.Value, typ class.FieldType) (any, error) {
+	switch typ := typ.(type) {
+	case class.BaseType:
+		return toGoPrimitive(value, typ)
+	case class.ObjectType, class.ArrayType:
+		return r.referenceToGo(referenceOf(value))
+	default:
+		return nil, fmt.Errorf("unknown field type %s", typ)
+	}
+}
+
+func toGoPrimitive(value stack.Value, typ class.BaseType) (any, error) {
+	switch v := value.(type) {
+	case stack.FloatValue:
+		return v.Value, nil
+	case stack.DoubleValue:
+		return v.Value, nil
+	}
+
+	n, ok := intOf(value)
+	if !ok {
+		return nil, fmt.Errorf("can not convert %s to %s", value, primitiveDescriptors[string(typ)])
+	}
+
+	switch typ {
+	case class.BOOLEAN:
+		return n != 0, nil
+	case class.BYTE:
+		return int8(n), nil
+	case class.CHAR:
+		return uint16(n), nil
+	case class.SHORT:
+		return int16(n), nil
+	case class.INT:
+		return int32(n), nil
+	case class.LONG:
+		return n, nil
+	default:
+		return nil, fmt.Errorf("can not convert %s to %s", value, primitiveDescriptors[string(typ)])
+	}
+}
+
+func (r *Runner) referenceToGo(ref stack.Reference) (any, error) {
+	if ref == stack.Null {
+		return nil, nil
+	}
+
+	className, err := r.runtimeClassName(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if className == "java/lang/String" {
+		return r.goString(ref)
+	}
+
+	if !strings.HasPrefix(className, "[") {
+		return nil, fmt.Errorf("can not convert an object of %s", dotted(className))
+	}
+
+	array, err := r.heap.GetArray(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	elementType, err := class.NewFieldType(className[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	var items reflect.Value
+	if primitive, ok := elementType.(class.BaseType); ok {
+		items = reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(goZero(primitive))), len(array.items), len(array.items))
+	} else {
+		items = reflect.ValueOf(make([]any, len(array.items)))
+	}
+
+	for i, item := range array.items {
+		converted, err := r.toGo(item, elementType)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+
+		if converted != nil {
+			items.Index(i).Set(reflect.ValueOf(converted))
+		}
+	}
+
+	return items.Interface(), nil
+}
+
+// goZero returns the zero value of the Go type a primitive converts to.
+func goZero(typ class.BaseType) any {
+	switch typ {
+	case class.BOOLEAN:
+		return false
+	case class.BYTE:
+		return int8(0)
+	case class.CHAR:
+		return uint16(0)
+	case class.SHORT:
+		return int16(0)
+	case class.INT:
+		return int32(0)
+	case class.LONG:
+		return int64(0)
+	case class.FLOAT:
+		return float32(0)
+	default:
+		return float64(0)
+	}
+}
+
+// fieldDescriptor returns the descriptor of a field type, e.g. I, Ljava/lang/String; or [[B.
+func fieldDescriptor(typ class.FieldType) string {
+	switch typ := typ.(type) {
+	case class.BaseType:
+		return string(typ)
+	case class.ObjectType:
+		return "L" + typ.ClassName + ";"
+	case class.ArrayType:
+		return "[" + fieldDescriptor(typ.FieldType)
+	default:
+		return ""
+	}
+}
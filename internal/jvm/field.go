@@ -0,0 +1,49 @@
+package jvm
+
+import (
+	"context"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/loader"
+)
+
+// resolveFieldRef resolves the Fieldref at index in the current constant pool
+// to the field's declaring class, name and descriptor.
+func resolveFieldRef(r *Runner, ctx context.Context, index uint16) (loader.FieldKey, *class.Field, error) {
+	pool, err := r.stack.CurrentConstantPool()
+	if err != nil {
+		return loader.FieldKey{}, nil, err
+	}
+
+	fieldRef, err := pool.Ref(index)
+	if err != nil {
+		return loader.FieldKey{}, nil, err
+	}
+
+	classInfo, err := pool.Class(fieldRef.ClassIndex)
+	if err != nil {
+		return loader.FieldKey{}, nil, err
+	}
+
+	className, err := pool.GetUtf8(classInfo.NameIndex)
+	if err != nil {
+		return loader.FieldKey{}, nil, err
+	}
+
+	nameAndType, err := pool.NameAndType(fieldRef.NameAndTypeIndex)
+	if err != nil {
+		return loader.FieldKey{}, nil, err
+	}
+
+	name, err := pool.GetUtf8(nameAndType.NameIndex)
+	if err != nil {
+		return loader.FieldKey{}, nil, err
+	}
+
+	descriptor, err := pool.GetUtf8(nameAndType.DescriptorIndex)
+	if err != nil {
+		return loader.FieldKey{}, nil, err
+	}
+
+	return r.loader.ResolveField(ctx, className, name, descriptor)
+}
@@ -0,0 +1,101 @@
+package jvm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	stdinFd  = 0
+	stdoutFd = 1
+	stderrFd = 2
+)
+
+// Files maps the file descriptors seen by Java to Go readers and writers.
+// 0, 1 and 2 are the configurable standard streams, opened files get the following numbers.
+type Files struct {
+	files map[int32]any
+	next  int32
+}
+
+func NewFiles(stdin io.Reader, stdout io.Writer, stderr io.Writer) Files {
+	return Files{
+		files: map[int32]any{stdinFd: stdin, stdoutFd: stdout, stderrFd: stderr},
+		next:  stderrFd + 1,
+	}
+}
+
+func (f *Files) Set(fd int32, file any) {
+	f.files[fd] = file
+}
+
+// Open opens a file with the flags of os.OpenFile and returns its descriptor.
+func (f *Files) Open(name string, flag int) (int32, error) {
+	file, err := os.OpenFile(name, flag, 0o666)
+	if err != nil {
+		return -1, err
+	}
+
+	fd := f.next
+	f.next++
+	f.files[fd] = file
+	return fd, nil
+}
+
+func (f *Files) Writer(fd int32) (io.Writer, error) {
+	if w, ok := f.files[fd].(io.Writer); ok {
+		return w, nil
+	}
+
+	return nil, fmt.Errorf("file descriptor %d is not open for writing", fd)
+}
+
+func (f *Files) Reader(fd int32) (io.Reader, error) {
+	if r, ok := f.files[fd].(io.Reader); ok {
+		return r, nil
+	}
+
+	return nil, fmt.Errorf("file descriptor %d is not open for reading", fd)
+}
+
+// Available returns the number of bytes that can be read without blocking, if known.
+func (f *Files) Available(fd int32) int64 {
+	file, ok := f.files[fd].(*os.File)
+	if !ok {
+		return 0
+	}
+
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return 0
+	}
+
+	position, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0
+	}
+
+	return max(info.Size()-position, 0)
+}
+
+// Close closes an opened file. The standard streams belong to the embedder and are left open.
+func (f *Files) Close(fd int32) error {
+	file, ok := f.files[fd]
+	if !ok {
+		return errors.New("file descriptor is not open")
+	}
+
+	delete(f.files, fd)
+
+	if fd <= stderrFd {
+		return nil
+	}
+
+	if closer, ok := file.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package jvm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/logger"
+)
+
+type GCStats struct {
+	Pause       time.Duration
+	Before      int
+	After       int
+	FreedItems  int
+	LiveObjects int
+}
+
+func (s GCStats) Reclaimed() int {
+	return s.Before - s.After
+}
+
+// Collect runs a stop-the-world mark and sweep collection.
+// Everything not reachable from the root set or the allocation handles is freed.
+func (h *Heap) Collect(ctx context.Context) GCStats {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.collect(ctx)
+}
+
+// collect is Collect for callers holding the lock.
+func (h *Heap) collect(ctx context.Context) GCStats {
+	log := logger.FromContext(ctx)
+
+	if h.roots == nil {
+		// without roots every item would look unreachable
+		return GCStats{Before: h.used, After: h.used}
+	}
+
+	start := time.Now()
+	stats := GCStats{Before: h.used}
+
+	marked := h.mark()
+
+	for i := 1; i < len(h.items); i++ {
+		item := h.items[i]
+		if item == nil {
+			continue
+		}
+
+		if marked[i] {
+			stats.LiveObjects++
+			continue
+		}
+
+		h.used -= item.size()
+		h.items[i] = nil
+		h.free = append(h.free, stack.Reference(i))
+		stats.FreedItems++
+	}
+
+	h.nextGC = max(defaultGCThreshold, 2*h.used)
+	if h.limit > 0 {
+		h.nextGC = min(h.nextGC, h.limit)
+	}
+
+	if h.budget > 0 {
+		h.nextGC = min(h.nextGC, h.budget)
+	}
+
+	stats.After = h.used
+	stats.Pause = time.Since(start)
+
+	log.Debugw("garbage collection", "before", stats.Before, "after", stats.After, "freed", stats.FreedItems, "pause", stats.Pause)
+
+	if h.gcLog != nil {
+		fmt.Fprintf(h.gcLog, "[gc] GC(%d) Pause Mark-Sweep %dK->%dK %.3fms, reclaimed %d bytes in %d items\n",
+			h.gcCount,
+			stats.Before/1024,
+			stats.After/1024,
+			float64(stats.Pause.Microseconds())/1000,
+			stats.Reclaimed(),
+			stats.FreedItems,
+		)
+	}
+
+	h.gcCount++
+
+	return stats
+}
+
+func (h *Heap) mark() []bool {
+	marked := make([]bool, len(h.items))
+	worklist := make([]stack.Reference, 0, 64)
+
+	push := func(ref stack.Reference) {
+		if ref == stack.Null || int(ref) >= len(h.items) || marked[ref] || h.items[ref] == nil {
+			return
+		}
+
+		marked[ref] = true
+		worklist = append(worklist, ref)
+	}
+
+	visit := func(value stack.Value) {
+		switch v := value.(type) {
+		case stack.ReferenceValue:
+			push(v.Value)
+		case stack.ClassReferenceValue:
+			push(v.Value)
+		}
+	}
+
+	h.roots.VisitRoots(visit)
+	for _, ref := range h.handles {
+		push(ref)
+	}
+
+	for len(worklist) > 0 {
+		ref := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+		h.items[ref].visitValues(visit)
+	}
+
+	return marked
+}
@@ -0,0 +1,187 @@
+package jvm
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+	"github.com/m4tthewde/swell/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type testRoots []stack.Value
+
+func (t *testRoots) VisitRoots(visit func(stack.Value)) {
+	for _, value := range *t {
+		visit(value)
+	}
+}
+
+func TestCollectFreesUnreachable(t *testing.T) {
+	ctx := logger.OnContext(t.Context(), zap.NewNop().Sugar())
+	roots := &testRoots{}
+	heap := NewHeap()
+	heap.roots = roots
+	layout := pointLayout(t)
+
+	rooted, err := heap.AllocateObject(ctx, layout)
+	assert.Nil(t, err)
+
+	reachable, err := heap.AllocateArray(ctx, "[I", []stack.Value{stack.IntValue{Value: 1}})
+	assert.Nil(t, err)
+
+	garbage, err := heap.AllocateObject(ctx, layout)
+	assert.Nil(t, err)
+
+	err = heap.SetField(rooted, loader.FieldKey{Class: "Point", Name: "x", Descriptor: "I"}, stack.ReferenceValue{Value: reachable})
+	assert.Nil(t, err)
+
+	*roots = append(*roots, stack.ReferenceValue{Value: rooted})
+	heap.ReleaseHandles(0)
+
+	var log bytes.Buffer
+	heap.SetGCLog(&log)
+
+	stats := heap.Collect(ctx)
+	assert.Equal(t, 1, stats.FreedItems)
+	assert.Equal(t, 2, stats.LiveObjects)
+	assert.Equal(t, (&Object{fields: make([]stack.Value, 2)}).size(), stats.Reclaimed())
+	assert.Contains(t, log.String(), "[gc] GC(0) Pause Mark-Sweep")
+
+	_, err = heap.GetObject(garbage)
+	assert.NotNil(t, err)
+
+	_, err = heap.GetArray(reachable)
+	assert.Nil(t, err)
+
+	// the freed slot is reused
+	ref, err := heap.AllocateObject(ctx, layout)
+	assert.Nil(t, err)
+	assert.Equal(t, garbage, ref)
+}
+
+func TestCollectKeepsHandles(t *testing.T) {
+	ctx := logger.OnContext(t.Context(), zap.NewNop().Sugar())
+	heap := NewHeap()
+	heap.roots = &testRoots{}
+
+	mark := heap.HandleMark()
+	ref, err := heap.AllocateObject(ctx, pointLayout(t))
+	assert.Nil(t, err)
+
+	heap.Collect(ctx)
+	_, err = heap.GetObject(ref)
+	assert.Nil(t, err)
+
+	heap.ReleaseHandles(mark)
+	heap.Collect(ctx)
+	_, err = heap.GetObject(ref)
+	assert.NotNil(t, err)
+}
+
+func TestOutOfMemory(t *testing.T) {
+	ctx := logger.OnContext(t.Context(), zap.NewNop().Sugar())
+	heap := NewHeap()
+	heap.roots = &testRoots{}
+	heap.SetLimit(1024)
+
+	_, err := heap.AllocateDefaultArray(ctx, "[I", 100, stack.IntValue{Value: 0})
+	assert.Nil(t, err)
+
+	_, err = heap.AllocateDefaultArray(ctx, "[I", 1000, stack.IntValue{Value: 0})
+	var throwable *ThrowableError
+	assert.ErrorAs(t, err, &throwable)
+	assert.Equal(t, "java/lang/OutOfMemoryError", throwable.ClassName)
+
+	// once the first array is unreachable there is room again
+	heap.ReleaseHandles(0)
+	_, err = heap.AllocateDefaultArray(ctx, "[I", 200, stack.IntValue{Value: 0})
+	assert.Nil(t, err)
+}
+
+// collectOnThrow collects the heap when an exception is thrown, before its handler has been resolved.
+type collectOnThrow struct {
+	Tracer
+	r   *Runner
+	ctx context.Context
+}
+
+func (c *collectOnThrow) Throw(event ExceptionEvent) {
+	c.r.heap.Collect(c.ctx)
+}
+
+func TestCollectKeepsThrownException(t *testing.T) {
+	main := newTestClass("Main", "java/lang/Object")
+	main.method(class.AccStatic, "thrower", "()V", throwCode(main, "java/lang/ArithmeticException")...)
+	caller := append([]byte{InvokeStaticOp}, u2(main.ref("Main", "thrower", "()V"))...)
+	caller = append(caller, AReturn)
+	main.method(class.AccStatic, "caught", "()Ljava/lang/Object;", caller...).
+		catch(0, 3, 3, "java/lang/RuntimeException")
+
+	r, ctx := newTestRunner(t, append(throwableClasses(), main.build())...)
+	WithTracer(&collectOnThrow{Tracer: NewTextTracer(io.Discard), r: r, ctx: ctx})(r)
+
+	result, err := invokeTestMethod(t, ctx, r, "Main", "caught", "()Ljava/lang/Object;")
+	assert.NoError(t, err)
+
+	object, err := r.heap.GetObject(result.(stack.ReferenceValue).Value)
+	assert.NoError(t, err)
+	assert.Equal(t, "java/lang/ArithmeticException", object.ClassName())
+}
+
+// collectOnAllocate also collects the heap after every allocation, so anything the VM holds
+// unrooted between two allocations is freed.
+type collectOnAllocate struct {
+	collectOnThrow
+}
+
+func (c *collectOnAllocate) Allocation(event AllocationEvent) {
+	c.r.heap.Collect(c.ctx)
+}
+
+func TestCollectOnAllocateWhileThrowing(t *testing.T) {
+	main := newTestClass("Main", "java/lang/Object")
+	main.method(class.AccStatic, "thrower", "()V", throwCode(main, "java/lang/ArithmeticException")...)
+	caller := append([]byte{InvokeStaticOp}, u2(main.ref("Main", "thrower", "()V"))...)
+	caller = append(caller, AReturn)
+	main.method(class.AccStatic, "caught", "()Ljava/lang/Object;", caller...).
+		catch(0, 3, 3, "java/lang/RuntimeException")
+
+	r, ctx := newTestRunner(t, append(throwableClasses(), main.build())...)
+	WithTracer(&collectOnAllocate{collectOnThrow{Tracer: NewTextTracer(io.Discard), r: r, ctx: ctx}})(r)
+
+	result, err := invokeTestMethod(t, ctx, r, "Main", "caught", "()Ljava/lang/Object;")
+	assert.NoError(t, err)
+
+	object, err := r.heap.GetObject(result.(stack.ReferenceValue).Value)
+	assert.NoError(t, err)
+	assert.Equal(t, "java/lang/ArithmeticException", object.ClassName())
+}
+
+func TestCollectOnAllocateWhileInitializing(t *testing.T) {
+	bad := newTestClass("Bad", "java/lang/Object")
+	bad.method(class.AccStatic, "<clinit>", "()V", throwCode(bad, "java/lang/ArithmeticException")...)
+	main := newTestClass("Main", "java/lang/Object")
+	main.method(class.AccStatic, "run", "()V", append(append([]byte{NewOp}, u2(main.classRef("Bad"))...), RetOp)...)
+
+	r, ctx := newTestRunner(t, append(throwableClasses(), bad.build(), main.build())...)
+	WithTracer(&collectOnAllocate{collectOnThrow{Tracer: NewTextTracer(io.Discard), r: r, ctx: ctx}})(r)
+
+	_, err := invokeTestMethod(t, ctx, r, "Main", "run", "()V")
+	throwable, ok := err.(*ThrowableError)
+	assert.True(t, ok)
+	assert.Equal(t, "java/lang/ExceptionInInitializerError", throwable.ClassName)
+
+	wrapper, err := r.heap.GetObject(throwable.Ref)
+	assert.NoError(t, err)
+	assert.Equal(t, "java/lang/ExceptionInInitializerError", wrapper.ClassName())
+
+	cause, err := r.heap.GetObject(throwable.Cause.Ref)
+	assert.NoError(t, err)
+	assert.Equal(t, "java/lang/ArithmeticException", cause.ClassName())
+}
@@ -11,37 +11,7 @@ func getField(r *Runner, ctx context.Context, code []byte) error {
 	index := (uint16(code[r.pc+1])<<8 | uint16(code[r.pc+2]))
 	r.pc += 3
 
-	pool, err := r.stack.CurrentConstantPool()
-	if err != nil {
-		return err
-	}
-
-	fieldRef, err := pool.Ref(index)
-	if err != nil {
-		return err
-	}
-
-	classInfo, err := pool.Class(fieldRef.ClassIndex)
-	if err != nil {
-		return err
-	}
-
-	className, err := pool.GetUtf8(classInfo.NameIndex)
-	if err != nil {
-		return err
-	}
-
-	_, err = r.loader.Load(ctx, className)
-	if err != nil {
-		return err
-	}
-
-	nameAndType, err := pool.NameAndType(fieldRef.NameAndTypeIndex)
-	if err != nil {
-		return err
-	}
-
-	fieldName, err := pool.GetUtf8(nameAndType.NameIndex)
+	key, _, err := resolveFieldRef(r, ctx, index)
 	if err != nil {
 		return err
 	}
@@ -54,12 +24,12 @@ func getField(r *Runner, ctx context.Context, code []byte) error {
 	objectRef := operands[0]
 
 	if reference, ok := objectRef.(stack.ReferenceValue); ok {
-		object, err := r.heap.GetObject(*reference.Value)
+		object, err := r.heap.GetObject(reference.Value)
 		if err != nil {
 			return err
 		}
 
-		fieldValue, err := object.GetFieldValue(fieldName)
+		fieldValue, err := object.GetFieldValue(key)
 		if err != nil {
 			return err
 		}
@@ -68,12 +38,12 @@ func getField(r *Runner, ctx context.Context, code []byte) error {
 	}
 
 	if reference, ok := objectRef.(stack.ClassReferenceValue); ok {
-		object, err := r.heap.GetObject(*reference.Value)
+		object, err := r.heap.GetObject(reference.Value)
 		if err != nil {
 			return err
 		}
 
-		fieldValue, err := object.GetFieldValue(fieldName)
+		fieldValue, err := object.GetFieldValue(key)
 		if err != nil {
 			return err
 		}
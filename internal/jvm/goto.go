@@ -1,7 +1,8 @@
 package jvm
 
 func goTo(r *Runner, code []byte) error {
-	index := (uint16(code[r.pc+1])<<8 | uint16(code[r.pc+2]))
-	r.pc += int(index)
+	// the offset is signed, loops branch backwards
+	offset := int16(uint16(code[r.pc+1])<<8 | uint16(code[r.pc+2]))
+	r.pc += int(offset)
 	return nil
 }
@@ -3,124 +3,417 @@ package jvm
 import (
 	"context"
 	"fmt"
+	"io"
+	"slices"
+	"sync"
 
-	"github.com/google/uuid"
-	"github.com/m4tthewde/swell/internal/class"
 	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
 	"github.com/m4tthewde/swell/internal/logger"
 )
 
 type HeapItem interface {
 	IsHeapItem()
+	// size is the estimated number of bytes the item occupies, used for heap accounting.
+	size() int
+	visitValues(visit func(stack.Value))
 }
 
+const headerSize = 16
+
 type Object struct {
-	className string
-	fields    map[string]stack.Value
+	layout *loader.Layout
+	fields []stack.Value
+	// hash is the identity hash code, 0 until it is first requested
+	hash int32
+	// monitor is created the first time the object is synchronized on
+	monitor *monitor
+}
+
+func (o *Object) IsHeapItem() {}
+
+func (o *Object) size() int {
+	return headerSize + 8*len(o.fields)
 }
 
-func (o Object) IsHeapItem() {}
+func (o *Object) visitValues(visit func(stack.Value)) {
+	for _, value := range o.fields {
+		visit(value)
+	}
+}
 
-func newObject(name string, fields map[string]stack.Value) Object {
-	return Object{className: name, fields: fields}
+func (o *Object) ClassName() string {
+	return o.layout.ClassName
 }
 
-func (o *Object) GetFieldValue(name string) (stack.Value, error) {
-	value, ok := o.fields[name]
+func (o *Object) GetFieldValue(key loader.FieldKey) (stack.Value, error) {
+	index, ok := o.layout.Index(key)
 	if !ok {
-		return nil, fmt.Errorf("field with name '%s' not found on %s", name, o.className)
+		return nil, fmt.Errorf("field %s.%s not found on %s", key.Class, key.Name, o.ClassName())
 	}
 
-	return value, nil
+	return o.fields[index], nil
+}
+
+func (o *Object) SetFieldValue(key loader.FieldKey, value stack.Value) error {
+	index, ok := o.layout.Index(key)
+	if !ok {
+		return fmt.Errorf("field %s.%s not found on %s", key.Class, key.Name, o.ClassName())
+	}
+
+	o.fields[index] = value
+	return nil
 }
 
 type Array struct {
-	items []stack.Value
+	className string
+	items     []stack.Value
+	hash      int32
+	monitor   *monitor
+}
+
+func (a *Array) IsHeapItem() {}
+
+// ClassName returns the name of the array class, e.g. [I or [Ljava/lang/String;.
+func (a *Array) ClassName() string {
+	return a.className
+}
+
+func (a *Array) size() int {
+	if len(a.items) == 0 {
+		return headerSize
+	}
+
+	return headerSize + len(a.items)*valueSize(a.items[0])
 }
 
-func (a Array) IsHeapItem() {}
-func (a Array) IsValue()    {}
-func (a Array) String() string {
+func (a *Array) visitValues(visit func(stack.Value)) {
+	for _, value := range a.items {
+		visit(value)
+	}
+}
+
+func valueSize(value stack.Value) int {
+	switch value.(type) {
+	case stack.BooleanValue, stack.ByteValue:
+		return 1
+	case stack.CharValue, stack.ShortValue:
+		return 2
+	case stack.LongValue, stack.DoubleValue:
+		return 8
+	default:
+		return 4
+	}
+}
+
+func (a *Array) String() string {
 	return "Array[...]"
 }
 
+// defaultGCThreshold is the heap usage at which the first collection is triggered.
+const defaultGCThreshold = 16 << 20
+
+// RootSet enumerates the values outside the heap that keep heap items alive.
+type RootSet interface {
+	VisitRoots(visit func(stack.Value))
+}
+
+// Heap stores objects and arrays in a table indexed by stack.Reference.
+// Slot 0 is never used so that the zero reference can stand for null.
+// Slots of collected items are reused by later allocations.
+// The table is safe for concurrent use, the fields of the items are guarded by the interpreter lock.
 type Heap struct {
-	items map[uuid.UUID]HeapItem
+	lock  sync.RWMutex
+	items []HeapItem
+	free  []stack.Reference
+	// handles keep items allocated by the instructions currently executing alive,
+	// until they are reachable from the operand stack or local variables.
+	handles []stack.Reference
+	roots   RootSet
+	used    int
+	limit   int
+	// budget is the hard limit of WithHeapBudget, exceeding it stops the VM instead of raising an OutOfMemoryError
+	budget  int
+	nextGC  int
+	gcCount int
+	gcLog   io.Writer
+	// hashState is the state of the xorshift generator for identity hash codes
+	hashState uint32
+	// onOutOfMemory is called once, when the first OutOfMemoryError is raised
+	onOutOfMemory func(ctx context.Context)
+	// onAllocate is called after an item has been allocated, without the lock
+	onAllocate func(ctx context.Context, ref stack.Reference, item HeapItem)
 }
 
 func NewHeap() Heap {
-	return Heap{items: make(map[uuid.UUID]HeapItem, 0)}
+	return Heap{items: make([]HeapItem, 1, 1024), nextGC: defaultGCThreshold, hashState: 0x9e3779b9}
 }
 
-func (h *Heap) AllocateObject(ctx context.Context, c *class.Class) (*uuid.UUID, error) {
-	log := logger.FromContext(ctx)
+// SetLimit sets the maximum number of bytes the heap may occupy, 0 means unlimited.
+func (h *Heap) SetLimit(limit int) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
 
-	fields := make(map[string]stack.Value)
+	h.limit = limit
+	if limit > 0 && limit < h.nextGC {
+		h.nextGC = limit
+	}
+}
 
-	for _, field := range c.Fields {
-		name, err := c.ConstantPool.GetUtf8(field.NameIndex)
-		if err != nil {
-			return nil, err
-		}
+// SetBudget sets the number of bytes after which allocations fail with a BudgetExceededError, 0 means unlimited.
+func (h *Heap) SetBudget(budget int) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
 
-		descriptor, err := c.ConstantPool.GetUtf8(field.DescriptorIndex)
-		if err != nil {
-			return nil, err
-		}
+	h.budget = budget
+	if budget > 0 && budget < h.nextGC {
+		h.nextGC = budget
+	}
+}
 
-		fieldType, err := class.NewFieldType(descriptor)
-		if err != nil {
-			return nil, err
-		}
+func (h *Heap) SetGCLog(w io.Writer) {
+	h.gcLog = w
+}
+
+func (h *Heap) Used() int {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return h.used
+}
+
+func (h *Heap) allocate(ctx context.Context, item HeapItem) (stack.Reference, error) {
+	ref, onOutOfMemory, err := h.insert(ctx, item)
+	if err != nil {
+		return stack.Null, err
+	}
 
-		value, err := stack.DefaultValue(fieldType)
-		if err != nil {
-			return nil, err
+	if ref != stack.Null {
+		if h.onAllocate != nil {
+			h.onAllocate(ctx, ref, item)
 		}
 
-		fields[name] = value
+		return ref, nil
 	}
 
-	id := uuid.New()
-	h.items[id] = newObject(c.Name, fields)
+	// called without the lock, the heap dump reads the heap
+	if onOutOfMemory != nil {
+		onOutOfMemory(ctx)
+	}
+
+	return stack.Null, newThrowableError("java/lang/OutOfMemoryError", "Java heap space")
+}
+
+// insert stores item in a free slot, collecting first if the heap has grown enough.
+// It returns the null reference if the heap is full, along with the out of memory callback on the first time.
+func (h *Heap) insert(ctx context.Context, item HeapItem) (stack.Reference, func(ctx context.Context), error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
 
-	log.Infow("allocated object", "id", id, "className", c.Name)
+	size := item.size()
+
+	if h.used+size > h.nextGC {
+		h.collect(ctx)
+	}
+
+	if h.budget > 0 && h.used+size > h.budget {
+		return stack.Null, nil, &BudgetExceededError{Budget: BudgetHeap, Limit: int64(h.budget)}
+	}
 
-	return &id, nil
+	if h.limit > 0 && h.used+size > h.limit {
+		onOutOfMemory := h.onOutOfMemory
+		h.onOutOfMemory = nil
+		return stack.Null, onOutOfMemory, nil
+	}
+
+	var ref stack.Reference
+	if len(h.free) > 0 {
+		ref = h.free[len(h.free)-1]
+		h.free = h.free[:len(h.free)-1]
+		h.items[ref] = item
+	} else {
+		ref = stack.Reference(len(h.items))
+		h.items = append(h.items, item)
+	}
+
+	h.used += size
+	h.handles = append(h.handles, ref)
+	return ref, nil, nil
 }
 
-func (h *Heap) GetObject(id uuid.UUID) (*Object, error) {
-	item, ok := h.items[id]
-	if !ok {
-		return nil, fmt.Errorf("object with id %s not found", id)
+// HandleMark returns the current position in the handle stack,
+// to be passed to ReleaseHandles once an instruction has finished.
+func (h *Heap) HandleMark() int {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return len(h.handles)
+}
+
+// AddHandle keeps ref alive until the handles of the current instruction are released.
+func (h *Heap) AddHandle(ref stack.Reference) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.handles = append(h.handles, ref)
+}
+
+func (h *Heap) ReleaseHandles(mark int) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if mark < len(h.handles) {
+		h.handles = h.handles[:mark]
+	}
+}
+
+// swapHandles installs the handles of the thread taking the interpreter lock and returns the previous ones.
+func (h *Heap) swapHandles(handles []stack.Reference) []stack.Reference {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	previous := h.handles
+	h.handles = handles
+	return previous
+}
+
+func (h *Heap) get(ref stack.Reference) (HeapItem, error) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return h.item(ref)
+}
+
+// item returns the item at ref, the caller has to hold the lock.
+func (h *Heap) item(ref stack.Reference) (HeapItem, error) {
+	if ref == stack.Null {
+		return nil, fmt.Errorf("null reference")
+	}
+
+	if int(ref) >= len(h.items) || h.items[ref] == nil {
+		return nil, fmt.Errorf("no heap item at %s", ref)
+	}
+
+	return h.items[ref], nil
+}
+
+func (h *Heap) AllocateObject(ctx context.Context, layout *loader.Layout) (stack.Reference, error) {
+	log := logger.FromContext(ctx)
+
+	ref, err := h.allocate(ctx, &Object{layout: layout, fields: layout.NewFields()})
+	if err != nil {
+		return stack.Null, err
+	}
+
+	log.Debugw("allocated object", "ref", ref, "className", layout.ClassName)
+
+	return ref, nil
+}
+
+// Clone allocates a shallow copy of an object or array. The copy gets its own identity hash code.
+func (h *Heap) Clone(ctx context.Context, ref stack.Reference) (stack.Reference, error) {
+	item, err := h.get(ref)
+	if err != nil {
+		return stack.Null, err
+	}
+
+	switch item := item.(type) {
+	case *Object:
+		return h.allocate(ctx, &Object{layout: item.layout, fields: slices.Clone(item.fields)})
+	case *Array:
+		return h.allocate(ctx, &Array{className: item.className, items: slices.Clone(item.items)})
+	default:
+		return stack.Null, fmt.Errorf("can not clone %T", item)
+	}
+}
+
+func (h *Heap) GetObject(ref stack.Reference) (*Object, error) {
+	item, err := h.get(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if object, ok := item.(*Object); ok {
+		return object, nil
 	}
 
-	if object, ok := item.(Object); ok {
-		return &object, nil
+	return nil, fmt.Errorf("object with ref %s not found", ref)
+}
+
+func (h *Heap) AllocateDefaultArray(ctx context.Context, className string, size int, defaultValue stack.Value) (stack.Reference, error) {
+	items := make([]stack.Value, size)
+	for i := range items {
+		items[i] = defaultValue
+	}
+
+	return h.AllocateArray(ctx, className, items)
+}
+
+func (h *Heap) AllocateArray(ctx context.Context, className string, items []stack.Value) (stack.Reference, error) {
+	log := logger.FromContext(ctx)
+
+	ref, err := h.allocate(ctx, &Array{className: className, items: items})
+	if err != nil {
+		return stack.Null, err
 	}
 
-	return nil, fmt.Errorf("object with id %s not found", id)
+	log.Debugw("allocated array", "ref", ref, "className", className, "length", len(items))
+
+	return ref, nil
 }
 
-func (h *Heap) AllocateDefaultArray(ctx context.Context, size int, defaultValue stack.Value) (*uuid.UUID, error) {
-	items := make([]stack.Value, 0)
-	for range size {
-		items = append(items, defaultValue)
+func (h *Heap) GetArray(ref stack.Reference) (*Array, error) {
+	item, err := h.get(ref)
+	if err != nil {
+		return nil, err
 	}
 
-	id := uuid.New()
-	h.items[id] = Array{items: items}
+	if array, ok := item.(*Array); ok {
+		return array, nil
+	}
 
-	return &id, nil
+	return nil, fmt.Errorf("array with ref %s not found", ref)
 }
 
-func (h *Heap) SetField(id uuid.UUID, fieldName string, value stack.Value) error {
-	obj, err := h.GetObject(id)
+func (h *Heap) SetField(ref stack.Reference, key loader.FieldKey, value stack.Value) error {
+	obj, err := h.GetObject(ref)
 	if err != nil {
 		return err
 	}
 
-	obj.fields[fieldName] = value
-	h.items[id] = *obj
-	return nil
+	return obj.SetFieldValue(key, value)
+}
+
+// IdentityHash returns the identity hash code of the item at ref, as used by Object.hashCode.
+// It is generated on first use and stays the same for the lifetime of the item.
+func (h *Heap) IdentityHash(ref stack.Reference) (int32, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	item, err := h.item(ref)
+	if err != nil {
+		return 0, err
+	}
+
+	var hash *int32
+	switch item := item.(type) {
+	case *Object:
+		hash = &item.hash
+	case *Array:
+		hash = &item.hash
+	default:
+		return 0, fmt.Errorf("no identity hash for %T", item)
+	}
+
+	for *hash == 0 {
+		// Marsaglia's xorshift, like HotSpot, limited to 31 bits
+		x := h.hashState
+		x ^= x << 13
+		x ^= x >> 17
+		x ^= x << 5
+		h.hashState = x
+		*hash = int32(x & 0x7fffffff)
+	}
+
+	return *hash, nil
 }
@@ -0,0 +1,143 @@
+package jvm
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+	"github.com/m4tthewde/swell/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func pointLayout(t testing.TB) *loader.Layout {
+	c := &class.Class{
+		Name: "Point",
+		ConstantPool: class.ConstantPool{
+			Infos: []class.CpInfo{
+				class.ReservedInfo{},
+				class.Utf8Info{Content: "x"},
+				class.Utf8Info{Content: "I"},
+				class.Utf8Info{Content: "y"},
+			},
+		},
+		Fields: []class.Field{
+			{NameIndex: 1, DescriptorIndex: 2},
+			{NameIndex: 3, DescriptorIndex: 2},
+		},
+	}
+
+	layout, err := loader.NewLayout(c, nil)
+	assert.Nil(t, err)
+
+	return layout
+}
+
+func TestHeapObjectFields(t *testing.T) {
+	ctx := logger.OnContext(t.Context(), zap.NewNop().Sugar())
+	heap := NewHeap()
+
+	ref, err := heap.AllocateObject(ctx, pointLayout(t))
+	assert.Nil(t, err)
+	assert.NotEqual(t, stack.Null, ref)
+
+	object, err := heap.GetObject(ref)
+	assert.Nil(t, err)
+
+	err = object.SetFieldValue(loader.FieldKey{Class: "Point", Name: "y", Descriptor: "I"}, stack.IntValue{Value: 7})
+	assert.Nil(t, err)
+
+	object, err = heap.GetObject(ref)
+	assert.Nil(t, err)
+
+	x, err := object.GetFieldValue(loader.FieldKey{Class: "Point", Name: "x", Descriptor: "I"})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.IntValue{Value: 0}, x)
+
+	y, err := object.GetFieldValue(loader.FieldKey{Class: "Point", Name: "y", Descriptor: "I"})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.IntValue{Value: 7}, y)
+
+	_, err = heap.GetObject(stack.Null)
+	assert.NotNil(t, err)
+}
+
+func TestHeapArray(t *testing.T) {
+	ctx := logger.OnContext(t.Context(), zap.NewNop().Sugar())
+	heap := NewHeap()
+
+	ref, err := heap.AllocateDefaultArray(ctx, "[B", 3, stack.ByteValue{Value: 0})
+	assert.Nil(t, err)
+
+	array, err := heap.GetArray(ref)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(array.items))
+
+	_, err = heap.GetObject(ref)
+	assert.NotNil(t, err)
+}
+
+func BenchmarkHeapAllocateObject(b *testing.B) {
+	ctx := logger.OnContext(b.Context(), zap.NewNop().Sugar())
+	layout := pointLayout(b)
+	heap := NewHeap()
+	x := loader.FieldKey{Class: "Point", Name: "x", Descriptor: "I"}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		ref, err := heap.AllocateObject(ctx, layout)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		err = heap.SetField(ref, x, stack.IntValue{Value: 1})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestHeapConcurrentAllocation(t *testing.T) {
+	ctx := logger.OnContext(t.Context(), zap.NewNop().Sugar())
+	heap := NewHeap()
+	layout := pointLayout(t)
+
+	const goroutines, allocations = 8, 100
+	refs := make([][]stack.Reference, goroutines)
+
+	var wg sync.WaitGroup
+	for i := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for range allocations {
+				ref, err := heap.AllocateObject(ctx, layout)
+				assert.Nil(t, err)
+
+				_, err = heap.IdentityHash(ref)
+				assert.Nil(t, err)
+
+				_, err = heap.GetObject(ref)
+				assert.Nil(t, err)
+
+				refs[i] = append(refs[i], ref)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	seen := make(map[stack.Reference]bool)
+	for _, allocated := range refs {
+		for _, ref := range allocated {
+			assert.False(t, seen[ref])
+			seen[ref] = true
+		}
+	}
+
+	assert.Equal(t, goroutines*allocations, len(seen))
+	assert.Equal(t, goroutines*allocations*(headerSize+16), heap.Used())
+}
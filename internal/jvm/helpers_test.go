@@ -0,0 +1,177 @@
+package jvm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// testClass builds classes in memory, so tests can run bytecode without a JDK.
+type testClass struct {
+	c     *class.Class
+	utf8s map[string]uint16
+}
+
+func newTestClass(name string, super string) *testClass {
+	b := &testClass{
+		c: &class.Class{
+			Name:         name,
+			AccessFlags:  class.AccPublic,
+			ConstantPool: class.ConstantPool{Infos: []class.CpInfo{class.ReservedInfo{}}},
+		},
+		utf8s: make(map[string]uint16),
+	}
+
+	if super != "" {
+		b.c.SuperClass = b.classRef(super)
+	}
+
+	return b
+}
+
+func (b *testClass) add(info class.CpInfo) uint16 {
+	b.c.ConstantPool.Infos = append(b.c.ConstantPool.Infos, info)
+	return uint16(len(b.c.ConstantPool.Infos) - 1)
+}
+
+func (b *testClass) utf8(s string) uint16 {
+	if index, ok := b.utf8s[s]; ok {
+		return index
+	}
+
+	index := b.add(class.Utf8Info{Content: s})
+	b.utf8s[s] = index
+	return index
+}
+
+func (b *testClass) classRef(name string) uint16 {
+	return b.add(class.ClassInfo{NameIndex: b.utf8(name)})
+}
+
+func (b *testClass) ref(className string, name string, descriptor string) uint16 {
+	nameAndType := b.add(class.NameAndTypeInfo{NameIndex: b.utf8(name), DescriptorIndex: b.utf8(descriptor)})
+	return b.add(class.RefInfo{ClassIndex: b.classRef(className), NameAndTypeIndex: nameAndType})
+}
+
+func (b *testClass) interfaces(names ...string) *testClass {
+	for _, name := range names {
+		b.c.Interfaces = append(b.c.Interfaces, b.classRef(name))
+	}
+
+	return b
+}
+
+func (b *testClass) flags(accessFlags uint16) *testClass {
+	b.c.AccessFlags = accessFlags
+	return b
+}
+
+func (b *testClass) field(accessFlags uint16, name string, descriptor string, attributes ...class.Attribute) *testClass {
+	b.c.Fields = append(b.c.Fields, class.Field{
+		AccessFlags:     accessFlags,
+		NameIndex:       b.utf8(name),
+		DescriptorIndex: b.utf8(descriptor),
+		Attributes:      attributes,
+	})
+
+	return b
+}
+
+func (b *testClass) method(accessFlags uint16, name string, descriptor string, code ...byte) *testClass {
+	attributes := []class.Attribute{}
+	if accessFlags&class.AccNative == 0 {
+		attributes = append(attributes, class.CodeAttribute{MaxStack: 16, MaxLocals: 16, Code: code})
+	}
+
+	b.c.Methods = append(b.c.Methods, class.Method{
+		AccessFlags:     accessFlags,
+		NameIndex:       b.utf8(name),
+		DescriptorIndex: b.utf8(descriptor),
+		Attributes:      attributes,
+	})
+
+	return b
+}
+
+// catch adds an exception handler to the last method, an empty catchType catches everything.
+func (b *testClass) catch(startPc uint16, endPc uint16, handlerPc uint16, catchType string) *testClass {
+	method := &b.c.Methods[len(b.c.Methods)-1]
+	code := method.Attributes[0].(class.CodeAttribute)
+
+	exception := class.Exception{StartPc: startPc, EndPc: endPc, HandlerPc: handlerPc}
+	if catchType != "" {
+		exception.CatchType = b.classRef(catchType)
+	}
+
+	code.Exceptions = append(code.Exceptions, exception)
+	method.Attributes[0] = code
+	return b
+}
+
+func (b *testClass) build() *class.Class {
+	return b.c
+}
+
+func u2(index uint16) []byte {
+	return []byte{byte(index >> 8), byte(index)}
+}
+
+func testContext(t testing.TB) context.Context {
+	return logger.OnContext(context.Background(), zap.NewNop().Sugar())
+}
+
+// newTestRunner creates a runner with a minimal java/lang/Object and the given classes.
+func newTestRunner(t testing.TB, classes ...*class.Class) (*Runner, context.Context) {
+	ctx := testContext(t)
+	r := NewRunner(nil)
+	// the test drives the VM directly, like a call from the host it holds the interpreter lock
+	r.acquire()
+
+	object := newTestClass("java/lang/Object", "").
+		method(class.AccPublic, "<init>", "()V", RetOp).
+		method(class.AccNative, "clone", "()Ljava/lang/Object;").
+		build()
+
+	for _, c := range append([]*class.Class{object}, classes...) {
+		assert.Nil(t, r.loader.Define(ctx, c))
+	}
+
+	return r, ctx
+}
+
+// invokeTestMethod runs a static method and returns its result, nil for void methods.
+func invokeTestMethod(t testing.TB, ctx context.Context, r *Runner, className string, name string, descriptor string, args ...stack.Value) (stack.Value, error) {
+	r.stack.Push("test", class.Method{}, class.ConstantPool{Infos: []class.CpInfo{class.Utf8Info{Content: "test"}}}, nil)
+	defer func() {
+		assert.Nil(t, r.stack.Pop())
+	}()
+
+	c, err := r.loader.Load(ctx, className)
+	assert.Nil(t, err)
+
+	method, ok, err := c.GetMethod(name, descriptor)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	code, err := method.CodeAttribute()
+	assert.Nil(t, err)
+
+	err = r.runMethod(ctx, code, *c, *method, args)
+	if err != nil {
+		return nil, err
+	}
+
+	operands, err := r.stack.Operands()
+	assert.Nil(t, err)
+
+	if len(operands) == 0 {
+		return nil, nil
+	}
+
+	return operands[len(operands)-1], nil
+}
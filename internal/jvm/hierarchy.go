@@ -0,0 +1,126 @@
+package jvm
+
+import (
+	"context"
+	"strings"
+)
+
+// isSubclassOf reports whether className is superName or one of its subclasses.
+func (r *Runner) isSubclassOf(ctx context.Context, className string, superName string) (bool, error) {
+	for {
+		if className == superName {
+			return true, nil
+		}
+
+		c, err := r.loader.Load(ctx, className)
+		if err != nil {
+			return false, err
+		}
+
+		name, ok, err := c.SuperClassName()
+		if err != nil {
+			return false, err
+		}
+
+		if !ok {
+			return false, nil
+		}
+
+		className = name
+	}
+}
+
+// isAssignable reports whether a value of type from can be stored where type to is expected (JVMS §6.5 checkcast).
+// Both are binary class names, array class names like [I or primitive type names.
+func (r *Runner) isAssignable(ctx context.Context, from string, to string) (bool, error) {
+	if from == to {
+		return true, nil
+	}
+
+	if !isClassName(from) || !isClassName(to) {
+		return r.isArrayAssignable(ctx, from, to)
+	}
+
+	target, err := r.loader.Load(ctx, to)
+	if err != nil {
+		return false, err
+	}
+
+	if target.IsInterface() {
+		return r.implements(ctx, from, to)
+	}
+
+	return r.isSubclassOf(ctx, from, to)
+}
+
+// isArrayAssignable handles isAssignable when either type is an array or a primitive type.
+func (r *Runner) isArrayAssignable(ctx context.Context, from string, to string) (bool, error) {
+	if !strings.HasPrefix(from, "[") {
+		return false, nil
+	}
+
+	switch to {
+	case "java/lang/Object", "java/lang/Cloneable", "java/io/Serializable":
+		return true, nil
+	}
+
+	if !strings.HasPrefix(to, "[") {
+		return false, nil
+	}
+
+	fromComponent, toComponent := componentName(from), componentName(to)
+	if _, ok := primitiveNames[fromComponent]; ok {
+		return false, nil
+	}
+
+	if _, ok := primitiveNames[toComponent]; ok {
+		return false, nil
+	}
+
+	return r.isAssignable(ctx, fromComponent, toComponent)
+}
+
+// implements reports whether className or one of its superclasses implements the interface, directly or
+// through a superinterface.
+func (r *Runner) implements(ctx context.Context, className string, interfaceName string) (bool, error) {
+	queue := []string{className}
+	seen := map[string]bool{}
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		if name == interfaceName {
+			return true, nil
+		}
+
+		if seen[name] {
+			continue
+		}
+
+		seen[name] = true
+
+		c, err := r.loader.Load(ctx, name)
+		if err != nil {
+			return false, err
+		}
+
+		interfaces, err := c.InterfaceNames()
+		if err != nil {
+			return false, err
+		}
+
+		queue = append(queue, interfaces...)
+
+		superName, ok, err := c.SuperClassName()
+		if err != nil {
+			return false, err
+		}
+
+		if ok {
+			queue = append(queue, superName)
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,429 @@
+package jvm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+)
+
+// The HPROF binary format is described in the HotSpot sources, see heapDumper.cpp.
+const hprofHeader = "JAVA PROFILE 1.0.2"
+const hprofIDSize = 4
+
+const (
+	hprofUtf8            = 0x01
+	hprofLoadClass       = 0x02
+	hprofTrace           = 0x05
+	hprofHeapDumpSegment = 0x1c
+	hprofHeapDumpEnd     = 0x2c
+)
+
+const (
+	hprofGCRootUnknown     = 0xff
+	hprofGCRootJavaFrame   = 0x03
+	hprofGCRootStickyClass = 0x05
+	hprofGCClassDump       = 0x20
+	hprofGCInstanceDump    = 0x21
+	hprofGCObjArrayDump    = 0x22
+	hprofGCPrimArrayDump   = 0x23
+)
+
+type hprofType uint8
+
+const (
+	hprofNormalObject hprofType = 2
+	hprofBoolean      hprofType = 4
+	hprofChar         hprofType = 5
+	hprofFloat        hprofType = 6
+	hprofDouble       hprofType = 7
+	hprofByte         hprofType = 8
+	hprofShort        hprofType = 9
+	hprofInt          hprofType = 10
+	hprofLong         hprofType = 11
+)
+
+// the class used for arrays of references
+const objectArrayClassName = "[Ljava/lang/Object;"
+
+func hprofTypeOfDescriptor(descriptor string) hprofType {
+	switch descriptor[0] {
+	case 'Z':
+		return hprofBoolean
+	case 'C':
+		return hprofChar
+	case 'F':
+		return hprofFloat
+	case 'D':
+		return hprofDouble
+	case 'B':
+		return hprofByte
+	case 'S':
+		return hprofShort
+	case 'I':
+		return hprofInt
+	case 'J':
+		return hprofLong
+	default:
+		return hprofNormalObject
+	}
+}
+
+func (t hprofType) size() int {
+	switch t {
+	case hprofBoolean, hprofByte:
+		return 1
+	case hprofChar, hprofShort:
+		return 2
+	case hprofDouble, hprofLong:
+		return 8
+	default:
+		return 4
+	}
+}
+
+type HprofRootKind int
+
+const (
+	HprofRootUnknown HprofRootKind = iota
+	HprofRootJavaFrame
+)
+
+type HprofRoot struct {
+	Ref        stack.Reference
+	Kind       HprofRootKind
+	FrameDepth int
+}
+
+type hprofWriter struct {
+	out     io.Writer
+	record  bytes.Buffer
+	strings map[string]uint32
+	classes map[string]uint32
+	nextID  uint32
+	err     error
+}
+
+func (w *hprofWriter) u1(v uint8) {
+	w.record.WriteByte(v)
+}
+
+func (w *hprofWriter) u2(v uint16) {
+	w.record.Write(binary.BigEndian.AppendUint16(nil, v))
+}
+
+func (w *hprofWriter) u4(v uint32) {
+	w.record.Write(binary.BigEndian.AppendUint32(nil, v))
+}
+
+func (w *hprofWriter) id(v uint32) {
+	w.u4(v)
+}
+
+func (w *hprofWriter) value(typ hprofType, value stack.Value) {
+	writeHprofValue(&w.record, typ, value)
+}
+
+func writeHprofValue(buf *bytes.Buffer, typ hprofType, value stack.Value) {
+	switch typ {
+	case hprofNormalObject:
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(referenceOf(value))))
+	case hprofBoolean, hprofByte:
+		buf.WriteByte(uint8(numericBits(value)))
+	case hprofChar, hprofShort:
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(numericBits(value))))
+	case hprofFloat:
+		if f, ok := value.(stack.FloatValue); ok {
+			buf.Write(binary.BigEndian.AppendUint32(nil, math.Float32bits(f.Value)))
+		} else {
+			buf.Write(binary.BigEndian.AppendUint32(nil, uint32(numericBits(value))))
+		}
+	case hprofDouble:
+		if d, ok := value.(stack.DoubleValue); ok {
+			buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(d.Value)))
+		} else {
+			buf.Write(binary.BigEndian.AppendUint64(nil, numericBits(value)))
+		}
+	case hprofLong:
+		buf.Write(binary.BigEndian.AppendUint64(nil, numericBits(value)))
+	default:
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(numericBits(value))))
+	}
+}
+
+// flush writes the buffered record with the given tag.
+func (w *hprofWriter) flush(tag uint8) {
+	if w.err != nil {
+		return
+	}
+
+	header := []byte{tag}
+	header = binary.BigEndian.AppendUint32(header, 0)
+	header = binary.BigEndian.AppendUint32(header, uint32(w.record.Len()))
+
+	_, w.err = w.out.Write(header)
+	if w.err != nil {
+		return
+	}
+
+	_, w.err = w.out.Write(w.record.Bytes())
+	w.record.Reset()
+}
+
+func (w *hprofWriter) newID() uint32 {
+	id := w.nextID
+	w.nextID++
+	return id
+}
+
+func (w *hprofWriter) stringID(s string) uint32 {
+	if id, ok := w.strings[s]; ok {
+		return id
+	}
+
+	id := w.newID()
+	w.strings[s] = id
+
+	w.id(id)
+	w.record.WriteString(s)
+	w.flush(hprofUtf8)
+
+	return id
+}
+
+func referenceOf(value stack.Value) stack.Reference {
+	switch v := value.(type) {
+	case stack.ReferenceValue:
+		return v.Value
+	case stack.ClassReferenceValue:
+		return v.Value
+	default:
+		return stack.Null
+	}
+}
+
+func numericBits(value stack.Value) uint64 {
+	switch v := value.(type) {
+	case stack.BooleanValue:
+		if v.Value {
+			return 1
+		}
+		return 0
+	case stack.ByteValue:
+		return uint64(v.Value)
+	case stack.CharValue:
+		return uint64(v.Value)
+	case stack.ShortValue:
+		return uint64(v.Value)
+	case stack.IntValue:
+		return uint64(uint32(v.Value))
+	case stack.LongValue:
+		return v.Value
+	case stack.FloatValue:
+		return uint64(math.Float32bits(v.Value))
+	case stack.DoubleValue:
+		return math.Float64bits(v.Value)
+	default:
+		return 0
+	}
+}
+
+// DumpHprof writes all items of the heap in the HPROF binary format,
+// readable by tools like Eclipse MAT or VisualVM.
+func (h *Heap) DumpHprof(out io.Writer, classes []loader.ClassSnapshot, roots []HprofRoot) error {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	w := &hprofWriter{
+		out:     out,
+		strings: make(map[string]uint32),
+		classes: make(map[string]uint32),
+		// object ids are heap references, everything else is numbered after them
+		nextID: uint32(len(h.items)),
+	}
+
+	header := append([]byte(hprofHeader), 0)
+	header = binary.BigEndian.AppendUint32(header, hprofIDSize)
+	header = binary.BigEndian.AppendUint64(header, uint64(time.Now().UnixMilli()))
+	_, err := out.Write(header)
+	if err != nil {
+		return err
+	}
+
+	slices.SortFunc(classes, func(a, b loader.ClassSnapshot) int {
+		return strings.Compare(a.Class.Name, b.Class.Name)
+	})
+
+	const traceSerial = 1
+	w.u4(traceSerial)
+	w.u4(0)
+	w.u4(0)
+	w.flush(hprofTrace)
+
+	superNames := make(map[string]string, len(classes))
+	for i, c := range classes {
+		superName, _, err := c.Class.SuperClassName()
+		if err != nil {
+			return err
+		}
+
+		superNames[c.Class.Name] = superName
+
+		nameID := w.stringID(c.Class.Name)
+		classID := w.newID()
+		w.classes[c.Class.Name] = classID
+
+		w.u4(uint32(i + 1))
+		w.id(classID)
+		w.u4(traceSerial)
+		w.id(nameID)
+		w.flush(hprofLoadClass)
+	}
+
+	// object arrays of classes that were never loaded fall back to Object[]
+	arrayClassID, ok := w.classes[objectArrayClassName]
+	if !ok {
+		arrayClassID = w.newID()
+		arrayNameID := w.stringID(objectArrayClassName)
+		w.u4(uint32(len(classes) + 1))
+		w.id(arrayClassID)
+		w.u4(traceSerial)
+		w.id(arrayNameID)
+		w.flush(hprofLoadClass)
+	}
+
+	// field names are referenced from the class dumps, so they have to be written before the segment
+	for _, c := range classes {
+		for _, static := range c.Statics {
+			w.stringID(static.Name)
+		}
+
+		for _, field := range c.Layout.Fields {
+			w.stringID(field.Name)
+		}
+	}
+
+	for _, root := range roots {
+		switch root.Kind {
+		case HprofRootJavaFrame:
+			w.u1(hprofGCRootJavaFrame)
+			w.id(uint32(root.Ref))
+			w.u4(1)
+			w.u4(uint32(root.FrameDepth))
+		default:
+			w.u1(hprofGCRootUnknown)
+			w.id(uint32(root.Ref))
+		}
+	}
+
+	for _, c := range classes {
+		classID := w.classes[c.Class.Name]
+
+		w.u1(hprofGCRootStickyClass)
+		w.id(classID)
+
+		instanceSize := 0
+		ownFields := make([]loader.LayoutField, 0)
+		for _, field := range c.Layout.Fields {
+			instanceSize += hprofTypeOfDescriptor(field.Descriptor).size()
+			if field.Class == c.Class.Name {
+				ownFields = append(ownFields, field)
+			}
+		}
+
+		w.u1(hprofGCClassDump)
+		w.id(classID)
+		w.u4(traceSerial)
+		w.id(w.classes[superNames[c.Class.Name]])
+		// class loader, signers, protection domain and two reserved ids
+		for range 5 {
+			w.id(0)
+		}
+		w.u4(uint32(instanceSize))
+		w.u2(0)
+
+		w.u2(uint16(len(c.Statics)))
+		for _, static := range c.Statics {
+			typ := hprofTypeOfDescriptor(static.Descriptor)
+			w.id(w.strings[static.Name])
+			w.u1(uint8(typ))
+			w.value(typ, static.Value)
+		}
+
+		w.u2(uint16(len(ownFields)))
+		for _, field := range ownFields {
+			w.id(w.strings[field.Name])
+			w.u1(uint8(hprofTypeOfDescriptor(field.Descriptor)))
+		}
+	}
+
+	w.u1(hprofGCClassDump)
+	w.id(arrayClassID)
+	w.u4(traceSerial)
+	w.id(w.classes["java/lang/Object"])
+	for range 5 {
+		w.id(0)
+	}
+	w.u4(0)
+	w.u2(0)
+	w.u2(0)
+	w.u2(0)
+
+	for i, item := range h.items {
+		switch item := item.(type) {
+		case *Object:
+			w.u1(hprofGCInstanceDump)
+			w.id(uint32(i))
+			w.u4(traceSerial)
+			w.id(w.classes[item.ClassName()])
+
+			// the fields of the class come first, then those of its superclasses
+			var values bytes.Buffer
+			for className := item.ClassName(); className != ""; className = superNames[className] {
+				for index, field := range item.layout.Fields {
+					if field.Class == className {
+						writeHprofValue(&values, hprofTypeOfDescriptor(field.Descriptor), item.fields[index])
+					}
+				}
+			}
+
+			w.u4(uint32(values.Len()))
+			w.record.Write(values.Bytes())
+		case *Array:
+			typ := hprofTypeOfDescriptor(item.ClassName()[1:])
+			if typ == hprofNormalObject {
+				classID, ok := w.classes[item.ClassName()]
+				if !ok {
+					classID = arrayClassID
+				}
+
+				w.u1(hprofGCObjArrayDump)
+				w.id(uint32(i))
+				w.u4(traceSerial)
+				w.u4(uint32(len(item.items)))
+				w.id(classID)
+			} else {
+				w.u1(hprofGCPrimArrayDump)
+				w.id(uint32(i))
+				w.u4(traceSerial)
+				w.u4(uint32(len(item.items)))
+				w.u1(uint8(typ))
+			}
+
+			for _, value := range item.items {
+				w.value(typ, value)
+			}
+		}
+	}
+
+	w.flush(hprofHeapDumpSegment)
+	w.flush(hprofHeapDumpEnd)
+
+	return w.err
+}
@@ -0,0 +1,119 @@
+package jvm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+	"github.com/m4tthewde/swell/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// readHprof splits a dump into its top level records and the sub records of the heap dump segments.
+func readHprof(t *testing.T, dump []byte) (map[uint8]int, map[uint8][][]byte) {
+	header := append([]byte(hprofHeader), 0)
+	assert.Equal(t, header, dump[:len(header)])
+	assert.Equal(t, uint32(hprofIDSize), binary.BigEndian.Uint32(dump[len(header):]))
+
+	records := make(map[uint8]int)
+	subRecords := make(map[uint8][][]byte)
+
+	rest := dump[len(header)+12:]
+	for len(rest) > 0 {
+		tag := rest[0]
+		length := binary.BigEndian.Uint32(rest[5:9])
+		body := rest[9 : 9+length]
+		rest = rest[9+length:]
+		records[tag]++
+
+		if tag != hprofHeapDumpSegment {
+			continue
+		}
+
+		for len(body) > 0 {
+			subTag := body[0]
+			var size int
+			switch subTag {
+			case hprofGCRootUnknown, hprofGCRootStickyClass:
+				size = 5
+			case hprofGCRootJavaFrame:
+				size = 13
+			case hprofGCInstanceDump:
+				size = 13 + int(binary.BigEndian.Uint32(body[13:])) + 4
+			case hprofGCPrimArrayDump:
+				count := int(binary.BigEndian.Uint32(body[9:]))
+				size = 14 + count*hprofType(body[13]).size()
+			case hprofGCObjArrayDump:
+				count := int(binary.BigEndian.Uint32(body[9:]))
+				size = 17 + count*hprofIDSize
+			case hprofGCClassDump:
+				offset := 1 + 4 + 4 + 6*4 + 4
+				constants := binary.BigEndian.Uint16(body[offset:])
+				assert.Equal(t, uint16(0), constants)
+				offset += 2
+				statics := int(binary.BigEndian.Uint16(body[offset:]))
+				offset += 2
+				for range statics {
+					offset += 4
+					offset += 1 + hprofType(body[offset]).size()
+				}
+				fields := int(binary.BigEndian.Uint16(body[offset:]))
+				size = offset + 2 + fields*5
+			default:
+				t.Fatalf("unexpected sub record %x", subTag)
+			}
+
+			subRecords[subTag] = append(subRecords[subTag], body[:size])
+			body = body[size:]
+		}
+	}
+
+	return records, subRecords
+}
+
+func TestDumpHprof(t *testing.T) {
+	ctx := logger.OnContext(t.Context(), zap.NewNop().Sugar())
+	heap := NewHeap()
+	layout := pointLayout(t)
+
+	point, err := heap.AllocateObject(ctx, layout)
+	assert.Nil(t, err)
+
+	err = heap.SetField(point, loader.FieldKey{Class: "Point", Name: "y", Descriptor: "I"}, stack.IntValue{Value: 42})
+	assert.Nil(t, err)
+
+	_, err = heap.AllocateArray(ctx, "[B", []stack.Value{stack.ByteValue{Value: 1}, stack.ByteValue{Value: 2}})
+	assert.Nil(t, err)
+
+	_, err = heap.AllocateArray(ctx, "[LPoint;", []stack.Value{stack.ReferenceValue{Value: point}})
+	assert.Nil(t, err)
+
+	classes := []loader.ClassSnapshot{{
+		Class:   &class.Class{Name: "Point"},
+		Layout:  layout,
+		Statics: []loader.StaticValue{{Name: "ORIGIN", Descriptor: "LPoint;", Value: stack.ReferenceValue{Value: point}}},
+	}}
+
+	var dump bytes.Buffer
+	err = heap.DumpHprof(&dump, classes, []HprofRoot{{Ref: point, Kind: HprofRootJavaFrame}})
+	assert.Nil(t, err)
+
+	records, subRecords := readHprof(t, dump.Bytes())
+	assert.Equal(t, 2, records[hprofLoadClass])
+	assert.Equal(t, 1, records[hprofHeapDumpSegment])
+	assert.Equal(t, 1, records[hprofHeapDumpEnd])
+
+	assert.Len(t, subRecords[hprofGCRootJavaFrame], 1)
+	assert.Len(t, subRecords[hprofGCClassDump], 2)
+	assert.Len(t, subRecords[hprofGCPrimArrayDump], 1)
+	assert.Len(t, subRecords[hprofGCObjArrayDump], 1)
+
+	instances := subRecords[hprofGCInstanceDump]
+	assert.Len(t, instances, 1)
+	assert.Equal(t, uint32(point), binary.BigEndian.Uint32(instances[0][1:]))
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 42}, instances[0][17:])
+}
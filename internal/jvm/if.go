@@ -33,8 +33,9 @@ func value(r *Runner) (int, error) {
 }
 
 func jump(r *Runner, code []byte) {
-	index := (uint16(code[r.pc+1])<<8 | uint16(code[r.pc+2]))
-	r.pc += int(index)
+	// the offset is signed, loops branch backwards
+	offset := int16(uint16(code[r.pc+1])<<8 | uint16(code[r.pc+2]))
+	r.pc += int(offset)
 }
 
 func cont(r *Runner) {
@@ -0,0 +1,34 @@
+package jvm
+
+import (
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackwardBranches(t *testing.T) {
+	main := newTestClass("Main", "java/lang/Object")
+	// while (n != 0) n--; with the loop closed by goto
+	main.method(class.AccStatic, "whileLoop", "(I)I",
+		ILoad0, IStore2,
+		ILoad2, IfEq, 0, 10,
+		ILoad2, IConst1, ISub, IStore2,
+		GoTo, 0xff, 0xf8,
+		ILoad2, IReturn)
+	// do n--; while (n != 0); with the loop closed by ifne
+	main.method(class.AccStatic, "doWhileLoop", "(I)I",
+		ILoad0, IStore2,
+		ILoad2, IConst1, ISub, IStore2,
+		ILoad2, IfNe, 0xff, 0xfb,
+		ILoad2, IReturn)
+
+	r, ctx := newTestRunner(t, main.build())
+
+	for _, name := range []string{"whileLoop", "doWhileLoop"} {
+		result, err := invokeTestMethod(t, ctx, r, "Main", name, "(I)I", stack.IntValue{Value: 3})
+		assert.NoError(t, err, name)
+		assert.Equal(t, stack.IntValue{Value: 0}, result, name)
+	}
+}
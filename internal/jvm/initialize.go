@@ -0,0 +1,273 @@
+package jvm
+
+import (
+	"context"
+	"sync"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/logger"
+)
+
+type initState int
+
+const (
+	classUninitialized initState = iota
+	classBeingInitialized
+	classInitialized
+	classErroneous
+)
+
+func (s initState) String() string {
+	switch s {
+	case classBeingInitialized:
+		return "being initialized"
+	case classInitialized:
+		return "initialized"
+	case classErroneous:
+		return "erroneous"
+	default:
+		return "uninitialized"
+	}
+}
+
+// classInit is the initialization state of a class, see JVMS §5.5.
+type classInit struct {
+	state initState
+	// thread is the runner executing <clinit> while the class is being initialized
+	thread *Runner
+	// done is closed once the class is no longer being initialized
+	done chan struct{}
+}
+
+// classInits holds the initialization state of all classes. Its lock serves as the
+// initialization lock LC of every class.
+type classInits struct {
+	lock    sync.Mutex
+	classes map[string]*classInit
+}
+
+func (r *Runner) initState(className string) initState {
+	r.classInits.lock.Lock()
+	defer r.classInits.lock.Unlock()
+
+	init, ok := r.classInits.classes[className]
+	if !ok {
+		return classUninitialized
+	}
+
+	return init.state
+}
+
+// claimInitialization performs steps 1 to 6 of the initialization procedure. It waits while another
+// thread initializes the class and returns the state the class had, if it was uninitialized the
+// class is now being initialized by r. Waiting ends early with the cause of ctx once it is cancelled.
+func (r *Runner) claimInitialization(ctx context.Context, className string) (initState, error) {
+	inits := &r.classInits
+	inits.lock.Lock()
+	defer inits.lock.Unlock()
+
+	for {
+		init, ok := inits.classes[className]
+		if !ok {
+			init = &classInit{state: classUninitialized}
+			inits.classes[className] = init
+		}
+
+		if init.state == classBeingInitialized && init.thread != r {
+			done := init.done
+
+			// the lock is released while waiting, the initializing thread needs it to finish
+			inits.lock.Unlock()
+			r.blocking(func() {
+				select {
+				case <-done:
+				case <-ctx.Done():
+				}
+			})
+			inits.lock.Lock()
+
+			if ctx.Err() != nil {
+				return classUninitialized, context.Cause(ctx)
+			}
+
+			continue
+		}
+
+		state := init.state
+		if state == classUninitialized {
+			init.state = classBeingInitialized
+			init.thread = r
+			init.done = make(chan struct{})
+		}
+
+		return state, nil
+	}
+}
+
+// finishInitialization records whether the initialization succeeded and wakes up the
+// threads waiting for it, steps 10 to 12 of the initialization procedure.
+func (r *Runner) finishInitialization(className string, state initState) {
+	r.classInits.lock.Lock()
+	defer r.classInits.lock.Unlock()
+
+	init := r.classInits.classes[className]
+	init.state = state
+	init.thread = nil
+	close(init.done)
+}
+
+// initializeClass follows the initialization procedure of JVMS §5.5.
+// Only one thread runs <clinit>, others trying to initialize the class at the same time wait for it.
+// The superclass and superinterfaces declaring default methods are initialized first,
+// exceptions thrown by <clinit> are wrapped in an ExceptionInInitializerError
+// and later attempts to initialize an erroneous class fail with a NoClassDefFoundError.
+func (r *Runner) initializeClass(ctx context.Context, className string) error {
+	log := logger.FromContext(ctx)
+
+	c, err := r.loader.Load(ctx, className)
+	if err != nil {
+		return err
+	}
+
+	state, err := r.claimInitialization(ctx, className)
+	if err != nil {
+		return err
+	}
+
+	switch state {
+	case classInitialized:
+		log.Debugw("already initialized", "className", className)
+		return nil
+	case classBeingInitialized:
+		// recursive request, e.g. <clinit> accessing a static field of its own class
+		return nil
+	case classErroneous:
+		return r.newThrowable(ctx, "java/lang/NoClassDefFoundError", "Could not initialize class "+dotted(className), nil)
+	}
+
+	log.Infow("initializing", "className", className)
+	if r.tracer != nil {
+		r.tracer.ClassInit(r.classEvent(className))
+	}
+
+	err = r.initializeConstants(ctx, c)
+	if err != nil {
+		r.finishInitialization(className, classErroneous)
+		return err
+	}
+
+	if !c.IsInterface() {
+		err = r.initializeSupers(ctx, c)
+		if err != nil {
+			r.finishInitialization(className, classErroneous)
+			return err
+		}
+	}
+
+	err = r.runClassInitializer(ctx, c)
+	if err != nil {
+		r.finishInitialization(className, classErroneous)
+		log.Infow("initialization failed", "className", className, "error", err)
+
+		throwable, ok := err.(*ThrowableError)
+		if !ok {
+			return err
+		}
+
+		// the exception is no longer reachable from <clinit>, loading its superclasses can collect
+		if throwable.Ref != stack.Null {
+			r.heap.AddHandle(throwable.Ref)
+		}
+
+		isError, subclassErr := r.isSubclassOf(ctx, throwable.ClassName, "java/lang/Error")
+		if subclassErr == nil && isError {
+			return throwable
+		}
+
+		return r.newThrowable(ctx, "java/lang/ExceptionInInitializerError", "", throwable)
+	}
+
+	err = r.injectVMFields(className)
+	if err != nil {
+		r.finishInitialization(className, classErroneous)
+		return err
+	}
+
+	r.finishInitialization(className, classInitialized)
+	log.Infow("initialized", "className", className)
+	return nil
+}
+
+// initializeSupers initializes the superclass and the superinterfaces declaring default methods.
+func (r *Runner) initializeSupers(ctx context.Context, c *class.Class) error {
+	superName, ok, err := c.SuperClassName()
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		err = r.initializeClass(ctx, superName)
+		if err != nil {
+			return err
+		}
+	}
+
+	interfaceNames, err := c.InterfaceNames()
+	if err != nil {
+		return err
+	}
+
+	for _, interfaceName := range interfaceNames {
+		err = r.initializeInterface(ctx, interfaceName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Runner) initializeInterface(ctx context.Context, interfaceName string) error {
+	i, err := r.loader.Load(ctx, interfaceName)
+	if err != nil {
+		return err
+	}
+
+	if i.DeclaresDefaultMethods() {
+		return r.initializeClass(ctx, interfaceName)
+	}
+
+	// superinterfaces of an interface without default methods may still declare some
+	interfaceNames, err := i.InterfaceNames()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range interfaceNames {
+		err = r.initializeInterface(ctx, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Runner) runClassInitializer(ctx context.Context, c *class.Class) error {
+	clinit, ok, err := c.GetMethod("<clinit>", "()V")
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return nil
+	}
+
+	code, err := clinit.CodeAttribute()
+	if err != nil {
+		return err
+	}
+
+	return r.runMethod(ctx, code, *c, *clinit, make([]stack.Value, 0))
+}
@@ -0,0 +1,180 @@
+package jvm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/stretchr/testify/assert"
+)
+
+// throwableClasses defines the exception classes the VM creates itself.
+func throwableClasses() []*class.Class {
+	str := newTestClass("java/lang/String", "java/lang/Object").
+		field(0, "value", "[B").
+		field(0, "coder", "B")
+
+	throwable := newTestClass("java/lang/Throwable", "java/lang/Object").
+		field(0, "detailMessage", "Ljava/lang/String;").
+		field(0, "cause", "Ljava/lang/Throwable;")
+
+	classes := []*class.Class{str.build(), throwable.build()}
+	for _, names := range [][2]string{
+		{"java/lang/Exception", "java/lang/Throwable"},
+		{"java/lang/RuntimeException", "java/lang/Exception"},
+		{"java/lang/ArithmeticException", "java/lang/RuntimeException"},
+		{"java/lang/Error", "java/lang/Throwable"},
+		{"java/lang/LinkageError", "java/lang/Error"},
+		{"java/lang/NoClassDefFoundError", "java/lang/LinkageError"},
+		{"java/lang/ExceptionInInitializerError", "java/lang/LinkageError"},
+	} {
+		c := newTestClass(names[0], names[1]).
+			method(class.AccPublic, "<init>", "()V", RetOp)
+		classes = append(classes, c.build())
+	}
+
+	return classes
+}
+
+// throwCode creates a new instance of className and throws it.
+func throwCode(b *testClass, className string) []byte {
+	code := append([]byte{NewOp}, u2(b.classRef(className))...)
+	code = append(code, DupOp, InvokeSpecialOp)
+	code = append(code, u2(b.ref(className, "<init>", "()V"))...)
+	return append(code, AThrow)
+}
+
+func TestInitializeSuperclassAndInterfaces(t *testing.T) {
+	plain := newTestClass("Plain", "").
+		flags(class.AccPublic|class.AccInterface|class.AccAbstract).
+		method(class.AccPublic|class.AccAbstract, "run", "()V")
+	withDefault := newTestClass("WithDefault", "").
+		flags(class.AccPublic|class.AccInterface|class.AccAbstract).
+		method(class.AccPublic, "run", "()V", RetOp)
+	base := newTestClass("Base", "java/lang/Object")
+	sub := newTestClass("Sub", "Base").interfaces("Plain", "WithDefault")
+
+	r, ctx := newTestRunner(t, plain.build(), withDefault.build(), base.build(), sub.build())
+
+	assert.Nil(t, r.initializeClass(ctx, "Sub"))
+	assert.Equal(t, classInitialized, r.initState("Sub"))
+	assert.Equal(t, classInitialized, r.initState("Base"))
+	assert.Equal(t, classInitialized, r.initState("java/lang/Object"))
+	assert.Equal(t, classInitialized, r.initState("WithDefault"))
+	assert.Equal(t, classUninitialized, r.initState("Plain"))
+}
+
+func TestInitializeWrapsExceptions(t *testing.T) {
+	bad := newTestClass("Bad", "java/lang/Object")
+	bad.method(class.AccStatic, "<clinit>", "()V", throwCode(bad, "java/lang/ArithmeticException")...)
+
+	r, ctx := newTestRunner(t, append(throwableClasses(), bad.build())...)
+
+	err := r.initializeClass(ctx, "Bad")
+	throwable, ok := err.(*ThrowableError)
+	assert.True(t, ok)
+	assert.Equal(t, "java/lang/ExceptionInInitializerError", throwable.ClassName)
+	assert.NotEqual(t, stack.Null, throwable.Ref)
+	assert.Equal(t, "java/lang/ArithmeticException", throwable.Cause.ClassName)
+	assert.Equal(t, "java.lang.ExceptionInInitializerError\nCaused by: java.lang.ArithmeticException\n\tBad.<clinit>()", err.Error())
+
+	cause, err := r.heap.GetObject(throwable.Ref)
+	assert.Nil(t, err)
+	value, err := cause.GetFieldValue(throwableCauseField)
+	assert.Nil(t, err)
+	assert.Equal(t, stack.ReferenceValue{Value: throwable.Cause.Ref}, value)
+
+	assert.Equal(t, classErroneous, r.initState("Bad"))
+
+	err = r.initializeClass(ctx, "Bad")
+	throwable, ok = err.(*ThrowableError)
+	assert.True(t, ok)
+	assert.Equal(t, "java/lang/NoClassDefFoundError", throwable.ClassName)
+	assert.Equal(t, "java.lang.NoClassDefFoundError: Could not initialize class Bad", err.Error())
+}
+
+func TestInitializeRethrowsErrors(t *testing.T) {
+	bad := newTestClass("Bad", "java/lang/Object")
+	bad.method(class.AccStatic, "<clinit>", "()V", throwCode(bad, "java/lang/Error")...)
+	sub := newTestClass("Sub", "Bad")
+
+	r, ctx := newTestRunner(t, append(throwableClasses(), bad.build(), sub.build())...)
+
+	err := r.initializeClass(ctx, "Sub")
+	throwable, ok := err.(*ThrowableError)
+	assert.True(t, ok)
+	assert.Equal(t, "java/lang/Error", throwable.ClassName)
+	assert.Equal(t, classErroneous, r.initState("Bad"))
+	assert.Equal(t, classErroneous, r.initState("Sub"))
+}
+
+func TestCatchException(t *testing.T) {
+	main := newTestClass("Main", "java/lang/Object")
+	main.method(class.AccStatic, "thrower", "()V", throwCode(main, "java/lang/ArithmeticException")...)
+
+	// try { thrower(); return 0; } catch (RuntimeException e) { return 1; }
+	caller := append([]byte{InvokeStaticOp}, u2(main.ref("Main", "thrower", "()V"))...)
+	caller = append(caller, IConst0, IReturn, IConst1, IReturn)
+	main.method(class.AccStatic, "caught", "()I", caller...).
+		catch(0, 3, 5, "java/lang/RuntimeException")
+	main.method(class.AccStatic, "uncaught", "()I", caller...).
+		catch(0, 3, 5, "java/lang/Error")
+
+	r, ctx := newTestRunner(t, append(throwableClasses(), main.build())...)
+
+	value, err := invokeTestMethod(t, ctx, r, "Main", "caught", "()I")
+	assert.Nil(t, err)
+	assert.Equal(t, stack.IntValue{Value: 1}, value)
+	assert.Equal(t, 0, r.pc)
+
+	_, err = invokeTestMethod(t, ctx, r, "Main", "uncaught", "()I")
+	assert.Equal(t, "java.lang.ArithmeticException\n\tMain.thrower()\n\tMain.uncaught()", err.Error())
+	assert.Equal(t, 0, r.pc)
+}
+
+// TestConcurrentInitialization starts threads initializing a class whose <clinit> blocks,
+// in several runners at once. Only one thread runs <clinit>, the others wait for it.
+func TestConcurrentInitialization(t *testing.T) {
+	for i := range 4 {
+		t.Run(fmt.Sprintf("runner%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			slow := newTestClass("Slow", "java/lang/Object").
+				method(class.AccStatic|class.AccNative, "block", "()V")
+			slow.method(class.AccStatic, "<clinit>", "()V", append(append([]byte{InvokeStaticOp}, u2(slow.ref("Slow", "block", "()V"))...), RetOp)...)
+			r, ctx := newTestRunner(t, append(threadClasses(), slow.build())...)
+
+			runs := 0
+			r.natives.Register("Slow", "block", "()V", func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+				runs++
+				return nil, r.sleep(ctx, 10*time.Millisecond)
+			})
+
+			initialized := 0
+			r.natives.Register("Blocked", "run", "()V", func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+				err := r.initializeClass(ctx, "Slow")
+				if r.initState("Slow") == classInitialized {
+					initialized++
+				}
+
+				return nil, err
+			})
+
+			for _, name := range []string{"T1", "T2", "T3"} {
+				thread := newThreadObject(t, ctx, r, "Blocked", name)
+				_, err := threadStart0(ctx, r, []stack.Value{stack.ReferenceValue{Value: thread}})
+				assert.Nil(t, err)
+			}
+
+			assert.Nil(t, r.initializeClass(ctx, "Slow"))
+			r.waitForThreads()
+
+			assert.Equal(t, 1, runs)
+			assert.Equal(t, 3, initialized)
+			assert.Equal(t, classInitialized, r.initState("Slow"))
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package jvm
+
+import (
+	"context"
+
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+)
+
+func instanceOf(r *Runner, ctx context.Context, code []byte) error {
+	className, err := r.classOperand(code)
+	if err != nil {
+		return err
+	}
+
+	r.pc += 3
+
+	operands, err := r.stack.PopOperands(1)
+	if err != nil {
+		return err
+	}
+
+	ok, err := r.isInstanceOf(ctx, referenceOf(operands[0]), className)
+	if err != nil {
+		return err
+	}
+
+	result := int32(0)
+	if ok {
+		result = 1
+	}
+
+	return r.stack.PushOperand(ctx, stack.IntValue{Value: result})
+}
@@ -6,82 +6,59 @@ import (
 	"fmt"
 
 	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
 )
 
 func invokeVirtual(r *Runner, ctx context.Context, code []byte) error {
 	index := (uint16(code[r.pc+1])<<8 | uint16(code[r.pc+2]))
 	r.pc += 3
 
-	pool, err := r.stack.CurrentConstantPool()
+	resolved, err := resolveMethodRef(r, ctx, index)
 	if err != nil {
 		return err
 	}
 
-	methodRef, err := pool.Ref(index)
-	if err != nil {
-		return err
-	}
-
-	classInfo, err := pool.Class(methodRef.ClassIndex)
-	if err != nil {
-		return err
-	}
-
-	className, err := pool.GetUtf8(classInfo.NameIndex)
-	if err != nil {
-		return err
-	}
-
-	c, err := r.loader.Load(ctx, className)
-	if err != nil {
-		return err
-	}
-
-	nameAndType, err := pool.NameAndType(methodRef.NameAndTypeIndex)
-	if err != nil {
-		return err
-	}
-
-	methodName, err := pool.GetUtf8(nameAndType.NameIndex)
-	if err != nil {
-		return err
-	}
-
-	descriptorString, err := pool.GetUtf8(nameAndType.DescriptorIndex)
-	if err != nil {
-		return err
+	if isSignaturePolymorphic(resolved.class, resolved.method, resolved.descriptor) {
+		return errors.New("invokevirtual not implemented for signature polymorphic methods")
 	}
 
-	method, ok, err := c.GetMethod(methodName, descriptorString)
+	// +1 to include the objectref at position 0
+	parameters, err := r.stack.PopOperands(len(resolved.descriptor.Parameters) + 1)
 	if err != nil {
 		return err
 	}
 
-	if !ok {
-		return fmt.Errorf("method %s not found in %s", methodName, c.Name)
+	switch parameters[0].(type) {
+	case stack.ReferenceValue, stack.ClassReferenceValue:
+	default:
+		return fmt.Errorf("objectref has to be a reference, is %s", parameters[0])
 	}
 
-	methodDescriptor, err := class.NewMethodDescriptor(descriptorString)
-	if err != nil {
-		return err
+	objectRef := referenceOf(parameters[0])
+	if objectRef == stack.Null {
+		return r.newThrowable(ctx, "java/lang/NullPointerException", "", nil)
 	}
 
-	if isSignaturePolymorphic(c, method, methodDescriptor) {
-		return errors.New("invokevirtual not implemented for signature polymorphic methods")
-	} else {
-		// +1 to include the objectref at position 0
-		parameters, err := r.stack.PopOperands(len(methodDescriptor.Parameters) + 1)
+	// arrays override clone with a public method that never throws CloneNotSupportedException (JLS §10.7),
+	// javac calls it on the array class, e.g. [Ljava/lang/String;.clone()
+	if _, err := r.heap.GetArray(objectRef); err == nil && resolved.name == "clone" && len(resolved.descriptor.Parameters) == 0 {
+		clone, err := r.heap.Clone(ctx, objectRef)
 		if err != nil {
 			return err
 		}
 
-		codeAttribute, err := method.CodeAttribute()
+		return r.stack.PushOperand(ctx, stack.ReferenceValue{Value: clone})
+	}
+
+	c, method := resolved.class, resolved.method
+	if object, err := r.heap.GetObject(objectRef); err == nil {
+		c, method, err = r.selectMethod(ctx, object.ClassName(), resolved)
 		if err != nil {
 			return err
 		}
-
-		return r.runMethod(ctx, codeAttribute, *c, *method, parameters)
 	}
+
+	return r.invokeMethod(ctx, c, method, parameters)
 }
 
 func isSignaturePolymorphic(c *class.Class, method *class.Method, methodDescriptor *class.MethodDescriptor) bool {
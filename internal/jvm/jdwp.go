@@ -0,0 +1,566 @@
+package jvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"slices"
+	"sync"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/logger"
+)
+
+// The IDs handed to the debugger are heap references for objects, the other kinds of IDs
+// are numbered in their own range above them.
+const (
+	jdwpThreadIDBase  = 1 << 32
+	jdwpThreadGroupID = 2 << 32
+	jdwpClassIDBase   = 3 << 32
+	jdwpFrameIDBase   = 4 << 32
+	jdwpIDMask        = 1<<32 - 1
+)
+
+// jdwpAgent lets a debugger like jdb or IntelliJ attach through the Java Debug Wire Protocol,
+// like -agentlib:jdwp=transport=dt_socket,server=y does. Only one debugger can attach.
+type jdwpAgent struct {
+	listener net.Listener
+	// suspend holds the VM until the debugger resumes it, like suspend=y
+	suspend bool
+
+	// writeLock serializes the packets sent to the debugger
+	writeLock sync.Mutex
+
+	// lock guards the fields below. Threads running Java code take it while holding the
+	// interpreter lock, it must not be held while waiting for the interpreter lock.
+	lock          sync.Mutex
+	conn          net.Conn
+	nextPacketID  uint32
+	requests      []*jdwpRequest
+	nextRequestID int32
+	// suspended counts how often the debugger has suspended each thread
+	suspended map[*Runner]int
+	// resumed is closed and replaced whenever a thread is resumed
+	resumed   chan struct{}
+	threadIDs map[*Runner]uint64
+	threads   []*Runner
+	classIDs  map[string]uint64
+	classes   []string
+	frames    map[uint64]jdwpFrame
+	// nextFrameID numbers the frames handed out, frame IDs are only valid while their thread is suspended
+	nextFrameID uint64
+	// done is closed once the connection has been closed
+	done chan struct{}
+}
+
+// jdwpFrame identifies a frame of a suspended thread by its depth, 0 being the active frame.
+type jdwpFrame struct {
+	thread *Runner
+	depth  int
+}
+
+func newJDWPAgent(listener net.Listener, suspend bool) *jdwpAgent {
+	return &jdwpAgent{
+		listener:  listener,
+		suspend:   suspend,
+		suspended: make(map[*Runner]int),
+		resumed:   make(chan struct{}),
+		threadIDs: make(map[*Runner]uint64),
+		classIDs:  make(map[string]uint64),
+		frames:    make(map[uint64]jdwpFrame),
+	}
+}
+
+// attach lets the debugger connect. With suspend set it waits for the debugger and returns once
+// the debugger resumes the VM, otherwise the debugger may attach at any time while the VM runs.
+func (a *jdwpAgent) attach(ctx context.Context, r *Runner) error {
+	if !a.suspend {
+		go func() {
+			conn, err := a.accept(ctx)
+			if err == nil {
+				err = a.connected(ctx, r, conn)
+			}
+
+			if err != nil && ctx.Err() == nil {
+				logger.FromContext(ctx).Warnw("debugger could not attach", "error", err)
+			}
+		}()
+
+		return nil
+	}
+
+	var conn net.Conn
+	var err error
+	r.blocking(func() {
+		conn, err = a.accept(ctx)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	err = a.connected(ctx, r, conn)
+	if err != nil {
+		return err
+	}
+
+	return a.waitWhileSuspended(ctx, r)
+}
+
+// accept waits for the debugger to connect, there is only ever one connection.
+func (a *jdwpAgent) accept(ctx context.Context) (net.Conn, error) {
+	stop := context.AfterFunc(ctx, func() {
+		a.listener.Close()
+	})
+	defer stop()
+
+	conn, err := a.listener.Accept()
+	a.listener.Close()
+
+	if ctx.Err() != nil {
+		return nil, context.Cause(ctx)
+	}
+
+	return conn, err
+}
+
+// connected performs the handshake, starts serving commands and reports the start of the VM.
+func (a *jdwpAgent) connected(ctx context.Context, r *Runner, conn net.Conn) error {
+	handshake := make([]byte, len(jdwpHandshake))
+	_, err := io.ReadFull(conn, handshake)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	if string(handshake) != jdwpHandshake {
+		conn.Close()
+		return fmt.Errorf("invalid JDWP handshake %q", handshake)
+	}
+
+	_, err = conn.Write(handshake)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	policy := byte(jdwpSuspendNone)
+	if a.suspend {
+		policy = jdwpSuspendAll
+	}
+
+	a.lock.Lock()
+	a.conn = conn
+	a.done = make(chan struct{})
+	if a.suspend {
+		// only the main thread exists before main runs
+		a.suspended[r]++
+	}
+
+	w := &jdwpWriter{}
+	w.id(a.threadID(r))
+	event := jdwpEvent{kind: jdwpEventVMStart, data: w.Bytes()}
+	a.lock.Unlock()
+
+	go a.serve(ctx, r, conn)
+
+	return a.sendEvents(policy, []jdwpEvent{event})
+}
+
+// detach reports the death of the VM and closes the connection.
+func (a *jdwpAgent) detach(r *Runner) {
+	a.lock.Lock()
+	conn, done := a.conn, a.done
+	events := []jdwpEvent{{kind: jdwpEventVMDeath}}
+	for _, q := range a.requests {
+		if q.kind == jdwpEventVMDeath {
+			events = append(events, jdwpEvent{kind: jdwpEventVMDeath, requestID: q.id})
+		}
+	}
+	a.lock.Unlock()
+
+	if conn == nil {
+		a.listener.Close()
+		return
+	}
+
+	// nothing is suspended anymore, the debugger learns about the death only
+	a.sendEvents(jdwpSuspendNone, events)
+	conn.Close()
+
+	// a command may be waiting for the interpreter lock
+	r.blocking(func() {
+		<-done
+	})
+}
+
+// serve handles the commands of the debugger until it disconnects. Threads it suspended are
+// resumed and its event requests are cleared then.
+func (a *jdwpAgent) serve(ctx context.Context, r *Runner, conn net.Conn) {
+	log := logger.FromContext(ctx)
+
+	defer func() {
+		a.lock.Lock()
+		a.conn = nil
+		a.requests = nil
+		a.resumeAll()
+		close(a.done)
+		a.lock.Unlock()
+	}()
+
+	for {
+		p, err := readJDWPPacket(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Warnw("debugger connection failed", "error", err)
+			}
+
+			return
+		}
+
+		if p.isReply() {
+			continue
+		}
+
+		reply := a.handle(ctx, r, p)
+		err = a.send(reply)
+		if err != nil {
+			log.Warnw("debugger connection failed", "error", err)
+			return
+		}
+	}
+}
+
+// handle runs a command with the interpreter lock held, so it sees a consistent state of the VM.
+func (a *jdwpAgent) handle(ctx context.Context, r *Runner, p jdwpPacket) jdwpPacket {
+	r.lockExternal()
+	defer r.lock.Unlock()
+
+	in := &jdwpReader{data: p.data}
+	out := &jdwpWriter{}
+
+	err := a.command(ctx, r, p.commandSet, p.command, in, out)
+	if err == nil && in.err != nil {
+		err = jdwpError(jdwpErrIllegalArgument)
+	}
+
+	reply := jdwpPacket{id: p.id, flags: jdwpReplyFlag}
+
+	var code jdwpError
+	switch {
+	case err == nil:
+		reply.data = out.Bytes()
+	case errors.As(err, &code):
+		reply.errorCode = uint16(code)
+	default:
+		logger.FromContext(ctx).Warnw("debugger command failed", "commandSet", p.commandSet, "command", p.command, "error", err)
+		reply.errorCode = jdwpErrInternal
+	}
+
+	return reply
+}
+
+func (a *jdwpAgent) send(p jdwpPacket) error {
+	a.lock.Lock()
+	conn := a.conn
+	a.lock.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	a.writeLock.Lock()
+	defer a.writeLock.Unlock()
+
+	_, err := conn.Write(p.bytes())
+	return err
+}
+
+// sendEvents sends a composite event command.
+func (a *jdwpAgent) sendEvents(policy byte, events []jdwpEvent) error {
+	w := &jdwpWriter{}
+	w.byte(policy)
+	w.int(int32(len(events)))
+	for _, event := range events {
+		w.byte(event.kind)
+		w.int(event.requestID)
+		w.Write(event.data)
+	}
+
+	a.lock.Lock()
+	a.nextPacketID++
+	id := a.nextPacketID
+	a.lock.Unlock()
+
+	return a.send(jdwpPacket{id: id, commandSet: jdwpCommandSetEvent, command: jdwpCommandComposite, data: w.Bytes()})
+}
+
+// suspendAll suspends every thread once, the caller has to hold a.lock and the interpreter lock.
+func (a *jdwpAgent) suspendAll(r *Runner) {
+	for _, t := range r.threads {
+		a.suspended[t]++
+	}
+}
+
+// resume undoes one suspension of t, the caller has to hold a.lock.
+func (a *jdwpAgent) resume(t *Runner) {
+	if a.suspended[t] == 0 {
+		return
+	}
+
+	a.suspended[t]--
+	if a.suspended[t] == 0 {
+		delete(a.suspended, t)
+		maps.DeleteFunc(a.frames, func(_ uint64, frame jdwpFrame) bool {
+			return frame.thread == t
+		})
+	}
+
+	close(a.resumed)
+	a.resumed = make(chan struct{})
+}
+
+// resumeAll resumes all threads however often they were suspended, the caller has to hold a.lock.
+func (a *jdwpAgent) resumeAll() {
+	clear(a.suspended)
+	clear(a.frames)
+	close(a.resumed)
+	a.resumed = make(chan struct{})
+}
+
+// waitWhileSuspended blocks the thread while the debugger keeps it suspended.
+func (a *jdwpAgent) waitWhileSuspended(ctx context.Context, r *Runner) error {
+	for {
+		a.lock.Lock()
+		suspended, resumed := a.suspended[r] > 0, a.resumed
+		a.lock.Unlock()
+
+		if !suspended {
+			return nil
+		}
+
+		r.blocking(func() {
+			select {
+			case <-resumed:
+			case <-ctx.Done():
+			}
+		})
+
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+	}
+}
+
+// threadID returns the ID of a thread, the caller has to hold a.lock.
+func (a *jdwpAgent) threadID(t *Runner) uint64 {
+	id, ok := a.threadIDs[t]
+	if !ok {
+		a.threads = append(a.threads, t)
+		id = jdwpThreadIDBase + uint64(len(a.threads))
+		a.threadIDs[t] = id
+	}
+
+	return id
+}
+
+func (a *jdwpAgent) thread(id uint64) (*Runner, error) {
+	index := int(id - jdwpThreadIDBase)
+	if id < jdwpThreadIDBase || index < 1 || index > len(a.threads) {
+		return nil, jdwpError(jdwpErrInvalidThread)
+	}
+
+	return a.threads[index-1], nil
+}
+
+// alive reports whether a thread has not yet terminated.
+func (a *jdwpAgent) alive(r *Runner, t *Runner) bool {
+	return slices.Contains(r.threads, t)
+}
+
+// classID returns the ID of the reference type className, the caller has to hold a.lock.
+func (a *jdwpAgent) classID(className string) uint64 {
+	id, ok := a.classIDs[className]
+	if !ok {
+		a.classes = append(a.classes, className)
+		id = jdwpClassIDBase + uint64(len(a.classes))
+		a.classIDs[className] = id
+	}
+
+	return id
+}
+
+// class returns the loaded class behind a reference type ID.
+func (a *jdwpAgent) class(ctx context.Context, r *Runner, id uint64) (*class.Class, error) {
+	index := int(id - jdwpClassIDBase)
+	if id < jdwpClassIDBase || index < 1 || index > len(a.classes) {
+		return nil, jdwpError(jdwpErrInvalidClass)
+	}
+
+	return a.loadedClass(ctx, r, a.classes[index-1])
+}
+
+// loadedClass returns a class that has already been loaded, the debugger never causes classes to be loaded.
+func (a *jdwpAgent) loadedClass(ctx context.Context, r *Runner, className string) (*class.Class, error) {
+	if !r.loader.IsDefined(className) {
+		return nil, jdwpError(jdwpErrInvalidClass)
+	}
+
+	return r.loader.Load(ctx, className)
+}
+
+// method returns the method behind a method ID, which is its index in the class plus one.
+func (a *jdwpAgent) method(c *class.Class, id uint64) (*class.Method, error) {
+	if id < 1 || id > uint64(len(c.Methods)) {
+		return nil, jdwpError(jdwpErrInvalidMethodID)
+	}
+
+	return &c.Methods[id-1], nil
+}
+
+// methodID finds the ID of a method of a frame, which holds a copy of it.
+func methodID(c *class.Class, method class.Method) (uint64, bool) {
+	for i, m := range c.Methods {
+		if m.NameIndex == method.NameIndex && m.DescriptorIndex == method.DescriptorIndex {
+			return uint64(i + 1), true
+		}
+	}
+
+	return 0, false
+}
+
+// fieldID combines the reference type and the index of a field.
+func fieldID(classID uint64, index int) uint64 {
+	return (classID&jdwpIDMask)<<16 | uint64(index+1)
+}
+
+// field returns the class declaring a field and the field behind a field ID.
+func (a *jdwpAgent) field(ctx context.Context, r *Runner, id uint64) (*class.Class, *class.Field, error) {
+	c, err := a.class(ctx, r, jdwpClassIDBase+id>>16)
+	if err != nil {
+		return nil, nil, jdwpError(jdwpErrInvalidFieldID)
+	}
+
+	index := int(id&0xffff) - 1
+	if index < 0 || index >= len(c.Fields) {
+		return nil, nil, jdwpError(jdwpErrInvalidFieldID)
+	}
+
+	return c, &c.Fields[index], nil
+}
+
+// location describes the instruction at pc of a frame, ok is false for frames the debugger can
+// not see, like the one InvokeStatic calls from.
+func (a *jdwpAgent) location(ctx context.Context, r *Runner, className string, method class.Method, pc int) (jdwpLocation, bool) {
+	c, err := a.loadedClass(ctx, r, className)
+	if err != nil {
+		return jdwpLocation{}, false
+	}
+
+	id, ok := methodID(c, method)
+	if !ok {
+		return jdwpLocation{}, false
+	}
+
+	return jdwpLocation{typeTag: typeTag(c), classID: a.classID(c.Name), methodID: id, index: int64(pc)}, true
+}
+
+// object returns the heap reference behind an object ID. Thread IDs stand for their Thread objects.
+func (a *jdwpAgent) object(r *Runner, id uint64) (stack.Reference, error) {
+	if id >= jdwpThreadIDBase {
+		t, err := a.thread(id)
+		if err != nil || t.thread == stack.Null {
+			return stack.Null, jdwpError(jdwpErrInvalidObject)
+		}
+
+		return t.thread, nil
+	}
+
+	ref := stack.Reference(id)
+	if _, err := r.heap.get(ref); err != nil {
+		return stack.Null, jdwpError(jdwpErrInvalidObject)
+	}
+
+	return ref, nil
+}
+
+func typeTag(c *class.Class) byte {
+	switch {
+	case c.IsArray():
+		return jdwpTypeTagArray
+	case c.IsInterface():
+		return jdwpTypeTagInterface
+	default:
+		return jdwpTypeTagClass
+	}
+}
+
+// signature returns the JNI signature of a class, e.g. Ljava/lang/String; or [I.
+func signature(className string) string {
+	if className[0] == '[' {
+		return className
+	}
+
+	return "L" + className + ";"
+}
+
+// objectTag returns the tag of the value of a reference, which depends on the class of the object.
+func (r *Runner) objectTag(ref stack.Reference) byte {
+	if ref == stack.Null {
+		return 'L'
+	}
+
+	className, err := r.runtimeClassName(ref)
+	switch {
+	case err != nil:
+		return 'L'
+	case className == "java/lang/String":
+		return 's'
+	case className == "java/lang/Class":
+		return 'c'
+	case className[0] == '[':
+		return '['
+	default:
+		return 'L'
+	}
+}
+
+// taggedValue tags a value of a field, local variable or array with the given descriptor.
+func (r *Runner) taggedValue(descriptor string, value stack.Value) jdwpValue {
+	switch descriptor[0] {
+	case 'L', '[':
+		return jdwpValue{tag: r.objectTag(referenceOf(value)), value: value}
+	default:
+		return jdwpValue{tag: descriptor[0], value: value}
+	}
+}
+
+// classStatus returns the JDWP class status flags of a loaded class.
+func (r *Runner) classStatus(className string) int32 {
+	switch r.initState(className) {
+	case classInitialized:
+		return jdwpClassVerified | jdwpClassPrepared | jdwpClassInitialized
+	case classErroneous:
+		return jdwpClassVerified | jdwpClassPrepared | jdwpClassError
+	default:
+		return jdwpClassVerified | jdwpClassPrepared
+	}
+}
+
+// jdwpThreadStatus maps the state of a thread to the thread status of JDWP.
+func jdwpThreadStatus(state threadState) int32 {
+	switch state {
+	case threadBlocked:
+		return jdwpThreadMonitor
+	case threadWaiting, threadTimedWaiting:
+		return jdwpThreadWait
+	case threadSleeping:
+		return jdwpThreadSleeping
+	default:
+		return jdwpThreadRunning
+	}
+}
@@ -0,0 +1,1091 @@
+package jvm
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+)
+
+// Command sets.
+const (
+	jdwpCommandSetVM            = 1
+	jdwpCommandSetReferenceType = 2
+	jdwpCommandSetClassType     = 3
+	jdwpCommandSetMethod        = 6
+	jdwpCommandSetObject        = 9
+	jdwpCommandSetString        = 10
+	jdwpCommandSetThread        = 11
+	jdwpCommandSetThreadGroup   = 12
+	jdwpCommandSetArray         = 13
+	jdwpCommandSetClassLoader   = 14
+	jdwpCommandSetEventRequest  = 15
+	jdwpCommandSetStackFrame    = 16
+	jdwpCommandSetClassObject   = 17
+	jdwpCommandSetEvent         = 64
+
+	// jdwpCommandComposite is the command of the event command set
+	jdwpCommandComposite = 100
+)
+
+// jdwpVersion is the JDK version the agent claims to implement the protocol of.
+const jdwpVersion = 17
+
+type jdwpCommand func(a *jdwpAgent, ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error
+
+// jdwpCommands are the implemented commands by command set and command.
+var jdwpCommands = map[[2]byte]jdwpCommand{
+	{jdwpCommandSetVM, 1}:  (*jdwpAgent).vmVersion,
+	{jdwpCommandSetVM, 2}:  (*jdwpAgent).vmClassesBySignature,
+	{jdwpCommandSetVM, 3}:  (*jdwpAgent).vmAllClasses,
+	{jdwpCommandSetVM, 4}:  (*jdwpAgent).vmAllThreads,
+	{jdwpCommandSetVM, 5}:  (*jdwpAgent).vmTopLevelThreadGroups,
+	{jdwpCommandSetVM, 6}:  (*jdwpAgent).vmDispose,
+	{jdwpCommandSetVM, 7}:  (*jdwpAgent).vmIDSizes,
+	{jdwpCommandSetVM, 8}:  (*jdwpAgent).vmSuspend,
+	{jdwpCommandSetVM, 9}:  (*jdwpAgent).vmResume,
+	{jdwpCommandSetVM, 10}: (*jdwpAgent).vmExit,
+	{jdwpCommandSetVM, 12}: (*jdwpAgent).vmCapabilities,
+	{jdwpCommandSetVM, 13}: (*jdwpAgent).vmClassPaths,
+	{jdwpCommandSetVM, 14}: (*jdwpAgent).ignore,
+	{jdwpCommandSetVM, 15}: (*jdwpAgent).ignore,
+	{jdwpCommandSetVM, 16}: (*jdwpAgent).ignore,
+	{jdwpCommandSetVM, 17}: (*jdwpAgent).vmCapabilitiesNew,
+	{jdwpCommandSetVM, 20}: (*jdwpAgent).vmAllClassesWithGeneric,
+
+	{jdwpCommandSetReferenceType, 1}:  (*jdwpAgent).typeSignature,
+	{jdwpCommandSetReferenceType, 2}:  (*jdwpAgent).typeClassLoader,
+	{jdwpCommandSetReferenceType, 3}:  (*jdwpAgent).typeModifiers,
+	{jdwpCommandSetReferenceType, 4}:  (*jdwpAgent).typeFields,
+	{jdwpCommandSetReferenceType, 5}:  (*jdwpAgent).typeMethods,
+	{jdwpCommandSetReferenceType, 6}:  (*jdwpAgent).typeGetValues,
+	{jdwpCommandSetReferenceType, 7}:  (*jdwpAgent).typeSourceFile,
+	{jdwpCommandSetReferenceType, 8}:  (*jdwpAgent).typeNestedTypes,
+	{jdwpCommandSetReferenceType, 9}:  (*jdwpAgent).typeStatus,
+	{jdwpCommandSetReferenceType, 10}: (*jdwpAgent).typeInterfaces,
+	{jdwpCommandSetReferenceType, 11}: (*jdwpAgent).typeClassObject,
+	{jdwpCommandSetReferenceType, 12}: (*jdwpAgent).absentInformation,
+	{jdwpCommandSetReferenceType, 13}: (*jdwpAgent).typeSignatureWithGeneric,
+	{jdwpCommandSetReferenceType, 14}: (*jdwpAgent).typeFieldsWithGeneric,
+	{jdwpCommandSetReferenceType, 15}: (*jdwpAgent).typeMethodsWithGeneric,
+
+	{jdwpCommandSetClassType, 1}: (*jdwpAgent).classSuperclass,
+
+	{jdwpCommandSetMethod, 1}: (*jdwpAgent).methodLineTable,
+	{jdwpCommandSetMethod, 2}: (*jdwpAgent).methodVariableTable,
+	{jdwpCommandSetMethod, 3}: (*jdwpAgent).methodBytecodes,
+	{jdwpCommandSetMethod, 5}: (*jdwpAgent).methodVariableTableWithGeneric,
+
+	{jdwpCommandSetObject, 1}: (*jdwpAgent).objectReferenceType,
+	{jdwpCommandSetObject, 2}: (*jdwpAgent).objectGetValues,
+	{jdwpCommandSetObject, 9}: (*jdwpAgent).objectIsCollected,
+
+	{jdwpCommandSetString, 1}: (*jdwpAgent).stringValue,
+
+	{jdwpCommandSetThread, 1}:  (*jdwpAgent).threadName,
+	{jdwpCommandSetThread, 2}:  (*jdwpAgent).threadSuspend,
+	{jdwpCommandSetThread, 3}:  (*jdwpAgent).threadResume,
+	{jdwpCommandSetThread, 4}:  (*jdwpAgent).threadStatus,
+	{jdwpCommandSetThread, 5}:  (*jdwpAgent).threadThreadGroup,
+	{jdwpCommandSetThread, 6}:  (*jdwpAgent).threadFrames,
+	{jdwpCommandSetThread, 7}:  (*jdwpAgent).threadFrameCount,
+	{jdwpCommandSetThread, 12}: (*jdwpAgent).threadSuspendCount,
+
+	{jdwpCommandSetThreadGroup, 1}: (*jdwpAgent).groupName,
+	{jdwpCommandSetThreadGroup, 2}: (*jdwpAgent).groupParent,
+	{jdwpCommandSetThreadGroup, 3}: (*jdwpAgent).groupChildren,
+
+	{jdwpCommandSetArray, 1}: (*jdwpAgent).arrayLength,
+	{jdwpCommandSetArray, 2}: (*jdwpAgent).arrayGetValues,
+
+	// all classes are visible to every class loader
+	{jdwpCommandSetClassLoader, 1}: (*jdwpAgent).vmAllClasses,
+
+	{jdwpCommandSetEventRequest, 1}: (*jdwpAgent).eventRequestSet,
+	{jdwpCommandSetEventRequest, 2}: (*jdwpAgent).eventRequestClear,
+	{jdwpCommandSetEventRequest, 3}: (*jdwpAgent).eventRequestClearAllBreakpoints,
+
+	{jdwpCommandSetStackFrame, 1}: (*jdwpAgent).frameGetValues,
+	{jdwpCommandSetStackFrame, 3}: (*jdwpAgent).frameThisObject,
+
+	{jdwpCommandSetClassObject, 1}: (*jdwpAgent).classObjectReflectedType,
+}
+
+func (a *jdwpAgent) command(ctx context.Context, r *Runner, commandSet byte, command byte, in *jdwpReader, out *jdwpWriter) error {
+	handler, ok := jdwpCommands[[2]byte{commandSet, command}]
+	if !ok {
+		return jdwpError(jdwpErrNotImplemented)
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return handler(a, ctx, r, in, out)
+}
+
+func (a *jdwpAgent) ignore(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	return nil
+}
+
+func (a *jdwpAgent) absentInformation(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	return jdwpError(jdwpErrAbsentInformation)
+}
+
+func (a *jdwpAgent) vmVersion(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	out.string("swell")
+	out.int(1)
+	out.int(jdwpVersion)
+	out.string(fmt.Sprint(jdwpVersion))
+	out.string("swell")
+	return nil
+}
+
+// loadedClasses returns the classes loaded so far, sorted by name.
+func (a *jdwpAgent) loadedClasses(r *Runner) ([]*class.Class, error) {
+	snapshots, err := r.loader.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	classes := make([]*class.Class, len(snapshots))
+	for i, snapshot := range snapshots {
+		classes[i] = snapshot.Class
+	}
+
+	slices.SortFunc(classes, func(a, b *class.Class) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return classes, nil
+}
+
+func (a *jdwpAgent) vmClassesBySignature(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	sig := in.string()
+	className := strings.TrimSuffix(strings.TrimPrefix(sig, "L"), ";")
+	if strings.HasPrefix(sig, "[") {
+		className = sig
+	}
+
+	c, err := a.loadedClass(ctx, r, className)
+	if err != nil {
+		out.int(0)
+		return nil
+	}
+
+	out.int(1)
+	out.byte(typeTag(c))
+	out.id(a.classID(c.Name))
+	out.int(r.classStatus(c.Name))
+	return nil
+}
+
+func (a *jdwpAgent) writeAllClasses(r *Runner, out *jdwpWriter, generic bool) error {
+	classes, err := a.loadedClasses(r)
+	if err != nil {
+		return err
+	}
+
+	out.int(int32(len(classes)))
+	for _, c := range classes {
+		out.byte(typeTag(c))
+		out.id(a.classID(c.Name))
+		out.string(signature(c.Name))
+		if generic {
+			out.string("")
+		}
+		out.int(r.classStatus(c.Name))
+	}
+
+	return nil
+}
+
+func (a *jdwpAgent) vmAllClasses(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	return a.writeAllClasses(r, out, false)
+}
+
+func (a *jdwpAgent) vmAllClassesWithGeneric(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	return a.writeAllClasses(r, out, true)
+}
+
+func (a *jdwpAgent) vmAllThreads(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	out.int(int32(len(r.threads)))
+	for _, t := range r.threads {
+		out.id(a.threadID(t))
+	}
+
+	return nil
+}
+
+func (a *jdwpAgent) vmTopLevelThreadGroups(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	out.int(1)
+	out.id(jdwpThreadGroupID)
+	return nil
+}
+
+// vmDispose cancels the event requests and resumes the threads, the debugger closes the connection afterwards.
+func (a *jdwpAgent) vmDispose(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	a.requests = nil
+	a.resumeAll()
+	return nil
+}
+
+func (a *jdwpAgent) vmIDSizes(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	// field, method, object, reference type and frame IDs
+	for range 5 {
+		out.int(jdwpIDSize)
+	}
+
+	return nil
+}
+
+func (a *jdwpAgent) vmSuspend(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	a.suspendAll(r)
+	return nil
+}
+
+func (a *jdwpAgent) vmResume(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	for _, t := range slices.Collect(maps.Keys(a.suspended)) {
+		a.resume(t)
+	}
+
+	return nil
+}
+
+// vmExit stops all threads of the VM, RunMain returns an error with the exit code.
+func (a *jdwpAgent) vmExit(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	code := in.int()
+	r.stopAll(fmt.Errorf("terminated by the debugger with exit code %d", code))
+	a.resumeAll()
+	return nil
+}
+
+func (a *jdwpAgent) vmCapabilities(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	// only canGetBytecodes
+	for i := range 7 {
+		out.bool(i == 2)
+	}
+
+	return nil
+}
+
+func (a *jdwpAgent) vmCapabilitiesNew(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	// canGetBytecodes and canRequestVMDeathEvent
+	for i := range 32 {
+		out.bool(i == 2 || i == 13)
+	}
+
+	return nil
+}
+
+func (a *jdwpAgent) vmClassPaths(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	classPath := r.loader.ClassPath()
+
+	out.string("")
+	out.int(int32(len(classPath)))
+	for _, path := range classPath {
+		out.string(path)
+	}
+
+	out.int(0)
+	return nil
+}
+
+func (a *jdwpAgent) typeSignature(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	c, err := a.class(ctx, r, in.id())
+	if err != nil {
+		return err
+	}
+
+	out.string(signature(c.Name))
+	return nil
+}
+
+func (a *jdwpAgent) typeSignatureWithGeneric(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	err := a.typeSignature(ctx, r, in, out)
+	out.string("")
+	return err
+}
+
+// typeClassLoader answers with the bootstrap class loader, which defines all classes.
+func (a *jdwpAgent) typeClassLoader(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	_, err := a.class(ctx, r, in.id())
+	if err != nil {
+		return err
+	}
+
+	out.id(0)
+	return nil
+}
+
+func (a *jdwpAgent) typeModifiers(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	c, err := a.class(ctx, r, in.id())
+	if err != nil {
+		return err
+	}
+
+	out.int(int32(c.AccessFlags))
+	return nil
+}
+
+func (a *jdwpAgent) writeFields(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter, generic bool) error {
+	id := in.id()
+	c, err := a.class(ctx, r, id)
+	if err != nil {
+		return err
+	}
+
+	out.int(int32(len(c.Fields)))
+	for i, field := range c.Fields {
+		name, err := c.ConstantPool.GetUtf8(field.NameIndex)
+		if err != nil {
+			return err
+		}
+
+		descriptor, err := c.ConstantPool.GetUtf8(field.DescriptorIndex)
+		if err != nil {
+			return err
+		}
+
+		out.id(fieldID(id, i))
+		out.string(name)
+		out.string(descriptor)
+		if generic {
+			out.string("")
+		}
+		out.int(int32(field.AccessFlags))
+	}
+
+	return nil
+}
+
+func (a *jdwpAgent) typeFields(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	return a.writeFields(ctx, r, in, out, false)
+}
+
+func (a *jdwpAgent) typeFieldsWithGeneric(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	return a.writeFields(ctx, r, in, out, true)
+}
+
+func (a *jdwpAgent) writeMethods(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter, generic bool) error {
+	c, err := a.class(ctx, r, in.id())
+	if err != nil {
+		return err
+	}
+
+	out.int(int32(len(c.Methods)))
+	for i, method := range c.Methods {
+		name, err := c.ConstantPool.GetUtf8(method.NameIndex)
+		if err != nil {
+			return err
+		}
+
+		descriptor, err := c.ConstantPool.GetUtf8(method.DescriptorIndex)
+		if err != nil {
+			return err
+		}
+
+		out.id(uint64(i + 1))
+		out.string(name)
+		out.string(descriptor)
+		if generic {
+			out.string("")
+		}
+		out.int(int32(method.AccessFlags))
+	}
+
+	return nil
+}
+
+func (a *jdwpAgent) typeMethods(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	return a.writeMethods(ctx, r, in, out, false)
+}
+
+func (a *jdwpAgent) typeMethodsWithGeneric(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	return a.writeMethods(ctx, r, in, out, true)
+}
+
+// fieldKey returns the key of a field and its descriptor.
+func fieldKey(c *class.Class, field *class.Field) (loader.FieldKey, error) {
+	name, err := c.ConstantPool.GetUtf8(field.NameIndex)
+	if err != nil {
+		return loader.FieldKey{}, err
+	}
+
+	descriptor, err := c.ConstantPool.GetUtf8(field.DescriptorIndex)
+	if err != nil {
+		return loader.FieldKey{}, err
+	}
+
+	return loader.FieldKey{Class: c.Name, Name: name, Descriptor: descriptor}, nil
+}
+
+// typeGetValues reads static fields.
+func (a *jdwpAgent) typeGetValues(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	_, err := a.class(ctx, r, in.id())
+	if err != nil {
+		return err
+	}
+
+	count := in.int()
+	out.int(count)
+	for range count {
+		c, field, err := a.field(ctx, r, in.id())
+		if err != nil {
+			return err
+		}
+
+		key, err := fieldKey(c, field)
+		if err != nil {
+			return err
+		}
+
+		value, err := r.loader.GetField(key)
+		if err != nil {
+			return jdwpError(jdwpErrInvalidFieldID)
+		}
+
+		out.value(r.taggedValue(key.Descriptor, value))
+	}
+
+	return nil
+}
+
+func (a *jdwpAgent) typeSourceFile(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	c, err := a.class(ctx, r, in.id())
+	if err != nil {
+		return err
+	}
+
+	sourceFile, ok := c.SourceFile()
+	if !ok {
+		return jdwpError(jdwpErrAbsentInformation)
+	}
+
+	out.string(sourceFile)
+	return nil
+}
+
+func (a *jdwpAgent) typeNestedTypes(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	_, err := a.class(ctx, r, in.id())
+	if err != nil {
+		return err
+	}
+
+	out.int(0)
+	return nil
+}
+
+func (a *jdwpAgent) typeStatus(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	c, err := a.class(ctx, r, in.id())
+	if err != nil {
+		return err
+	}
+
+	out.int(r.classStatus(c.Name))
+	return nil
+}
+
+func (a *jdwpAgent) typeInterfaces(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	c, err := a.class(ctx, r, in.id())
+	if err != nil {
+		return err
+	}
+
+	names, err := c.InterfaceNames()
+	if err != nil {
+		return err
+	}
+
+	out.int(int32(len(names)))
+	for _, name := range names {
+		out.id(a.classID(name))
+	}
+
+	return nil
+}
+
+func (a *jdwpAgent) typeClassObject(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	c, err := a.class(ctx, r, in.id())
+	if err != nil {
+		return err
+	}
+
+	mirror, ok := r.mirrors[c.Name]
+	if !ok {
+		return jdwpError(jdwpErrInvalidClass)
+	}
+
+	out.id(uint64(mirror.Value))
+	return nil
+}
+
+func (a *jdwpAgent) classSuperclass(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	c, err := a.class(ctx, r, in.id())
+	if err != nil {
+		return err
+	}
+
+	superClassName, ok, err := c.SuperClassName()
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		out.id(0)
+		return nil
+	}
+
+	out.id(a.classID(superClassName))
+	return nil
+}
+
+// methodCode returns the method a command refers to and its code, nil for native and abstract methods.
+func (a *jdwpAgent) methodCode(ctx context.Context, r *Runner, in *jdwpReader) (*class.Class, *class.Method, *class.CodeAttribute, error) {
+	c, err := a.class(ctx, r, in.id())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	method, err := a.method(c, in.id())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if method.IsNative() || method.IsAbstract() {
+		return c, method, nil, nil
+	}
+
+	code, err := method.CodeAttribute()
+	return c, method, code, err
+}
+
+func (a *jdwpAgent) methodLineTable(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	_, _, code, err := a.methodCode(ctx, r, in)
+	if err != nil {
+		return err
+	}
+
+	if code == nil {
+		out.long(-1)
+		out.long(-1)
+		out.int(0)
+		return nil
+	}
+
+	var entries []class.LineNumberTableEntry
+	for _, attribute := range code.Attributes {
+		if table, ok := attribute.(class.LineNumberTableAttribute); ok {
+			entries = append(entries, table.Table...)
+		}
+	}
+
+	out.long(0)
+	out.long(int64(len(code.Code) - 1))
+	out.int(int32(len(entries)))
+	for _, entry := range entries {
+		out.long(int64(entry.StartPc))
+		out.int(int32(entry.LineNumber))
+	}
+
+	return nil
+}
+
+func (a *jdwpAgent) writeVariableTable(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter, generic bool) error {
+	c, method, code, err := a.methodCode(ctx, r, in)
+	if err != nil {
+		return err
+	}
+
+	if code == nil {
+		return jdwpError(jdwpErrAbsentInformation)
+	}
+
+	variables, ok := code.LocalVariables()
+	if !ok {
+		return jdwpError(jdwpErrAbsentInformation)
+	}
+
+	descriptor, err := c.ConstantPool.GetUtf8(method.DescriptorIndex)
+	if err != nil {
+		return err
+	}
+
+	methodDescriptor, err := class.NewMethodDescriptor(descriptor)
+	if err != nil {
+		return err
+	}
+
+	// the slots taken by the arguments, long and double take two
+	argumentSlots := 0
+	if !method.IsStatic() {
+		argumentSlots++
+	}
+
+	for _, parameter := range methodDescriptor.Parameters {
+		argumentSlots++
+		if parameter == class.BaseType(class.LONG) || parameter == class.BaseType(class.DOUBLE) {
+			argumentSlots++
+		}
+	}
+
+	out.int(int32(argumentSlots))
+	out.int(int32(len(variables)))
+	for _, variable := range variables {
+		name, err := c.ConstantPool.GetUtf8(variable.NameIndex)
+		if err != nil {
+			return err
+		}
+
+		variableDescriptor, err := c.ConstantPool.GetUtf8(variable.DescriptorIndex)
+		if err != nil {
+			return err
+		}
+
+		out.long(int64(variable.StartPc))
+		out.string(name)
+		out.string(variableDescriptor)
+		if generic {
+			out.string("")
+		}
+		out.int(int32(variable.Length))
+		out.int(int32(variable.Index))
+	}
+
+	return nil
+}
+
+func (a *jdwpAgent) methodVariableTable(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	return a.writeVariableTable(ctx, r, in, out, false)
+}
+
+func (a *jdwpAgent) methodVariableTableWithGeneric(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	return a.writeVariableTable(ctx, r, in, out, true)
+}
+
+func (a *jdwpAgent) methodBytecodes(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	_, _, code, err := a.methodCode(ctx, r, in)
+	if err != nil {
+		return err
+	}
+
+	if code == nil {
+		out.int(0)
+		return nil
+	}
+
+	out.int(int32(len(code.Code)))
+	out.Write(code.Code)
+	return nil
+}
+
+func (a *jdwpAgent) objectReferenceType(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	ref, err := a.object(r, in.id())
+	if err != nil {
+		return err
+	}
+
+	className, err := r.runtimeClassName(ref)
+	if err != nil {
+		return err
+	}
+
+	c, err := a.loadedClass(ctx, r, className)
+	if err != nil {
+		return err
+	}
+
+	out.byte(typeTag(c))
+	out.id(a.classID(className))
+	return nil
+}
+
+func (a *jdwpAgent) objectGetValues(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	ref, err := a.object(r, in.id())
+	if err != nil {
+		return err
+	}
+
+	object, err := r.heap.GetObject(ref)
+	if err != nil {
+		return jdwpError(jdwpErrInvalidObject)
+	}
+
+	count := in.int()
+	out.int(count)
+	for range count {
+		c, field, err := a.field(ctx, r, in.id())
+		if err != nil {
+			return err
+		}
+
+		key, err := fieldKey(c, field)
+		if err != nil {
+			return err
+		}
+
+		value, err := object.GetFieldValue(key)
+		if err != nil {
+			return jdwpError(jdwpErrInvalidFieldID)
+		}
+
+		out.value(r.taggedValue(key.Descriptor, value))
+	}
+
+	return nil
+}
+
+// objectIsCollected reports whether the object has been collected, the debugger does not keep objects alive.
+func (a *jdwpAgent) objectIsCollected(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	_, err := a.object(r, in.id())
+	out.bool(err != nil)
+	return nil
+}
+
+func (a *jdwpAgent) stringValue(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	ref, err := a.object(r, in.id())
+	if err != nil {
+		return err
+	}
+
+	s, err := r.goString(ref)
+	if err != nil {
+		return jdwpError(jdwpErrInvalidObject)
+	}
+
+	out.string(s)
+	return nil
+}
+
+func (a *jdwpAgent) threadName(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	t, err := a.thread(in.id())
+	if err != nil {
+		return err
+	}
+
+	out.string(t.threadName())
+	return nil
+}
+
+func (a *jdwpAgent) threadSuspend(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	t, err := a.thread(in.id())
+	if err != nil {
+		return err
+	}
+
+	a.suspended[t]++
+	return nil
+}
+
+func (a *jdwpAgent) threadResume(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	t, err := a.thread(in.id())
+	if err != nil {
+		return err
+	}
+
+	a.resume(t)
+	return nil
+}
+
+func (a *jdwpAgent) threadStatus(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	t, err := a.thread(in.id())
+	if err != nil {
+		return err
+	}
+
+	if !a.alive(r, t) {
+		out.int(jdwpThreadZombie)
+	} else {
+		out.int(jdwpThreadStatus(t.state))
+	}
+
+	if a.suspended[t] > 0 {
+		out.int(jdwpSuspended)
+	} else {
+		out.int(0)
+	}
+
+	return nil
+}
+
+func (a *jdwpAgent) threadThreadGroup(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	_, err := a.thread(in.id())
+	if err != nil {
+		return err
+	}
+
+	out.id(jdwpThreadGroupID)
+	return nil
+}
+
+// suspendedThread returns a thread the debugger has suspended, only their stacks can be inspected.
+func (a *jdwpAgent) suspendedThread(r *Runner, id uint64) (*Runner, error) {
+	t, err := a.thread(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.suspended[t] == 0 || !a.alive(r, t) {
+		return nil, jdwpError(jdwpErrThreadNotSuspended)
+	}
+
+	return t, nil
+}
+
+// visibleFrames returns the frames of t the debugger can see along with their locations.
+func (a *jdwpAgent) visibleFrames(ctx context.Context, r *Runner, t *Runner) ([]stack.TraceFrame, []jdwpLocation) {
+	var frames []stack.TraceFrame
+	var locations []jdwpLocation
+	for _, frame := range t.stack.Trace() {
+		location, ok := a.location(ctx, r, frame.ClassName, frame.Method, frame.Pc)
+		if ok {
+			frames = append(frames, frame)
+			locations = append(locations, location)
+		}
+	}
+
+	return frames, locations
+}
+
+func (a *jdwpAgent) threadFrames(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	t, err := a.suspendedThread(r, in.id())
+	if err != nil {
+		return err
+	}
+
+	start, length := int(in.int()), int(in.int())
+
+	_, locations := a.visibleFrames(ctx, r, t)
+	if length == -1 {
+		length = len(locations) - start
+	}
+
+	if start < 0 || length < 0 || start+length > len(locations) {
+		return jdwpError(jdwpErrInvalidIndex)
+	}
+
+	out.int(int32(length))
+	for depth := start; depth < start+length; depth++ {
+		a.nextFrameID++
+		id := jdwpFrameIDBase + a.nextFrameID
+		a.frames[id] = jdwpFrame{thread: t, depth: depth}
+		out.id(id)
+		out.location(locations[depth])
+	}
+
+	return nil
+}
+
+func (a *jdwpAgent) threadFrameCount(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	t, err := a.suspendedThread(r, in.id())
+	if err != nil {
+		return err
+	}
+
+	_, locations := a.visibleFrames(ctx, r, t)
+	out.int(int32(len(locations)))
+	return nil
+}
+
+func (a *jdwpAgent) threadSuspendCount(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	t, err := a.thread(in.id())
+	if err != nil {
+		return err
+	}
+
+	out.int(int32(a.suspended[t]))
+	return nil
+}
+
+func (a *jdwpAgent) groupName(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	if in.id() != jdwpThreadGroupID {
+		return jdwpError(jdwpErrInvalidObject)
+	}
+
+	out.string("main")
+	return nil
+}
+
+func (a *jdwpAgent) groupParent(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	if in.id() != jdwpThreadGroupID {
+		return jdwpError(jdwpErrInvalidObject)
+	}
+
+	out.id(0)
+	return nil
+}
+
+func (a *jdwpAgent) groupChildren(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	if in.id() != jdwpThreadGroupID {
+		return jdwpError(jdwpErrInvalidObject)
+	}
+
+	err := a.vmAllThreads(ctx, r, in, out)
+	out.int(0)
+	return err
+}
+
+func (a *jdwpAgent) array(r *Runner, id uint64) (*Array, error) {
+	ref, err := a.object(r, id)
+	if err != nil {
+		return nil, err
+	}
+
+	array, err := r.heap.GetArray(ref)
+	if err != nil {
+		return nil, jdwpError(jdwpErrInvalidObject)
+	}
+
+	return array, nil
+}
+
+func (a *jdwpAgent) arrayLength(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	array, err := a.array(r, in.id())
+	if err != nil {
+		return err
+	}
+
+	out.int(int32(len(array.items)))
+	return nil
+}
+
+// arrayGetValues writes an array region, the values of primitive arrays are not tagged.
+func (a *jdwpAgent) arrayGetValues(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	array, err := a.array(r, in.id())
+	if err != nil {
+		return err
+	}
+
+	first, length := int(in.int()), int(in.int())
+	if first < 0 || length < 0 || first+length > len(array.items) {
+		return jdwpError(jdwpErrInvalidLength)
+	}
+
+	elementDescriptor := array.ClassName()[1:]
+	primitive := elementDescriptor[0] != 'L' && elementDescriptor[0] != '['
+
+	out.byte(elementDescriptor[0])
+	out.int(int32(length))
+	for _, item := range array.items[first : first+length] {
+		value := r.taggedValue(elementDescriptor, item)
+		if primitive {
+			out.untagged(value)
+		} else {
+			out.value(value)
+		}
+	}
+
+	return nil
+}
+
+func (a *jdwpAgent) eventRequestSet(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	q, err := a.readRequest(ctx, r, in)
+	if err != nil {
+		return err
+	}
+
+	a.nextRequestID++
+	q.id = a.nextRequestID
+	a.requests = append(a.requests, q)
+
+	out.int(q.id)
+	return nil
+}
+
+func (a *jdwpAgent) eventRequestClear(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	kind, id := in.byte(), in.int()
+	a.requests = slices.DeleteFunc(a.requests, func(q *jdwpRequest) bool {
+		return q.kind == kind && q.id == id
+	})
+
+	return nil
+}
+
+func (a *jdwpAgent) eventRequestClearAllBreakpoints(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	a.requests = slices.DeleteFunc(a.requests, func(q *jdwpRequest) bool {
+		return q.kind == jdwpEventBreakpoint
+	})
+
+	return nil
+}
+
+// frame returns the frame behind a frame ID of thread t.
+func (a *jdwpAgent) frame(ctx context.Context, r *Runner, in *jdwpReader) (stack.TraceFrame, error) {
+	t, err := a.suspendedThread(r, in.id())
+	if err != nil {
+		return stack.TraceFrame{}, err
+	}
+
+	id := in.id()
+	frame, ok := a.frames[id]
+	if !ok || frame.thread != t {
+		return stack.TraceFrame{}, jdwpError(jdwpErrInvalidFrameID)
+	}
+
+	frames, _ := a.visibleFrames(ctx, r, t)
+	if frame.depth >= len(frames) {
+		return stack.TraceFrame{}, jdwpError(jdwpErrInvalidFrameID)
+	}
+
+	return frames[frame.depth], nil
+}
+
+func (a *jdwpAgent) frameGetValues(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	frame, err := a.frame(ctx, r, in)
+	if err != nil {
+		return err
+	}
+
+	count := in.int()
+	out.int(count)
+	for range count {
+		slot, tag := int(in.int()), in.byte()
+		if slot < 0 || slot >= len(frame.LocalVariables) || frame.LocalVariables[slot] == nil {
+			return jdwpError(jdwpErrInvalidSlot)
+		}
+
+		out.value(r.taggedValue(string(tag), frame.LocalVariables[slot]))
+	}
+
+	return nil
+}
+
+func (a *jdwpAgent) frameThisObject(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	frame, err := a.frame(ctx, r, in)
+	if err != nil {
+		return err
+	}
+
+	if frame.Method.IsStatic() || frame.Method.IsNative() || len(frame.LocalVariables) == 0 {
+		out.value(jdwpValue{tag: 'L', value: stack.ReferenceValue{Value: stack.Null}})
+		return nil
+	}
+
+	out.value(r.taggedValue("L", frame.LocalVariables[0]))
+	return nil
+}
+
+func (a *jdwpAgent) classObjectReflectedType(ctx context.Context, r *Runner, in *jdwpReader, out *jdwpWriter) error {
+	ref, err := a.object(r, in.id())
+	if err != nil {
+		return err
+	}
+
+	name, ok := r.mirrorNames[ref]
+	if !ok {
+		return jdwpError(jdwpErrInvalidObject)
+	}
+
+	c, err := a.loadedClass(ctx, r, name)
+	if err != nil {
+		return err
+	}
+
+	out.byte(typeTag(c))
+	out.id(a.classID(c.Name))
+	return nil
+}
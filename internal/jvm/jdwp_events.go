@@ -0,0 +1,430 @@
+package jvm
+
+import (
+	"context"
+	"strings"
+
+	"github.com/m4tthewde/swell/internal/class"
+)
+
+// Event kinds, the agent reports the ones handled below and accepts requests for the others.
+const (
+	jdwpEventSingleStep   = 1
+	jdwpEventBreakpoint   = 2
+	jdwpEventThreadStart  = 6
+	jdwpEventThreadDeath  = 7
+	jdwpEventClassPrepare = 8
+	jdwpEventVMStart      = 90
+	jdwpEventVMDeath      = 99
+)
+
+// Suspend policies of event requests.
+const (
+	jdwpSuspendNone        = 0
+	jdwpSuspendEventThread = 1
+	jdwpSuspendAll         = 2
+)
+
+// Modifier kinds of event requests.
+const (
+	jdwpModCount           = 1
+	jdwpModConditional     = 2
+	jdwpModThreadOnly      = 3
+	jdwpModClassOnly       = 4
+	jdwpModClassMatch      = 5
+	jdwpModClassExclude    = 6
+	jdwpModLocationOnly    = 7
+	jdwpModExceptionOnly   = 8
+	jdwpModFieldOnly       = 9
+	jdwpModStep            = 10
+	jdwpModInstanceOnly    = 11
+	jdwpModSourceNameMatch = 12
+	jdwpModPlatformThreads = 13
+)
+
+// Step sizes and depths.
+const (
+	jdwpStepMin  = 0
+	jdwpStepLine = 1
+
+	jdwpStepInto = 0
+	jdwpStepOver = 1
+	jdwpStepOut  = 2
+)
+
+// jdwpEvent is an event of a composite event command, data follows the request ID.
+type jdwpEvent struct {
+	kind      byte
+	requestID int32
+	data      []byte
+}
+
+// jdwpRequest is an event request set by the debugger.
+type jdwpRequest struct {
+	id            int32
+	kind          byte
+	suspendPolicy byte
+	// count is the number of occurrences left until the event is reported, 0 without a count modifier
+	count   int32
+	expired bool
+	thread  *Runner
+	// classOnly is the class name events are restricted to, with its subclasses
+	classOnly    string
+	classMatch   []string
+	classExclude []string
+	breakpoint   *jdwpBreakpoint
+	step         *jdwpStep
+}
+
+// jdwpBreakpoint is the location of a breakpoint.
+type jdwpBreakpoint struct {
+	className       string
+	nameIndex       uint16
+	descriptorIndex uint16
+	pc              int
+}
+
+// jdwpStep tracks the progress of a single step of a thread.
+type jdwpStep struct {
+	thread *Runner
+	size   int32
+	depth  int32
+	// frames and line are where the step started
+	frames  int
+	line    int
+	hasLine bool
+}
+
+// readRequest decodes the arguments of EventRequest.Set.
+func (a *jdwpAgent) readRequest(ctx context.Context, r *Runner, in *jdwpReader) (*jdwpRequest, error) {
+	q := &jdwpRequest{kind: in.byte(), suspendPolicy: in.byte()}
+
+	modifiers := in.int()
+	for range modifiers {
+		switch kind := in.byte(); kind {
+		case jdwpModCount:
+			q.count = in.int()
+		case jdwpModConditional:
+			in.int()
+		case jdwpModThreadOnly:
+			t, err := a.thread(in.id())
+			if err != nil {
+				return nil, err
+			}
+
+			q.thread = t
+		case jdwpModClassOnly:
+			c, err := a.class(ctx, r, in.id())
+			if err != nil {
+				return nil, err
+			}
+
+			q.classOnly = c.Name
+		case jdwpModClassMatch:
+			q.classMatch = append(q.classMatch, in.string())
+		case jdwpModClassExclude:
+			q.classExclude = append(q.classExclude, in.string())
+		case jdwpModLocationOnly:
+			breakpoint, err := a.breakpoint(ctx, r, in.location())
+			if err != nil {
+				return nil, err
+			}
+
+			q.breakpoint = breakpoint
+		case jdwpModExceptionOnly:
+			in.id()
+			in.bool()
+			in.bool()
+		case jdwpModFieldOnly:
+			in.id()
+			in.id()
+		case jdwpModStep:
+			t, err := a.thread(in.id())
+			if err != nil {
+				return nil, err
+			}
+
+			q.step = newStep(t, in.int(), in.int())
+		case jdwpModInstanceOnly:
+			in.id()
+		case jdwpModSourceNameMatch:
+			in.string()
+		case jdwpModPlatformThreads:
+		default:
+			return nil, jdwpError(jdwpErrIllegalArgument)
+		}
+	}
+
+	if q.kind == jdwpEventBreakpoint && q.breakpoint == nil || q.kind == jdwpEventSingleStep && q.step == nil {
+		return nil, jdwpError(jdwpErrIllegalArgument)
+	}
+
+	return q, nil
+}
+
+func (a *jdwpAgent) breakpoint(ctx context.Context, r *Runner, location jdwpLocation) (*jdwpBreakpoint, error) {
+	c, err := a.class(ctx, r, location.classID)
+	if err != nil {
+		return nil, err
+	}
+
+	method, err := a.method(c, location.methodID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jdwpBreakpoint{
+		className:       c.Name,
+		nameIndex:       method.NameIndex,
+		descriptorIndex: method.DescriptorIndex,
+		pc:              int(location.index),
+	}, nil
+}
+
+// newStep starts a step of a suspended thread at its current instruction.
+func newStep(t *Runner, size int32, depth int32) *jdwpStep {
+	s := &jdwpStep{thread: t, size: size, depth: depth}
+	s.frames = t.stack.Depth()
+	s.line, s.hasLine = t.currentLine()
+	return s
+}
+
+// currentLine returns the source line of the instruction the thread executes.
+func (r *Runner) currentLine() (int, bool) {
+	method, err := r.stack.CurrentMethod()
+	if err != nil {
+		return 0, false
+	}
+
+	code, err := method.CodeAttribute()
+	if err != nil {
+		return 0, false
+	}
+
+	return code.LineNumber(r.pc)
+}
+
+// completed reports whether the thread has completed the step when it is about to execute the next instruction.
+func (s *jdwpStep) completed(frames int, line int, hasLine bool) bool {
+	switch {
+	case frames < s.frames:
+		// returned from the method the step started in
+		return true
+	case s.depth == jdwpStepOut:
+		return false
+	case frames > s.frames:
+		return s.depth == jdwpStepInto
+	case s.size == jdwpStepMin:
+		return true
+	default:
+		return !hasLine || !s.hasLine || line != s.line
+	}
+}
+
+// matches applies the thread and class filters of the request.
+func (q *jdwpRequest) matches(ctx context.Context, t *Runner, className string) bool {
+	if q.expired || q.thread != nil && q.thread != t {
+		return false
+	}
+
+	name := dotted(className)
+	if len(q.classMatch) > 0 && !matchesAnyClass(q.classMatch, name) {
+		return false
+	}
+
+	if matchesAnyClass(q.classExclude, name) {
+		return false
+	}
+
+	if q.classOnly != "" {
+		subclass, err := t.isSubclassOf(ctx, className, q.classOnly)
+		if err != nil || !subclass {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesAnyClass matches a class name against patterns like java.lang.String, java.* or *.Main.
+func matchesAnyClass(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		switch {
+		case strings.HasPrefix(pattern, "*"):
+			if strings.HasSuffix(name, pattern[1:]) {
+				return true
+			}
+		case strings.HasSuffix(pattern, "*"):
+			if strings.HasPrefix(name, pattern[:len(pattern)-1]) {
+				return true
+			}
+		case pattern == name:
+			return true
+		}
+	}
+
+	return false
+}
+
+// occurred counts an occurrence of the event and reports whether it is to be reported.
+// A request with a count modifier expires once its event has been reported.
+func (q *jdwpRequest) occurred() bool {
+	if q.count == 0 {
+		return true
+	}
+
+	q.count--
+	if q.count > 0 {
+		return false
+	}
+
+	q.expired = true
+	return true
+}
+
+// report sends the events that occurred on thread t, suspends threads as the requests ask for
+// and blocks t while it is suspended.
+func (a *jdwpAgent) report(ctx context.Context, t *Runner, policy byte, events []jdwpEvent) error {
+	a.lock.Lock()
+	switch policy {
+	case jdwpSuspendAll:
+		a.suspendAll(t)
+	case jdwpSuspendEventThread:
+		a.suspended[t]++
+	}
+	a.lock.Unlock()
+
+	err := a.sendEvents(policy, events)
+	if err != nil {
+		return err
+	}
+
+	return a.waitWhileSuspended(ctx, t)
+}
+
+// collect builds the events of kind for all matching requests, the caller has to hold a.lock.
+// It returns the strictest suspend policy of the requests.
+func (a *jdwpAgent) collect(ctx context.Context, t *Runner, kind byte, className string, match func(*jdwpRequest) bool, data []byte) ([]jdwpEvent, byte) {
+	var events []jdwpEvent
+	policy := byte(jdwpSuspendNone)
+
+	for _, q := range a.requests {
+		if q.kind != kind || !q.matches(ctx, t, className) || !match(q) || !q.occurred() {
+			continue
+		}
+
+		events = append(events, jdwpEvent{kind: kind, requestID: q.id, data: data})
+		policy = max(policy, q.suspendPolicy)
+	}
+
+	return events, policy
+}
+
+func matchAll(*jdwpRequest) bool {
+	return true
+}
+
+// instruction is called before r executes the instruction at r.pc. It stops the thread while it
+// is suspended and reports breakpoints and completed steps.
+func (a *jdwpAgent) instruction(ctx context.Context, r *Runner, code *class.CodeAttribute) error {
+	err := a.waitWhileSuspended(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	a.lock.Lock()
+	if a.conn == nil || len(a.requests) == 0 {
+		a.lock.Unlock()
+		return nil
+	}
+
+	method, err := r.stack.CurrentMethod()
+	if err != nil {
+		a.lock.Unlock()
+		return err
+	}
+
+	className := r.stack.CurrentClassName()
+	frames := r.stack.Depth()
+	line, hasLine := code.LineNumber(r.pc)
+
+	location, ok := a.location(ctx, r, className, *method, r.pc)
+	if !ok {
+		a.lock.Unlock()
+		return nil
+	}
+
+	w := &jdwpWriter{}
+	w.id(a.threadID(r))
+	w.location(location)
+
+	events, policy := a.collect(ctx, r, jdwpEventBreakpoint, className, func(q *jdwpRequest) bool {
+		b := q.breakpoint
+		return b.className == className && b.nameIndex == method.NameIndex && b.descriptorIndex == method.DescriptorIndex && b.pc == r.pc
+	}, w.Bytes())
+
+	steps, stepPolicy := a.collect(ctx, r, jdwpEventSingleStep, className, func(q *jdwpRequest) bool {
+		s := q.step
+		if s.thread != r || !s.completed(frames, line, hasLine) {
+			return false
+		}
+
+		// the next step starts here
+		s.frames, s.line, s.hasLine = frames, line, hasLine
+		return true
+	}, w.Bytes())
+	a.lock.Unlock()
+
+	events = append(events, steps...)
+	if len(events) == 0 {
+		return nil
+	}
+
+	return a.report(ctx, r, max(policy, stepPolicy), events)
+}
+
+// classPrepared reports a class that has just been defined by thread t.
+func (a *jdwpAgent) classPrepared(ctx context.Context, t *Runner, c *class.Class) error {
+	a.lock.Lock()
+	if a.conn == nil {
+		a.lock.Unlock()
+		return nil
+	}
+
+	w := &jdwpWriter{}
+	w.id(a.threadID(t))
+	w.byte(typeTag(c))
+	w.id(a.classID(c.Name))
+	w.string(signature(c.Name))
+	w.int(jdwpClassVerified | jdwpClassPrepared)
+
+	events, policy := a.collect(ctx, t, jdwpEventClassPrepare, c.Name, matchAll, w.Bytes())
+	a.lock.Unlock()
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	return a.report(ctx, t, policy, events)
+}
+
+// threadEvent reports the start or death of thread t.
+func (a *jdwpAgent) threadEvent(ctx context.Context, t *Runner, kind byte) error {
+	a.lock.Lock()
+	if a.conn == nil {
+		a.lock.Unlock()
+		return nil
+	}
+
+	w := &jdwpWriter{}
+	w.id(a.threadID(t))
+
+	events, policy := a.collect(ctx, t, kind, "", matchAll, w.Bytes())
+	a.lock.Unlock()
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	return a.report(ctx, t, policy, events)
+}
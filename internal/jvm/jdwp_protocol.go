@@ -0,0 +1,270 @@
+package jvm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+)
+
+// jdwpHandshake is exchanged by debugger and VM before the first packet.
+const jdwpHandshake = "JDWP-Handshake"
+
+const jdwpReplyFlag = 0x80
+
+// jdwpIDSize is the size of all object, method, field, reference type and frame IDs.
+const jdwpIDSize = 8
+
+// Error codes of replies, see the JDWP specification.
+const (
+	jdwpErrInvalidThread      = 10
+	jdwpErrThreadNotSuspended = 13
+	jdwpErrInvalidObject      = 20
+	jdwpErrInvalidClass       = 21
+	jdwpErrInvalidMethodID    = 23
+	jdwpErrInvalidFieldID     = 25
+	jdwpErrInvalidFrameID     = 30
+	jdwpErrInvalidSlot        = 35
+	jdwpErrNotImplemented     = 99
+	jdwpErrAbsentInformation  = 101
+	jdwpErrIllegalArgument    = 103
+	jdwpErrInternal           = 113
+	jdwpErrInvalidIndex       = 503
+	jdwpErrInvalidLength      = 504
+)
+
+// Type tags of reference types.
+const (
+	jdwpTypeTagClass     = 1
+	jdwpTypeTagInterface = 2
+	jdwpTypeTagArray     = 3
+)
+
+// Class status flags.
+const (
+	jdwpClassVerified    = 1
+	jdwpClassPrepared    = 2
+	jdwpClassInitialized = 4
+	jdwpClassError       = 8
+)
+
+// Thread status and suspend status.
+const (
+	jdwpThreadZombie   = 0
+	jdwpThreadRunning  = 1
+	jdwpThreadSleeping = 2
+	jdwpThreadMonitor  = 3
+	jdwpThreadWait     = 4
+
+	jdwpSuspended = 1
+)
+
+// jdwpError is an error code sent as the reply to a command.
+type jdwpError uint16
+
+func (e jdwpError) Error() string {
+	return fmt.Sprintf("JDWP error %d", uint16(e))
+}
+
+// jdwpPacket is a command or a reply, see the JDWP specification for the layout.
+type jdwpPacket struct {
+	id         uint32
+	flags      byte
+	commandSet byte
+	command    byte
+	errorCode  uint16
+	data       []byte
+}
+
+func (p jdwpPacket) isReply() bool {
+	return p.flags&jdwpReplyFlag != 0
+}
+
+func readJDWPPacket(r io.Reader) (jdwpPacket, error) {
+	header := make([]byte, 11)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return jdwpPacket{}, err
+	}
+
+	length := binary.BigEndian.Uint32(header)
+	if length < 11 {
+		return jdwpPacket{}, fmt.Errorf("invalid packet length %d", length)
+	}
+
+	p := jdwpPacket{id: binary.BigEndian.Uint32(header[4:]), flags: header[8], data: make([]byte, length-11)}
+	if p.isReply() {
+		p.errorCode = binary.BigEndian.Uint16(header[9:])
+	} else {
+		p.commandSet, p.command = header[9], header[10]
+	}
+
+	_, err = io.ReadFull(r, p.data)
+	return p, err
+}
+
+func (p jdwpPacket) bytes() []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(11+len(p.data)))
+	b = binary.BigEndian.AppendUint32(b, p.id)
+	b = append(b, p.flags)
+	if p.isReply() {
+		b = binary.BigEndian.AppendUint16(b, p.errorCode)
+	} else {
+		b = append(b, p.commandSet, p.command)
+	}
+
+	return append(b, p.data...)
+}
+
+// jdwpReader decodes the data of a packet. The first error is kept and reported by err.
+type jdwpReader struct {
+	data []byte
+	err  error
+}
+
+func (r *jdwpReader) next(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+
+	if len(r.data) < n {
+		r.err = errors.New("packet is too short")
+		return make([]byte, n)
+	}
+
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *jdwpReader) byte() byte {
+	return r.next(1)[0]
+}
+
+func (r *jdwpReader) bool() bool {
+	return r.byte() != 0
+}
+
+func (r *jdwpReader) int() int32 {
+	return int32(binary.BigEndian.Uint32(r.next(4)))
+}
+
+func (r *jdwpReader) long() int64 {
+	return int64(binary.BigEndian.Uint64(r.next(8)))
+}
+
+func (r *jdwpReader) id() uint64 {
+	return binary.BigEndian.Uint64(r.next(jdwpIDSize))
+}
+
+func (r *jdwpReader) string() string {
+	n := r.int()
+	if n < 0 {
+		r.err = errors.New("negative string length")
+		return ""
+	}
+
+	return string(r.next(int(n)))
+}
+
+func (r *jdwpReader) location() jdwpLocation {
+	return jdwpLocation{typeTag: r.byte(), classID: r.id(), methodID: r.id(), index: r.long()}
+}
+
+// jdwpWriter encodes the data of a packet.
+type jdwpWriter struct {
+	bytes.Buffer
+}
+
+func (w *jdwpWriter) byte(b byte) {
+	w.WriteByte(b)
+}
+
+func (w *jdwpWriter) bool(b bool) {
+	if b {
+		w.byte(1)
+	} else {
+		w.byte(0)
+	}
+}
+
+func (w *jdwpWriter) short(n uint16) {
+	w.Write(binary.BigEndian.AppendUint16(nil, n))
+}
+
+func (w *jdwpWriter) int(n int32) {
+	w.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+}
+
+func (w *jdwpWriter) long(n int64) {
+	w.Write(binary.BigEndian.AppendUint64(nil, uint64(n)))
+}
+
+func (w *jdwpWriter) id(id uint64) {
+	w.Write(binary.BigEndian.AppendUint64(nil, id))
+}
+
+func (w *jdwpWriter) string(s string) {
+	w.int(int32(len(s)))
+	w.WriteString(s)
+}
+
+func (w *jdwpWriter) location(l jdwpLocation) {
+	w.byte(l.typeTag)
+	w.id(l.classID)
+	w.id(l.methodID)
+	w.long(l.index)
+}
+
+// jdwpLocation is a code index in a method.
+type jdwpLocation struct {
+	typeTag  byte
+	classID  uint64
+	methodID uint64
+	index    int64
+}
+
+// jdwpValue is a value with its signature tag, like 'I' for int or 's' for a String.
+type jdwpValue struct {
+	tag   byte
+	value stack.Value
+}
+
+// untagged writes a value without its tag, as in primitive array regions.
+func (w *jdwpWriter) untagged(v jdwpValue) {
+	switch v.tag {
+	case 'V':
+	case 'Z':
+		n, _ := intOf(v.value)
+		w.bool(n != 0)
+	case 'B':
+		n, _ := intOf(v.value)
+		w.byte(byte(n))
+	case 'C', 'S':
+		n, _ := intOf(v.value)
+		w.short(uint16(n))
+	case 'I':
+		n, _ := intOf(v.value)
+		w.int(int32(n))
+	case 'J':
+		n, _ := intOf(v.value)
+		w.long(n)
+	case 'F':
+		f, _ := v.value.(stack.FloatValue)
+		w.int(int32(math.Float32bits(f.Value)))
+	case 'D':
+		d, _ := v.value.(stack.DoubleValue)
+		w.long(int64(math.Float64bits(d.Value)))
+	default:
+		w.id(uint64(referenceOf(v.value)))
+	}
+}
+
+func (w *jdwpWriter) value(v jdwpValue) {
+	w.byte(v.tag)
+	w.untagged(v)
+}
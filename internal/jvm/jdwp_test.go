@@ -0,0 +1,275 @@
+package jvm
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/stretchr/testify/assert"
+)
+
+// jdwpTestClient is a minimal debugger, it reads replies and events in the order they arrive.
+type jdwpTestClient struct {
+	t      *testing.T
+	conn   net.Conn
+	nextID uint32
+	events []jdwpPacket
+}
+
+func dialJDWP(t *testing.T, address string) *jdwpTestClient {
+	conn, err := net.Dial("tcp", address)
+	assert.NoError(t, err)
+	assert.NoError(t, conn.SetDeadline(time.Now().Add(10*time.Second)))
+
+	_, err = conn.Write([]byte(jdwpHandshake))
+	assert.NoError(t, err)
+
+	handshake := make([]byte, len(jdwpHandshake))
+	_, err = io.ReadFull(conn, handshake)
+	assert.NoError(t, err)
+	assert.Equal(t, jdwpHandshake, string(handshake))
+
+	return &jdwpTestClient{t: t, conn: conn}
+}
+
+// command sends a command and returns the data of its reply, events arriving meanwhile are kept.
+func (c *jdwpTestClient) command(commandSet byte, command byte, write func(w *jdwpWriter)) *jdwpReader {
+	w := &jdwpWriter{}
+	if write != nil {
+		write(w)
+	}
+
+	c.nextID++
+	_, err := c.conn.Write(jdwpPacket{id: c.nextID, commandSet: commandSet, command: command, data: w.Bytes()}.bytes())
+	assert.NoError(c.t, err)
+
+	for {
+		p, err := readJDWPPacket(c.conn)
+		assert.NoError(c.t, err)
+
+		if !p.isReply() {
+			c.events = append(c.events, p)
+			continue
+		}
+
+		assert.Equal(c.t, c.nextID, p.id)
+		assert.Zero(c.t, p.errorCode, "command %d/%d", commandSet, command)
+		return &jdwpReader{data: p.data}
+	}
+}
+
+// event returns the first event of the next composite event command and the event's data.
+func (c *jdwpTestClient) event() (byte, *jdwpReader) {
+	var p jdwpPacket
+	if len(c.events) > 0 {
+		p, c.events = c.events[0], c.events[1:]
+	} else {
+		var err error
+		p, err = readJDWPPacket(c.conn)
+		assert.NoError(c.t, err)
+	}
+
+	assert.Equal(c.t, []byte{jdwpCommandSetEvent, jdwpCommandComposite}, []byte{p.commandSet, p.command})
+
+	in := &jdwpReader{data: p.data}
+	in.byte()
+	assert.Positive(c.t, in.int())
+
+	kind := in.byte()
+	in.int()
+	return kind, in
+}
+
+func (c *jdwpTestClient) resume() {
+	c.command(jdwpCommandSetVM, 9, nil)
+}
+
+// debuggeeClass runs main with line numbers and a local variable x in slot 2:
+//
+//	10: load();
+//	11: int x = 5;
+//	12: x = 2;
+//	13: return;
+func debuggeeClass() *class.Class {
+	debuggee := newTestClass("Debuggee", "java/lang/Object").
+		method(class.AccStatic|class.AccNative, "load", "()V")
+	load := debuggee.ref("Debuggee", "load", "()V")
+	debuggee.method(class.AccPublic|class.AccStatic, "main", mainDescriptor,
+		append(append([]byte{InvokeStaticOp}, u2(load)...), IConst5, IStore2, IConst2, IStore2, RetOp)...)
+
+	method := &debuggee.c.Methods[1]
+	code := method.Attributes[0].(class.CodeAttribute)
+	code.Attributes = []class.Attribute{
+		class.LineNumberTableAttribute{Table: []class.LineNumberTableEntry{
+			{StartPc: 0, LineNumber: 10},
+			{StartPc: 3, LineNumber: 11},
+			{StartPc: 5, LineNumber: 12},
+			{StartPc: 7, LineNumber: 13},
+		}},
+		class.LocalVariableTableAttribute{Table: []class.LocalVariableTableEntry{
+			{StartPc: 0, Length: 8, NameIndex: debuggee.utf8("args"), DescriptorIndex: debuggee.utf8("[Ljava/lang/String;"), Index: 0},
+			{StartPc: 5, Length: 3, NameIndex: debuggee.utf8("x"), DescriptorIndex: debuggee.utf8("I"), Index: 2},
+		}},
+	}
+	method.Attributes[0] = code
+
+	return debuggee.build()
+}
+
+func TestJDWP(t *testing.T) {
+	r, ctx := newTestRunner(t, debuggeeClass())
+	r.natives.Register("Debuggee", "load", "()V", func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+		return nil, r.loader.Define(ctx, newTestClass("Helper", "java/lang/Object").build())
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	WithJDWP(listener, true)(r)
+
+	finished := make(chan error, 1)
+	go func() {
+		finished <- r.RunMain(ctx, "Debuggee")
+	}()
+
+	c := dialJDWP(t, listener.Addr().String())
+
+	kind, in := c.event()
+	assert.Equal(t, byte(jdwpEventVMStart), kind)
+	thread := in.id()
+
+	name := c.command(jdwpCommandSetThread, 1, func(w *jdwpWriter) { w.id(thread) }).string()
+	assert.Equal(t, "main", name)
+
+	// ClassPrepare for Helper
+	c.command(jdwpCommandSetEventRequest, 1, func(w *jdwpWriter) {
+		w.byte(jdwpEventClassPrepare)
+		w.byte(jdwpSuspendAll)
+		w.int(1)
+		w.byte(jdwpModClassMatch)
+		w.string("Helper")
+	})
+
+	in = c.command(jdwpCommandSetVM, 2, func(w *jdwpWriter) { w.string("LDebuggee;") })
+	assert.Equal(t, int32(1), in.int())
+	in.byte()
+	debuggee := in.id()
+
+	in = c.command(jdwpCommandSetReferenceType, 5, func(w *jdwpWriter) { w.id(debuggee) })
+	var main uint64
+	for range in.int() {
+		id, name := in.id(), in.string()
+		in.string()
+		in.int()
+		if name == "main" {
+			main = id
+		}
+	}
+	assert.NotZero(t, main)
+
+	// the breakpoint goes on the first instruction of line 12
+	in = c.command(jdwpCommandSetMethod, 1, func(w *jdwpWriter) {
+		w.id(debuggee)
+		w.id(main)
+	})
+	in.long()
+	in.long()
+	var index int64 = -1
+	for range in.int() {
+		pc, line := in.long(), in.int()
+		if line == 12 {
+			index = pc
+		}
+	}
+	assert.Equal(t, int64(5), index)
+
+	c.command(jdwpCommandSetEventRequest, 1, func(w *jdwpWriter) {
+		w.byte(jdwpEventBreakpoint)
+		w.byte(jdwpSuspendEventThread)
+		w.int(1)
+		w.byte(jdwpModLocationOnly)
+		w.location(jdwpLocation{typeTag: jdwpTypeTagClass, classID: debuggee, methodID: main, index: index})
+	})
+
+	c.resume()
+
+	kind, in = c.event()
+	assert.Equal(t, byte(jdwpEventClassPrepare), kind)
+	assert.Equal(t, thread, in.id())
+	in.byte()
+	in.id()
+	assert.Equal(t, "LHelper;", in.string())
+
+	c.resume()
+
+	kind, in = c.event()
+	assert.Equal(t, byte(jdwpEventBreakpoint), kind)
+	assert.Equal(t, thread, in.id())
+	assert.Equal(t, int64(5), in.location().index)
+
+	in = c.command(jdwpCommandSetMethod, 2, func(w *jdwpWriter) {
+		w.id(debuggee)
+		w.id(main)
+	})
+	assert.Equal(t, int32(1), in.int())
+	assert.Equal(t, int32(2), in.int())
+	in.long()
+	in.string()
+	in.string()
+	in.int()
+	in.int()
+	assert.Equal(t, int64(5), in.long())
+	assert.Equal(t, "x", in.string())
+
+	readX := func() int32 {
+		in := c.command(jdwpCommandSetThread, 6, func(w *jdwpWriter) {
+			w.id(thread)
+			w.int(0)
+			w.int(-1)
+		})
+		assert.Equal(t, int32(1), in.int())
+		frame := in.id()
+
+		in = c.command(jdwpCommandSetStackFrame, 1, func(w *jdwpWriter) {
+			w.id(thread)
+			w.id(frame)
+			w.int(1)
+			w.int(2)
+			w.byte('I')
+		})
+		assert.Equal(t, int32(1), in.int())
+		assert.Equal(t, byte('I'), in.byte())
+		return in.int()
+	}
+	assert.Equal(t, int32(5), readX())
+
+	// step over line 12
+	c.command(jdwpCommandSetEventRequest, 1, func(w *jdwpWriter) {
+		w.byte(jdwpEventSingleStep)
+		w.byte(jdwpSuspendEventThread)
+		w.int(2)
+		w.byte(jdwpModStep)
+		w.id(thread)
+		w.int(jdwpStepLine)
+		w.int(jdwpStepOver)
+		w.byte(jdwpModCount)
+		w.int(1)
+	})
+
+	c.resume()
+
+	kind, in = c.event()
+	assert.Equal(t, byte(jdwpEventSingleStep), kind)
+	in.id()
+	assert.Equal(t, int64(7), in.location().index)
+	assert.Equal(t, int32(2), readX())
+
+	c.resume()
+
+	kind, _ = c.event()
+	assert.Equal(t, byte(jdwpEventVMDeath), kind)
+	assert.NoError(t, <-finished)
+}
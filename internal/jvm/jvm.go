@@ -1,40 +1,258 @@
 package jvm
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
-	"strings"
+	"io"
+	"os"
 
 	"github.com/m4tthewde/swell/internal/class"
 	"github.com/m4tthewde/swell/internal/jvm/stack"
 	"github.com/m4tthewde/swell/internal/loader"
 	"github.com/m4tthewde/swell/internal/logger"
+	"go.uber.org/zap"
 )
 
+// vm is the state shared by all threads of the virtual machine.
+type vm struct {
+	classInits     classInits
+	loader         loader.Loader
+	heap           Heap
+	natives        *NativeRegistry
+	mirrors        map[string]stack.ClassReferenceValue
+	mirrorNames    map[stack.Reference]string
+	pendingMirrors []string
+	memory         Memory
+	files          Files
+	strings        map[string]stack.Reference
+	compactStrings bool
+	heapDumpOnExit string
+	budgets        budgets
+	// logger replaces the logger of the contexts passed in, if set
+	logger *zap.SugaredLogger
+	// jdwp is the debugger agent and debugger the command-line debugger, if enabled
+	jdwp     *jdwpAgent
+	debugger *debugger
+	// tracer is notified of the execution, if set
+	tracer Tracer
+	scheduler
+}
+
+// Runner interprets the bytecode of a single Java thread, all threads share the vm.
 type Runner struct {
-	classBeingInitialized string
-	initializedClasses    map[string]struct{}
-	pc                    int
-	loader                loader.Loader
-	stack                 stack.Stack
-	heap                  Heap
+	*vm
+	pc     int
+	stack  stack.Stack
+	thread stack.Reference
+	// handles holds the allocation handles of the thread while another thread runs
+	handles []stack.Reference
+	// steps counts the instructions executed since the thread last yielded
+	steps int
+	// holding reports whether the thread holds the interpreter lock
+	holding bool
+	// wakeup interrupts a sleeping or waiting thread
+	wakeup chan struct{}
+	// state is what the thread is doing, blockedOn and waitingOn the monitors it is blocked or waiting on
+	state     threadState
+	blockedOn stack.Reference
+	waitingOn stack.Reference
+	// locks are the monitors the thread has entered, in order
+	locks []lockRecord
 }
 
-func NewRunner(classPath []string) Runner {
-	return Runner{
-		classBeingInitialized: "",
-		// FIXME: is there a better data structure for this?
-		initializedClasses: make(map[string]struct{}),
-		pc:                 0,
-		loader:             loader.NewLoader(classPath),
-		stack:              stack.NewStack(),
-		heap:               NewHeap(),
+func NewRunner(classPath []string, options ...Option) *Runner {
+	v := &vm{
+		classInits:     classInits{classes: make(map[string]*classInit)},
+		loader:         loader.NewLoader(classPath),
+		heap:           NewHeap(),
+		natives:        builtinNatives(),
+		mirrors:        make(map[string]stack.ClassReferenceValue),
+		mirrorNames:    make(map[stack.Reference]string),
+		memory:         NewMemory(),
+		files:          NewFiles(os.Stdin, os.Stdout, os.Stderr),
+		strings:        make(map[string]stack.Reference),
+		compactStrings: true,
+	}
+
+	// the main thread only holds the interpreter lock while the host calls into the VM
+	r := v.newThread(stack.Null)
+	r.acquire()
+	defer r.release()
+
+	r.heap.roots = v
+	r.loader.SetDefineHook(r.classDefined)
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// VisitRoots visits the garbage collection roots: the frames and thread objects of all threads,
+// static fields, class mirrors, interned strings and the allocation handles of waiting threads.
+func (v *vm) VisitRoots(visit func(stack.Value)) {
+	for _, t := range v.threads {
+		t.stack.VisitValues(visit)
+		visit(stack.ReferenceValue{Value: t.thread})
+
+		for _, ref := range t.handles {
+			visit(stack.ReferenceValue{Value: ref})
+		}
+	}
+
+	v.loader.VisitStatics(visit)
+
+	for _, mirror := range v.mirrors {
+		visit(mirror)
+	}
+
+	for _, ref := range v.strings {
+		visit(stack.ReferenceValue{Value: ref})
 	}
+}
+
+// DumpHeap writes an HPROF heap dump of the current state of the VM to w.
+func (r *Runner) DumpHeap(w io.Writer) error {
+	leave := r.enter()
+	defer leave()
 
+	return r.writeHeapDump(w)
 }
 
+// writeHeapDump writes the heap dump, the caller has to hold the interpreter lock.
+func (r *Runner) writeHeapDump(w io.Writer) error {
+	classes, err := r.loader.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	roots := make([]HprofRoot, 0)
+	for _, t := range r.threads {
+		t.stack.VisitFrameValues(func(depth int, value stack.Value) {
+			if ref := referenceOf(value); ref != stack.Null {
+				roots = append(roots, HprofRoot{Ref: ref, Kind: HprofRootJavaFrame, FrameDepth: depth})
+			}
+		})
+
+		for _, ref := range t.handles {
+			roots = append(roots, HprofRoot{Ref: ref, Kind: HprofRootUnknown})
+		}
+
+		if t.thread != stack.Null {
+			roots = append(roots, HprofRoot{Ref: t.thread, Kind: HprofRootUnknown})
+		}
+	}
+
+	for _, ref := range r.heap.handles {
+		roots = append(roots, HprofRoot{Ref: ref, Kind: HprofRootUnknown})
+	}
+
+	for _, mirror := range r.mirrors {
+		roots = append(roots, HprofRoot{Ref: mirror.Value, Kind: HprofRootUnknown})
+	}
+
+	for _, ref := range r.strings {
+		roots = append(roots, HprofRoot{Ref: ref, Kind: HprofRootUnknown})
+	}
+
+	return r.heap.DumpHprof(w, classes, roots)
+}
+
+func (r *Runner) dumpHeapToFile(ctx context.Context, path string) {
+	log := logger.FromContext(ctx)
+
+	f, err := os.Create(path)
+	if err != nil {
+		log.Errorw("heap dump failed", "path", path, "error", err)
+		return
+	}
+
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	err = r.writeHeapDump(w)
+	if err == nil {
+		err = w.Flush()
+	}
+
+	if err != nil {
+		log.Errorw("heap dump failed", "path", path, "error", err)
+		return
+	}
+
+	log.Infow("heap dump written", "path", path)
+}
+
+// RunMain runs the main method of className and waits for the non-daemon threads it started.
+// Execution stops early with the cause of ctx once it is cancelled, or with a BudgetExceededError.
 func (r *Runner) RunMain(ctx context.Context, className string) error {
+	ctx = r.withLogger(ctx)
+
+	leave := r.enter()
+	defer leave()
+
+	if r.heapDumpOnExit != "" {
+		defer r.dumpHeapToFile(ctx, r.heapDumpOnExit)
+	}
+
+	if r.jdwp != nil {
+		defer r.jdwp.detach(r)
+	}
+
+	return r.execute(ctx, func(ctx context.Context) error {
+		if r.jdwp != nil {
+			err := r.jdwp.attach(ctx, r)
+			if err != nil {
+				return err
+			}
+		}
+
+		if r.debugger != nil {
+			err := r.debugger.start(ctx, r, className)
+			if err != nil {
+				return err
+			}
+		}
+
+		return r.runMain(ctx, className)
+	})
+}
+
+// withLogger puts the logger configured with WithLogger on ctx.
+func (r *Runner) withLogger(ctx context.Context) context.Context {
+	if r.logger == nil {
+		return ctx
+	}
+
+	return logger.OnContext(ctx, r.logger)
+}
+
+// execute runs fn on the thread with the context the VM is stopped through, then waits for the
+// non-daemon threads. It returns the error of fn or the reason another thread stopped the VM.
+func (r *Runner) execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx, cancel := r.start(ctx)
+	defer cancel()
+
+	err := fn(ctx)
+	if err != nil {
+		r.stopping(ctx, err)
+	}
+
+	// like DestroyJavaVM, the VM only exits once the other non-daemon threads have finished
+	r.waitForThreads()
+
+	if err == nil {
+		// another thread may have stopped the VM
+		err = context.Cause(ctx)
+	}
+
+	return err
+}
+
+func (r *Runner) runMain(ctx context.Context, className string) error {
 	err := r.initializeClass(ctx, className)
 	if err != nil {
 		return err
@@ -59,12 +277,7 @@ func (r *Runner) RunMain(ctx context.Context, className string) error {
 		return err
 	}
 
-	err = r.runMethod(ctx, code, *c, *main, make([]stack.Value, 0))
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return r.runMethod(ctx, code, *c, *main, make([]stack.Value, 0))
 }
 
 const Nop = 0x00
@@ -113,14 +326,48 @@ const BiPush = 0x10
 const IfLt = 0x9b
 const IfICmpLt = 0xa1
 const NewArray = 0xbc
-
-func (r *Runner) run(ctx context.Context, code []byte) error {
+const AThrow = 0xbf
+const MonitorEnter = 0xc2
+const MonitorExit = 0xc3
+const CheckCast = 0xc0
+const InstanceOf = 0xc1
+const AALoad = 0x32
+const AAStore = 0x53
+
+func (r *Runner) run(ctx context.Context, codeAttribute *class.CodeAttribute) error {
 	log := logger.FromContext(ctx)
+	code := codeAttribute.Code
 
 	for {
+		err := r.step(ctx)
+		if err != nil {
+			return err
+		}
+
+		start := r.pc
 		instruction := code[r.pc]
+		r.stack.SetPc(start)
+
+		if r.jdwp != nil {
+			err = r.jdwp.instruction(ctx, r, codeAttribute)
+			if err != nil {
+				return err
+			}
+		}
+
+		if r.debugger != nil {
+			err = r.debugger.instruction(ctx, r, codeAttribute)
+			if err != nil {
+				return err
+			}
+		}
+
+		if r.tracer != nil {
+			r.traceInstruction(code)
+		}
+
+		handleMark := r.heap.HandleMark()
 
-		var err error
 		switch instruction {
 		case GetField:
 			log.Debug("getfield")
@@ -163,10 +410,14 @@ func (r *Runner) run(ctx context.Context, code []byte) error {
 			err = invokeSpecial(r, ctx, code)
 		case RetOp:
 			log.Debug("ret")
-			return ret(r)
+			err = ret(r)
+			r.heap.ReleaseHandles(handleMark)
+			return err
 		case AReturn:
 			log.Debug("areturn")
-			return areturn(ctx, r)
+			err = areturn(ctx, r)
+			r.heap.ReleaseHandles(handleMark)
+			return err
 		case Astore0:
 			log.Debug("astore_0")
 			err = astore(ctx, r, 0)
@@ -214,7 +465,9 @@ func (r *Runner) run(ctx context.Context, code []byte) error {
 			r.pc += 1
 		case IReturn:
 			log.Debug("ireturn")
-			return ireturn(ctx, r)
+			err = ireturn(ctx, r)
+			r.heap.ReleaseHandles(handleMark)
+			return err
 		case IfNe:
 			log.Debug("ifne")
 			err = ifne(r, code)
@@ -262,13 +515,56 @@ func (r *Runner) run(ctx context.Context, code []byte) error {
 		case NewArray:
 			log.Debug("newarray")
 			err = newArray(r, ctx, code)
+		case AThrow:
+			log.Debug("athrow")
+			err = athrow(ctx, r)
+		case MonitorEnter:
+			log.Debug("monitorenter")
+			err = monitorEnter(ctx, r)
+		case MonitorExit:
+			log.Debug("monitorexit")
+			err = monitorExit(ctx, r)
+		case CheckCast:
+			log.Debug("checkcast")
+			err = checkCast(r, ctx, code)
+		case InstanceOf:
+			log.Debug("instanceof")
+			err = instanceOf(r, ctx, code)
+		case AALoad:
+			log.Debug("aaload")
+			err = aaload(ctx, r)
+		case AAStore:
+			log.Debug("aastore")
+			err = aastore(ctx, r)
 		default:
 			return fmt.Errorf("unknown instruction %x", instruction)
 
 		}
 
+		r.heap.ReleaseHandles(handleMark)
+
 		if err != nil {
-			return err
+			// until a handler has it on its operand stack the exception is only reachable through err,
+			// keep it alive while the handler is resolved, loading its class can collect
+			if throwable, ok := err.(*ThrowableError); ok && throwable.Ref != stack.Null {
+				r.heap.AddHandle(throwable.Ref)
+			}
+
+			if r.tracer != nil {
+				r.traceThrow(err, start)
+			}
+
+			caught, handlerErr := r.catchException(ctx, codeAttribute, start, err)
+			if handlerErr != nil {
+				return handlerErr
+			}
+
+			if !caught {
+				return err
+			}
+
+			r.heap.ReleaseHandles(handleMark)
+			continue
 		}
 
 		if r.pc == len(code) {
@@ -277,52 +573,64 @@ func (r *Runner) run(ctx context.Context, code []byte) error {
 	}
 }
 
-func (r *Runner) initializeClass(ctx context.Context, className string) error {
-	log := logger.FromContext(ctx)
-
-	_, exists := r.initializedClasses[className]
-	if exists {
-		log.Debugw("already initialized", "className", className)
-		return nil
+// catchException searches the exception table of the current method for a handler of err,
+// thrown by the instruction at pc. If one is found, execution continues at the handler.
+func (r *Runner) catchException(ctx context.Context, code *class.CodeAttribute, pc int, err error) (bool, error) {
+	throwable, ok := err.(*ThrowableError)
+	if !ok || throwable.Ref == stack.Null {
+		return false, nil
 	}
 
-	if r.classBeingInitialized == className {
-		return nil
-	} else {
-		r.classBeingInitialized = className
+	pool, poolErr := r.stack.CurrentConstantPool()
+	if poolErr != nil {
+		return false, poolErr
 	}
 
-	log.Infow("initializing", "className", className)
+	for _, exception := range code.Exceptions {
+		if pc < int(exception.StartPc) || pc >= int(exception.EndPc) {
+			continue
+		}
 
-	c, err := r.loader.Load(ctx, className)
-	if err != nil {
-		return err
-	}
+		if exception.CatchType != 0 {
+			catchType, err := pool.Class(exception.CatchType)
+			if err != nil {
+				return false, err
+			}
+
+			catchName, err := pool.GetUtf8(catchType.NameIndex)
+			if err != nil {
+				return false, err
+			}
+
+			matches, err := r.isSubclassOf(ctx, throwable.ClassName, catchName)
+			if err != nil {
+				return false, err
+			}
+
+			if !matches {
+				continue
+			}
+		}
 
-	clinit, ok, err := c.GetMethodByName("<clinit>")
-	if !ok {
-		r.initializedClasses[className] = struct{}{}
-		log.Infow("initialized without clinit", "className", className)
-		return nil
-	}
+		err := r.stack.ClearOperands()
+		if err != nil {
+			return false, err
+		}
 
-	if err != nil {
-		return err
-	}
+		err = r.stack.PushOperand(ctx, stack.ReferenceValue{Value: throwable.Ref})
+		if err != nil {
+			return false, err
+		}
 
-	code, err := clinit.CodeAttribute()
-	if err != nil {
-		return err
-	}
+		r.pc = int(exception.HandlerPc)
+		if r.tracer != nil {
+			r.tracer.Catch(r.exceptionEvent(throwable, r.pc))
+		}
 
-	err = r.runMethod(ctx, code, *c, *clinit, make([]stack.Value, 0))
-	if err != nil {
-		return err
+		return true, nil
 	}
 
-	r.initializedClasses[className] = struct{}{}
-	log.Infow("initialized", "className", className)
-	return nil
+	return false, nil
 }
 
 func (r *Runner) runMethod(ctx context.Context, code *class.CodeAttribute, c class.Class, method class.Method, parameters []stack.Value) error {
@@ -339,22 +647,35 @@ func (r *Runner) runMethod(ctx context.Context, code *class.CodeAttribute, c cla
 		"parameters", fmt.Sprintf("%s", parameters),
 		"code", fmt.Sprintf("% x", code.Code), // Use hex formatting for binary data
 	)
-	r.stack.Push(c.Name, method, c.ConstantPool, parameters)
+	err = r.pushFrame(&c, method, parameters)
+	if err != nil {
+		return err
+	}
+
+	if r.tracer != nil {
+		r.traceMethodEntry(&c, &method, parameters)
+	}
 
 	returnPc := r.pc
 	r.pc = 0
 
-	err = r.run(ctx, code.Code)
-	if err != nil {
-		err = fmt.Errorf("%v\n\t%s.%s()", err, strings.ReplaceAll(c.Name, "/", "."), name)
-		return err
+	err = r.run(ctx, code)
+
+	if r.tracer != nil {
+		var result stack.Value
+		if err == nil {
+			result = r.returnValue(&c, &method)
+		}
+
+		r.traceMethodExit(&c, &method, result, err)
 	}
 
-	err = r.stack.Pop()
+	popErr := r.stack.Pop()
+	r.pc = returnPc
+
 	if err != nil {
-		return err
+		return methodError(err, c.Name, name)
 	}
 
-	r.pc = returnPc
-	return nil
+	return popErr
 }
@@ -44,22 +44,12 @@ func ldc(r *Runner, ctx context.Context, index int) error {
 			return err
 		}
 
-		c, err := r.loader.Load(ctx, className)
+		mirror, err := r.classMirror(ctx, className)
 		if err != nil {
 			return err
 		}
 
-		classClass, err := r.loader.Load(ctx, "java/lang/Class")
-		if err != nil {
-			return err
-		}
-
-		ref, err := r.heap.AllocateObject(ctx, classClass)
-		if err != nil {
-			return err
-		}
-
-		return r.stack.PushOperand(ctx, stack.ClassReferenceValue{Value: ref, Class: c})
+		return r.stack.PushOperand(ctx, mirror)
 	case class.IntegerInfo:
 		return r.stack.PushOperand(ctx, stack.IntValue{Value: int32(info.Value)})
 	case class.StringInfo:
@@ -68,35 +58,12 @@ func ldc(r *Runner, ctx context.Context, index int) error {
 			return err
 		}
 
-		c, err := r.loader.Load(ctx, "java/lang/String")
-		if err != nil {
-			return err
-		}
-
-		strID, err := r.heap.AllocateObject(ctx, c)
-		if err != nil {
-			return err
-		}
-
-		byteArray := make([]stack.Value, 0)
-		for _, b := range []byte(stringValue) {
-			byteArray = append(byteArray, stack.ByteValue{Value: b})
-		}
-
-		var value stack.Value
-		value = Array{items: byteArray}
-
-		err = r.heap.SetField(*strID, "value", value)
-		if err != nil {
-			return err
-		}
-
-		err = r.heap.SetField(*strID, "coder", stack.ByteValue{Value: 1})
+		strRef, err := r.internString(ctx, stringValue)
 		if err != nil {
 			return err
 		}
 
-		return r.stack.PushOperand(ctx, stack.ReferenceValue{Value: strID})
+		return r.stack.PushOperand(ctx, stack.ReferenceValue{Value: strRef})
 	default:
 		return fmt.Errorf("ldc not implemented for %s", cpInfo)
 	}
@@ -0,0 +1,88 @@
+package jvm
+
+import (
+	"fmt"
+	"slices"
+)
+
+// memoryBase is the first address handed out, so that 0 stays an invalid address.
+const memoryBase = 0x10000
+
+// Memory is the off-heap memory allocated through Unsafe.allocateMemory.
+// Addresses are plain numbers, only the ranges of allocated blocks can be accessed.
+type Memory struct {
+	blocks map[uint64][]byte
+	// bases holds the start addresses of the blocks in ascending order
+	bases []uint64
+	next  uint64
+}
+
+func NewMemory() Memory {
+	return Memory{blocks: make(map[uint64][]byte), next: memoryBase}
+}
+
+func (m *Memory) Allocate(size int) uint64 {
+	if size == 0 {
+		return 0
+	}
+
+	address := m.next
+	// keep blocks 16 byte aligned and leave a gap, so overflows don't hit the next block
+	m.next += uint64(size+31) &^ 15
+
+	m.blocks[address] = make([]byte, size)
+	m.bases = append(m.bases, address)
+	return address
+}
+
+func (m *Memory) Free(address uint64) error {
+	if address == 0 {
+		return nil
+	}
+
+	if _, ok := m.blocks[address]; !ok {
+		return fmt.Errorf("no memory allocated at %#x", address)
+	}
+
+	delete(m.blocks, address)
+	index, _ := slices.BinarySearch(m.bases, address)
+	m.bases = slices.Delete(m.bases, index, index+1)
+	return nil
+}
+
+func (m *Memory) Reallocate(address uint64, size int) (uint64, error) {
+	newAddress := m.Allocate(size)
+	if address == 0 {
+		return newAddress, nil
+	}
+
+	old, ok := m.blocks[address]
+	if !ok {
+		return 0, fmt.Errorf("no memory allocated at %#x", address)
+	}
+
+	if newAddress != 0 {
+		copy(m.blocks[newAddress], old)
+	}
+
+	return newAddress, m.Free(address)
+}
+
+// Bytes returns the allocated memory from address to address+size.
+func (m *Memory) Bytes(address uint64, size int) ([]byte, error) {
+	index, found := slices.BinarySearch(m.bases, address)
+	if !found {
+		index--
+	}
+
+	if index >= 0 {
+		base := m.bases[index]
+		block := m.blocks[base]
+		offset := address - base
+		if size >= 0 && offset <= uint64(len(block)) && uint64(size) <= uint64(len(block))-offset {
+			return block[offset : offset+uint64(size)], nil
+		}
+	}
+
+	return nil, fmt.Errorf("memory access out of bounds at %#x, size %d", address, size)
+}
@@ -0,0 +1,163 @@
+package jvm
+
+import (
+	"context"
+	"errors"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+)
+
+// resolvedMethod is a method resolved from a Methodref, along with its declaring class.
+type resolvedMethod struct {
+	class      *class.Class
+	method     *class.Method
+	name       string
+	descriptor *class.MethodDescriptor
+}
+
+// resolveMethodRef resolves the Methodref at index in the current constant pool.
+func resolveMethodRef(r *Runner, ctx context.Context, index uint16) (*resolvedMethod, error) {
+	pool, err := r.stack.CurrentConstantPool()
+	if err != nil {
+		return nil, err
+	}
+
+	methodRef, err := pool.Ref(index)
+	if err != nil {
+		return nil, err
+	}
+
+	classInfo, err := pool.Class(methodRef.ClassIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	className, err := pool.GetUtf8(classInfo.NameIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	nameAndType, err := pool.NameAndType(methodRef.NameAndTypeIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := pool.GetUtf8(nameAndType.NameIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptor, err := pool.GetUtf8(nameAndType.DescriptorIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	methodDescriptor, err := class.NewMethodDescriptor(descriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	c, method, err := r.loader.ResolveMethod(ctx, className, name, descriptor)
+	if errors.Is(err, loader.ErrNoSuchMethod) {
+		return nil, r.newThrowable(ctx, "java/lang/NoSuchMethodError", methodSignature(className, name, descriptor), nil)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &resolvedMethod{class: c, method: method, name: name, descriptor: methodDescriptor}, nil
+}
+
+// selectMethod finds the method invoked by invokevirtual on an instance of className, see JVMS §5.4.6.
+func (r *Runner) selectMethod(ctx context.Context, className string, resolved *resolvedMethod) (*class.Class, *class.Method, error) {
+	descriptor, err := resolved.class.ConstantPool.GetUtf8(resolved.method.DescriptorIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, method, err := r.loader.ResolveMethod(ctx, className, resolved.name, descriptor)
+	if errors.Is(err, loader.ErrNoSuchMethod) {
+		return resolved.class, resolved.method, nil
+	}
+
+	return c, method, err
+}
+
+// invokeMethod runs a bytecode or native method, args holds the objectref first for instance methods.
+// The result, if any, is pushed onto the operand stack of the invoker.
+func (r *Runner) invokeMethod(ctx context.Context, c *class.Class, method *class.Method, args []stack.Value) error {
+	if method.IsAbstract() {
+		name, err := c.ConstantPool.GetUtf8(method.NameIndex)
+		if err != nil {
+			return err
+		}
+
+		return r.newThrowable(ctx, "java/lang/AbstractMethodError", dotted(c.Name)+"."+name, nil)
+	}
+
+	if method.IsSynchronized() {
+		return r.invokeSynchronized(ctx, c, method, args)
+	}
+
+	return r.invokeUnsynchronized(ctx, c, method, args)
+}
+
+// invokeSynchronized holds the monitor of the receiver or class while the method runs,
+// it is released however the method completes.
+func (r *Runner) invokeSynchronized(ctx context.Context, c *class.Class, method *class.Method, args []stack.Value) error {
+	lock, err := r.synchronizedLock(ctx, c, method, args)
+	if err != nil {
+		return err
+	}
+
+	// the monitor belongs to the frame of the method, which is about to be pushed
+	err = r.enterMonitor(ctx, lock, r.stack.Depth())
+	if err != nil {
+		return err
+	}
+
+	err = r.invokeUnsynchronized(ctx, c, method, args)
+
+	exitErr := r.monitorExit(ctx, lock)
+	if err != nil {
+		return err
+	}
+
+	return exitErr
+}
+
+func (r *Runner) invokeUnsynchronized(ctx context.Context, c *class.Class, method *class.Method, args []stack.Value) error {
+	if method.IsNative() {
+		return r.runNative(ctx, c, method, args)
+	}
+
+	code, err := method.CodeAttribute()
+	if err != nil {
+		return err
+	}
+
+	return r.runMethod(ctx, code, *c, *method, args)
+}
+
+// methodSignature formats a method for error messages, e.g. java.lang.Object.hashCode()I.
+func methodSignature(className string, name string, descriptor string) string {
+	return dotted(className) + "." + name + descriptor
+}
+
+// callVirtual calls a method on an object from Go, selecting it by the class of the object like invokevirtual.
+func (r *Runner) callVirtual(ctx context.Context, ref stack.Reference, name string, descriptor string, args ...stack.Value) error {
+	className, err := r.runtimeClassName(ref)
+	if err != nil {
+		return err
+	}
+
+	c, method, err := r.loader.ResolveMethod(ctx, className, name, descriptor)
+	if err != nil {
+		return err
+	}
+
+	return r.invokeMethod(ctx, c, method, append([]stack.Value{stack.ReferenceValue{Value: ref}}, args...))
+}
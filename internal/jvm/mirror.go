@@ -0,0 +1,157 @@
+package jvm
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+)
+
+var primitiveNames = map[string]struct{}{
+	"boolean": {}, "byte": {}, "char": {}, "short": {}, "int": {}, "long": {}, "float": {}, "double": {}, "void": {},
+}
+
+// primitiveDescriptors maps the descriptor of a primitive array component to its type name.
+var primitiveDescriptors = map[string]string{
+	"Z": "boolean", "B": "byte", "C": "char", "S": "short", "I": "int", "J": "long", "F": "float", "D": "double",
+}
+
+var (
+	classNameField          = loader.FieldKey{Class: "java/lang/Class", Name: "name", Descriptor: "Ljava/lang/String;"}
+	classComponentTypeField = loader.FieldKey{Class: "java/lang/Class", Name: "componentType", Descriptor: "Ljava/lang/Class;"}
+)
+
+// classDefined creates the mirror of a class as soon as it is defined and reports it to the debugger and tracer.
+func (r *Runner) classDefined(ctx context.Context, c *class.Class) error {
+	err := r.defineMirrors(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	if r.tracer != nil && r.current != nil {
+		r.tracer.ClassLoad(r.current.classEvent(c.Name))
+	}
+
+	if r.jdwp == nil {
+		return nil
+	}
+
+	return r.jdwp.classPrepared(ctx, r.current, c)
+}
+
+// defineMirrors creates the mirror of a newly defined class. Classes defined before
+// java.lang.Class itself get their mirrors together with the primitive types once it is.
+func (r *Runner) defineMirrors(ctx context.Context, c *class.Class) error {
+	if !r.loader.IsDefined("java/lang/Class") {
+		r.pendingMirrors = append(r.pendingMirrors, c.Name)
+		return nil
+	}
+
+	names := []string{c.Name}
+	if c.Name == "java/lang/Class" {
+		names = append(r.pendingMirrors, c.Name)
+		for _, name := range slices.Sorted(maps.Keys(primitiveNames)) {
+			names = append(names, name)
+		}
+
+		r.pendingMirrors = nil
+	}
+
+	for _, name := range names {
+		if _, err := r.classMirror(ctx, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// classMirror returns the java.lang.Class object of a class, array type or primitive type like int.
+// There is only one mirror per type, so mirrors can be compared by reference.
+func (r *Runner) classMirror(ctx context.Context, name string) (stack.ClassReferenceValue, error) {
+	if mirror, ok := r.mirrors[name]; ok {
+		return mirror, nil
+	}
+
+	var c *class.Class
+	if _, primitive := primitiveNames[name]; !primitive {
+		var err error
+		c, err = r.loader.Load(ctx, name)
+		if err != nil {
+			return stack.ClassReferenceValue{}, err
+		}
+
+		// loading the class may have created its mirror already
+		if mirror, ok := r.mirrors[name]; ok {
+			return mirror, nil
+		}
+	}
+
+	var componentType stack.ClassReferenceValue
+	if strings.HasPrefix(name, "[") {
+		var err error
+		componentType, err = r.classMirror(ctx, componentName(name))
+		if err != nil {
+			return stack.ClassReferenceValue{}, err
+		}
+	}
+
+	layout, err := r.loader.Layout(ctx, "java/lang/Class")
+	if err != nil {
+		return stack.ClassReferenceValue{}, err
+	}
+
+	ref, err := r.heap.AllocateObject(ctx, layout)
+	if err != nil {
+		return stack.ClassReferenceValue{}, err
+	}
+
+	if componentType.Value != stack.Null {
+		object, err := r.heap.GetObject(ref)
+		if err != nil {
+			return stack.ClassReferenceValue{}, err
+		}
+
+		setFieldIfPresent(object, classComponentTypeField, stack.ReferenceValue{Value: componentType.Value})
+	}
+
+	mirror := stack.ClassReferenceValue{Value: ref, Class: c}
+	r.mirrors[name] = mirror
+	r.mirrorNames[ref] = name
+	return mirror, nil
+}
+
+// mirrorName returns the name of the type a java.lang.Class object stands for.
+func (r *Runner) mirrorName(value stack.Value) (string, error) {
+	name, ok := r.mirrorNames[referenceOf(value)]
+	if !ok {
+		return "", fmt.Errorf("%s is not a class mirror", value)
+	}
+
+	return name, nil
+}
+
+// isClassName reports whether name is the name of a class or interface rather than an array or primitive type.
+func isClassName(name string) bool {
+	_, primitive := primitiveNames[name]
+	return !primitive && !strings.HasPrefix(name, "[")
+}
+
+// componentName returns the component type of an array class, e.g. int for [I and java/lang/String for [Ljava/lang/String;.
+func componentName(arrayName string) string {
+	component := arrayName[1:]
+	if primitive, ok := primitiveDescriptors[component]; ok {
+		return primitive
+	}
+
+	if strings.HasPrefix(component, "L") {
+		return strings.TrimSuffix(component[1:], ";")
+	}
+
+	return component
+}
@@ -0,0 +1,98 @@
+package jvm
+
+import (
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/stretchr/testify/assert"
+)
+
+func mirrorClass() *class.Class {
+	return newTestClass("java/lang/Class", "java/lang/Object").
+		field(0, "name", "Ljava/lang/String;").
+		field(0, "componentType", "Ljava/lang/Class;").
+		build()
+}
+
+func TestMirrorsCreatedAtDefinition(t *testing.T) {
+	r, ctx := newTestRunner(t, mirrorClass(), stringClass())
+
+	for _, name := range []string{"java/lang/Object", "java/lang/Class", "java/lang/String", "int", "void"} {
+		assert.Contains(t, r.mirrors, name)
+	}
+
+	mirror, err := r.classMirror(ctx, "java/lang/String")
+	assert.Nil(t, err)
+	assert.Equal(t, r.mirrors["java/lang/String"], mirror)
+	assert.Equal(t, "java/lang/String", mirror.Class.Name)
+
+	strings, err := r.classMirror(ctx, "[Ljava/lang/String;")
+	assert.Nil(t, err)
+	again, err := r.classMirror(ctx, "[Ljava/lang/String;")
+	assert.Nil(t, err)
+	assert.Equal(t, strings, again)
+
+	object, err := r.heap.GetObject(strings.Value)
+	assert.Nil(t, err)
+	componentType, err := object.GetFieldValue(classComponentTypeField)
+	assert.Nil(t, err)
+	assert.Equal(t, mirror.Value, referenceOf(componentType))
+}
+
+func TestClassNatives(t *testing.T) {
+	shape := newTestClass("Shape", "java/lang/Object").flags(class.AccPublic | class.AccInterface | class.AccAbstract)
+	square := newTestClass("Square", "java/lang/Object").flags(class.AccPublic | class.AccSuper).interfaces("Shape")
+	r, ctx := newTestRunner(t, mirrorClass(), stringClass(), shape.build(), square.build())
+
+	mirror := func(name string) stack.Value {
+		m, err := r.classMirror(ctx, name)
+		assert.Nil(t, err)
+		return m
+	}
+
+	call := func(fn NativeMethod, args ...stack.Value) stack.Value {
+		value, err := fn(ctx, r, args)
+		assert.Nil(t, err)
+		return value
+	}
+
+	name := call(classInitClassName, mirror("[LSquare;"))
+	content, err := r.goString(referenceOf(name))
+	assert.Nil(t, err)
+	assert.Equal(t, "[LSquare;", content)
+
+	name = call(classInitClassName, mirror("java/lang/String"))
+	content, err = r.goString(referenceOf(name))
+	assert.Nil(t, err)
+	assert.Equal(t, "java.lang.String", content)
+
+	assert.Equal(t, stack.BooleanValue{Value: true}, call(classIsArray, mirror("[I")))
+	assert.Equal(t, stack.BooleanValue{Value: false}, call(classIsArray, mirror("int")))
+	assert.Equal(t, stack.BooleanValue{Value: true}, call(classIsPrimitive, mirror("int")))
+	assert.Equal(t, stack.BooleanValue{Value: true}, call(classIsInterface, mirror("Shape")))
+
+	assert.Equal(t, mirror("java/lang/Object"), call(classGetSuperclass, mirror("Square")))
+	assert.Equal(t, mirror("java/lang/Object"), call(classGetSuperclass, mirror("[I")))
+	assert.Equal(t, stack.ReferenceValue{Value: stack.Null}, call(classGetSuperclass, mirror("Shape")))
+	assert.Equal(t, stack.ReferenceValue{Value: stack.Null}, call(classGetSuperclass, mirror("java/lang/Object")))
+	assert.Equal(t, stack.ReferenceValue{Value: stack.Null}, call(classGetSuperclass, mirror("int")))
+
+	layout, err := r.loader.Layout(ctx, "Square")
+	assert.Nil(t, err)
+	ref, err := r.heap.AllocateObject(ctx, layout)
+	assert.Nil(t, err)
+	object := stack.ReferenceValue{Value: ref}
+
+	assert.Equal(t, stack.BooleanValue{Value: true}, call(classIsInstance, mirror("Shape"), object))
+	assert.Equal(t, stack.BooleanValue{Value: true}, call(classIsInstance, mirror("java/lang/Object"), object))
+	assert.Equal(t, stack.BooleanValue{Value: false}, call(classIsInstance, mirror("java/lang/String"), object))
+	assert.Equal(t, stack.BooleanValue{Value: false}, call(classIsInstance, mirror("Shape"), stack.ReferenceValue{Value: stack.Null}))
+
+	assert.Equal(t, stack.BooleanValue{Value: true}, call(classIsAssignableFrom, mirror("[LShape;"), mirror("[LSquare;")))
+	assert.Equal(t, stack.BooleanValue{Value: true}, call(classIsAssignableFrom, mirror("[Ljava/lang/Object;"), mirror("[[I")))
+	assert.Equal(t, stack.BooleanValue{Value: false}, call(classIsAssignableFrom, mirror("[Ljava/lang/Object;"), mirror("[I")))
+
+	assert.Equal(t, stack.IntValue{Value: class.AccPublic}, call(classGetModifiers, mirror("Square")))
+	assert.Equal(t, stack.IntValue{Value: class.AccPublic | class.AccFinal | class.AccAbstract}, call(classGetModifiers, mirror("[LSquare;")))
+}
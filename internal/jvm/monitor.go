@@ -0,0 +1,305 @@
+package jvm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+)
+
+var threadInterruptedField = loader.FieldKey{Class: "java/lang/Thread", Name: "interrupted", Descriptor: "Z"}
+
+// monitor is the reentrant lock and wait set of an object. All fields are guarded by the interpreter lock.
+type monitor struct {
+	owner *Runner
+	count int
+	// released is closed and replaced whenever the monitor becomes free, threads trying to enter block on it
+	released chan struct{}
+	waiters  []*waiter
+}
+
+// lockRecord is a monitor entered by a thread, frame is the index of the frame holding it counted from the bottom.
+type lockRecord struct {
+	ref   stack.Reference
+	frame int
+}
+
+// waiter is a thread in the wait set of a monitor.
+type waiter struct {
+	thread   *Runner
+	notified chan struct{}
+}
+
+func newMonitor() *monitor {
+	return &monitor{released: make(chan struct{})}
+}
+
+func (m *monitor) free() {
+	m.owner = nil
+	m.count = 0
+	close(m.released)
+	m.released = make(chan struct{})
+}
+
+// monitor returns the monitor of an object or array, inflating it on first use.
+func (h *Heap) monitor(ref stack.Reference) (*monitor, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	item, err := h.item(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	switch item := item.(type) {
+	case *Object:
+		if item.monitor == nil {
+			item.monitor = newMonitor()
+		}
+
+		return item.monitor, nil
+	case *Array:
+		if item.monitor == nil {
+			item.monitor = newMonitor()
+		}
+
+		return item.monitor, nil
+	default:
+		return nil, fmt.Errorf("no monitor for %T", item)
+	}
+}
+
+// ownedMonitor returns the monitor of ref, which the thread has to own.
+func (r *Runner) ownedMonitor(ctx context.Context, ref stack.Reference) (*monitor, error) {
+	if ref == stack.Null {
+		return nil, r.newThrowable(ctx, "java/lang/NullPointerException", "", nil)
+	}
+
+	m, err := r.heap.monitor(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.owner != r {
+		return nil, r.newThrowable(ctx, "java/lang/IllegalMonitorStateException", "current thread is not owner", nil)
+	}
+
+	return m, nil
+}
+
+// monitorEnter locks the monitor of ref for the active frame, blocking while another thread owns it.
+func (r *Runner) monitorEnter(ctx context.Context, ref stack.Reference) error {
+	return r.enterMonitor(ctx, ref, r.stack.Depth()-1)
+}
+
+// enterMonitor locks the monitor of ref on behalf of the frame at index frame.
+func (r *Runner) enterMonitor(ctx context.Context, ref stack.Reference, frame int) error {
+	if ref == stack.Null {
+		return r.newThrowable(ctx, "java/lang/NullPointerException", "", nil)
+	}
+
+	m, err := r.heap.monitor(ref)
+	if err != nil {
+		return err
+	}
+
+	err = r.lockMonitor(ctx, ref, m, 1)
+	if err != nil {
+		return err
+	}
+
+	r.locks = append(r.locks, lockRecord{ref: ref, frame: frame})
+	return nil
+}
+
+// lockMonitor waits until the monitor is free or owned by the thread and adds count to its recursions.
+// Waiting ends early with the cause of ctx once it is cancelled.
+func (r *Runner) lockMonitor(ctx context.Context, ref stack.Reference, m *monitor, count int) error {
+	if m.owner != nil && m.owner != r {
+		r.blockedOn = ref
+		r.setState(threadBlocked)
+
+		defer func() {
+			r.blockedOn = stack.Null
+			r.setState(threadRunnable)
+		}()
+	}
+
+	for m.owner != nil && m.owner != r {
+		released := m.released
+		r.blocking(func() {
+			select {
+			case <-released:
+			case <-ctx.Done():
+			}
+		})
+
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+	}
+
+	m.owner = r
+	m.count += count
+	return nil
+}
+
+func (r *Runner) monitorExit(ctx context.Context, ref stack.Reference) error {
+	m, err := r.ownedMonitor(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	m.count--
+	if m.count == 0 {
+		m.free()
+	}
+
+	for i := len(r.locks) - 1; i >= 0; i-- {
+		if r.locks[i].ref == ref {
+			r.locks = slices.Delete(r.locks, i, i+1)
+			break
+		}
+	}
+
+	return nil
+}
+
+// monitorWait implements Object.wait: the monitor is released until the thread is notified, interrupted
+// or the timeout elapses, a timeout of 0 waits forever. Afterwards the monitor is locked again as often as before.
+func (r *Runner) monitorWait(ctx context.Context, ref stack.Reference, timeout time.Duration) error {
+	m, err := r.ownedMonitor(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	if r.takeInterrupt() {
+		return r.newThrowable(ctx, "java/lang/InterruptedException", "", nil)
+	}
+
+	w := &waiter{thread: r, notified: make(chan struct{}, 1)}
+	m.waiters = append(m.waiters, w)
+
+	count := m.count
+	m.free()
+
+	state := threadWaiting
+	var timer <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		timer = t.C
+		state = threadTimedWaiting
+	}
+
+	r.waitingOn = ref
+	r.setState(state)
+
+	r.blocking(func() {
+		select {
+		case <-w.notified:
+		case <-timer:
+		case <-r.wakeup:
+		case <-ctx.Done():
+		}
+	})
+
+	r.waitingOn = stack.Null
+	r.setState(threadRunnable)
+
+	m.waiters = slices.DeleteFunc(m.waiters, func(other *waiter) bool {
+		return other == w
+	})
+
+	err = r.lockMonitor(ctx, ref, m, count)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
+	if r.takeInterrupt() {
+		return r.newThrowable(ctx, "java/lang/InterruptedException", "", nil)
+	}
+
+	return nil
+}
+
+// monitorNotify wakes up one thread waiting on the monitor of ref, or all of them.
+func (r *Runner) monitorNotify(ctx context.Context, ref stack.Reference, all bool) error {
+	m, err := r.ownedMonitor(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	for len(m.waiters) > 0 {
+		w := m.waiters[0]
+		m.waiters = m.waiters[1:]
+		w.notified <- struct{}{}
+
+		if !all {
+			break
+		}
+	}
+
+	return nil
+}
+
+// synchronizedLock returns the object a synchronized method locks: the class mirror for static methods
+// and the receiver otherwise.
+func (r *Runner) synchronizedLock(ctx context.Context, c *class.Class, method *class.Method, args []stack.Value) (stack.Reference, error) {
+	if method.IsStatic() {
+		mirror, err := r.classMirror(ctx, c.Name)
+		if err != nil {
+			return stack.Null, err
+		}
+
+		return mirror.Value, nil
+	}
+
+	return referenceOf(args[0]), nil
+}
+
+// takeInterrupt reports whether the thread has been interrupted and clears the interrupt status,
+// which lives in the Thread.interrupted field.
+func (r *Runner) takeInterrupt() bool {
+	if r.thread == stack.Null {
+		return false
+	}
+
+	value, ok := r.threadField(r.thread, threadInterruptedField, threadInterruptedField)
+	if !ok || !boolOf(value) {
+		return false
+	}
+
+	r.setThreadField(r.thread, threadInterruptedField, threadInterruptedField, stack.BooleanValue{Value: false})
+
+	// a pending wakeup belongs to the interrupt that was just consumed
+	select {
+	case <-r.wakeup:
+	default:
+	}
+
+	return true
+}
+
+// interrupt wakes up the thread of the Thread object ref if it is sleeping or waiting.
+// Thread.interrupt sets the interrupt status itself before.
+func (r *Runner) interrupt(ref stack.Reference) {
+	for _, t := range r.threads {
+		if t.thread != ref {
+			continue
+		}
+
+		select {
+		case t.wakeup <- struct{}{}:
+		default:
+		}
+	}
+}
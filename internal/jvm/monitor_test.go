@@ -0,0 +1,155 @@
+package jvm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/stretchr/testify/assert"
+)
+
+// startBlocked starts a thread whose run method is the Go function body.
+func startBlocked(t *testing.T, ctx context.Context, r *Runner, body func(ctx context.Context, r *Runner) error) stack.Reference {
+	r.natives.Register("Blocked", "run", "()V", func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+		return nil, body(ctx, r)
+	})
+
+	thread := newThreadObject(t, ctx, r, "Blocked", "blocked")
+	_, err := threadStart0(ctx, r, []stack.Value{stack.ReferenceValue{Value: thread}})
+	assert.Nil(t, err)
+	return thread
+}
+
+func newLock(t *testing.T, ctx context.Context, r *Runner) stack.Reference {
+	layout, err := r.loader.Layout(ctx, "java/lang/Object")
+	assert.Nil(t, err)
+	lock, err := r.heap.AllocateObject(ctx, layout)
+	assert.Nil(t, err)
+	return lock
+}
+
+func TestMonitorReentrant(t *testing.T) {
+	r, ctx := newTestRunner(t, threadClasses()...)
+	lock := newLock(t, ctx, r)
+
+	assert.Nil(t, r.monitorEnter(ctx, lock))
+	assert.Nil(t, r.monitorEnter(ctx, lock))
+	assert.Nil(t, r.monitorExit(ctx, lock))
+
+	holds, err := threadHoldsLock(ctx, r, []stack.Value{stack.ReferenceValue{Value: lock}})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.BooleanValue{Value: true}, holds)
+
+	assert.Nil(t, r.monitorExit(ctx, lock))
+	err = r.monitorExit(ctx, lock)
+	assert.Equal(t, "java.lang.IllegalMonitorStateException: current thread is not owner", err.Error())
+
+	err = r.monitorWait(ctx, lock, 0)
+	assert.Equal(t, "java.lang.IllegalMonitorStateException: current thread is not owner", err.Error())
+}
+
+func TestSynchronizedMethodReleasesOnException(t *testing.T) {
+	counter := newTestClass("Counter", "java/lang/Object")
+	counter.method(class.AccStatic|class.AccSynchronized, "fail", "()V", throwCode(counter, "java/lang/RuntimeException")...)
+	r, ctx := newTestRunner(t, append(threadClasses(), mirrorClass(), counter.build())...)
+
+	_, err := invokeTestMethod(t, ctx, r, "Counter", "fail", "()V")
+	assert.Equal(t, "java.lang.RuntimeException\n\tCounter.fail()", err.Error())
+
+	mirror, err := r.classMirror(ctx, "Counter")
+	assert.Nil(t, err)
+	m, err := r.heap.monitor(mirror.Value)
+	assert.Nil(t, err)
+	assert.Nil(t, m.owner)
+	assert.Equal(t, 0, m.count)
+}
+
+func TestMonitorBlocksOtherThreads(t *testing.T) {
+	r, ctx := newTestRunner(t, threadClasses()...)
+	lock := newLock(t, ctx, r)
+	assert.Nil(t, r.monitorEnter(ctx, lock))
+
+	entered := false
+	startBlocked(t, ctx, r, func(ctx context.Context, r *Runner) error {
+		err := r.monitorEnter(ctx, lock)
+		entered = true
+		return err
+	})
+
+	assert.Nil(t, r.sleep(ctx, 20*time.Millisecond))
+	assert.False(t, entered)
+
+	assert.Nil(t, r.monitorExit(ctx, lock))
+	r.waitForThreads()
+	assert.True(t, entered)
+}
+
+func TestWaitNotify(t *testing.T) {
+	r, ctx := newTestRunner(t, threadClasses()...)
+	lock := newLock(t, ctx, r)
+
+	assert.Nil(t, r.monitorEnter(ctx, lock))
+	assert.Nil(t, r.monitorEnter(ctx, lock))
+
+	startBlocked(t, ctx, r, func(ctx context.Context, r *Runner) error {
+		err := r.monitorEnter(ctx, lock)
+		if err != nil {
+			return err
+		}
+
+		err = r.monitorNotify(ctx, lock, true)
+		if err != nil {
+			return err
+		}
+
+		return r.monitorExit(ctx, lock)
+	})
+
+	// the notifier can only enter once wait has released the monitor
+	assert.Nil(t, r.monitorWait(ctx, lock, 0))
+
+	m, err := r.heap.monitor(lock)
+	assert.Nil(t, err)
+	assert.Equal(t, r, m.owner)
+	assert.Equal(t, 2, m.count)
+
+	start := time.Now()
+	assert.Nil(t, r.monitorWait(ctx, lock, 10*time.Millisecond))
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+
+	r.waitForThreads()
+}
+
+func TestWaitInterrupted(t *testing.T) {
+	r, ctx := newTestRunner(t, threadClasses()...)
+	lock := newLock(t, ctx, r)
+
+	var waitErr error
+	waiting := make(chan struct{})
+	thread := startBlocked(t, ctx, r, func(ctx context.Context, r *Runner) error {
+		waitErr = r.monitorEnter(ctx, lock)
+		close(waiting)
+		if waitErr == nil {
+			waitErr = r.monitorWait(ctx, lock, 0)
+		}
+
+		return r.monitorExit(ctx, lock)
+	})
+
+	r.blocking(func() {
+		<-waiting
+	})
+
+	// Thread.interrupt sets the field before calling interrupt0, putfield stores the boolean as an int
+	assert.Nil(t, r.heap.SetField(thread, threadInterruptedField, stack.IntValue{Value: 1}))
+	_, err := threadInterrupt0(ctx, r, []stack.Value{stack.ReferenceValue{Value: thread}})
+	assert.Nil(t, err)
+	r.waitForThreads()
+
+	assert.Equal(t, "java.lang.InterruptedException", waitErr.Error())
+	interrupted, ok := r.threadField(thread, threadInterruptedField, threadInterruptedField)
+	assert.True(t, ok)
+	assert.Equal(t, stack.BooleanValue{Value: false}, interrupted)
+}
@@ -0,0 +1,25 @@
+package jvm
+
+import (
+	"context"
+)
+
+func monitorEnter(ctx context.Context, r *Runner) error {
+	r.pc += 1
+	operands, err := r.stack.PopOperands(1)
+	if err != nil {
+		return err
+	}
+
+	return r.monitorEnter(ctx, referenceOf(operands[0]))
+}
+
+func monitorExit(ctx context.Context, r *Runner) error {
+	r.pc += 1
+	operands, err := r.stack.PopOperands(1)
+	if err != nil {
+		return err
+	}
+
+	return r.monitorExit(ctx, referenceOf(operands[0]))
+}
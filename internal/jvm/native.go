@@ -0,0 +1,151 @@
+package jvm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/logger"
+)
+
+// NativeKey identifies a native method by its class, name and descriptor,
+// e.g. {"java/lang/Object", "hashCode", "()I"}.
+type NativeKey struct {
+	Class      string
+	Name       string
+	Descriptor string
+}
+
+// NativeMethod implements a native method in Go.
+// args holds the objectref first for instance methods, followed by the parameters.
+// The returned value is pushed onto the operand stack of the invoker, it has to be nil for void methods.
+// Returning a *ThrowableError throws the exception in the invoking method.
+type NativeMethod func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error)
+
+// NativeRegistry holds the Go implementations of native methods.
+type NativeRegistry struct {
+	methods map[NativeKey]NativeMethod
+}
+
+func NewNativeRegistry() *NativeRegistry {
+	return &NativeRegistry{methods: make(map[NativeKey]NativeMethod)}
+}
+
+// Register adds or replaces the implementation of a native method.
+func (n *NativeRegistry) Register(className string, name string, descriptor string, method NativeMethod) {
+	n.methods[NativeKey{Class: className, Name: name, Descriptor: descriptor}] = method
+}
+
+func (n *NativeRegistry) Lookup(key NativeKey) (NativeMethod, bool) {
+	method, ok := n.methods[key]
+	return method, ok
+}
+
+// builtinNatives creates the registry with the native methods implemented by the VM.
+func builtinNatives() *NativeRegistry {
+	natives := NewNativeRegistry()
+	registerLangNatives(natives)
+	registerUnsafeNatives(natives)
+	registerVMNatives(natives)
+	registerIONatives(natives)
+	return natives
+}
+
+// runNative invokes the registered implementation of a native method.
+// Like a bytecode method it gets its own frame, which keeps the arguments alive during garbage collection.
+func (r *Runner) runNative(ctx context.Context, c *class.Class, method *class.Method, args []stack.Value) error {
+	log := logger.FromContext(ctx)
+
+	name, err := c.ConstantPool.GetUtf8(method.NameIndex)
+	if err != nil {
+		return err
+	}
+
+	descriptor, err := c.ConstantPool.GetUtf8(method.DescriptorIndex)
+	if err != nil {
+		return err
+	}
+
+	native, ok := r.natives.Lookup(NativeKey{Class: c.Name, Name: name, Descriptor: descriptor})
+	if !ok {
+		return r.newThrowable(ctx, "java/lang/UnsatisfiedLinkError", methodSignature(c.Name, name, descriptor), nil)
+	}
+
+	log.Infow("executing native method", "class", c.Name, "name", name, "descriptor", descriptor)
+
+	args, err = nativeArgs(descriptor, args)
+	if err != nil {
+		return err
+	}
+
+	err = r.pushFrame(c, *method, args)
+	if err != nil {
+		return err
+	}
+
+	if r.tracer != nil {
+		r.traceMethodEntry(c, method, args)
+	}
+
+	result, err := native(ctx, r, args)
+
+	if r.tracer != nil {
+		if err != nil {
+			r.traceThrow(err, -1)
+		}
+
+		r.traceMethodExit(c, method, result, err)
+	}
+
+	popErr := r.stack.Pop()
+
+	if err != nil {
+		return methodError(err, c.Name, name)
+	}
+
+	if popErr != nil {
+		return popErr
+	}
+
+	if result != nil {
+		return r.stack.PushOperand(ctx, result)
+	}
+
+	return nil
+}
+
+// methodError adds the method to the stack trace of an error it completed abruptly with.
+func methodError(err error, className string, methodName string) error {
+	if throwable, ok := err.(*ThrowableError); ok {
+		throwable.trace = append(throwable.trace, fmt.Sprintf("%s.%s()", dotted(className), methodName))
+		return throwable
+	}
+
+	return fmt.Errorf("%w\n\t%s.%s()", err, dotted(className), methodName)
+}
+
+// nativeArgs converts the arguments to the parameter types of descriptor, so natives can rely on
+// the Value types, e.g. a BooleanValue for a boolean. The receiver of instance methods comes first.
+func nativeArgs(descriptor string, args []stack.Value) ([]stack.Value, error) {
+	methodDescriptor, err := class.NewMethodDescriptor(descriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := len(args) - len(methodDescriptor.Parameters)
+	if offset < 0 {
+		return nil, fmt.Errorf("%d arguments for %s", len(args), descriptor)
+	}
+
+	converted := slices.Clone(args)
+	for i, parameter := range methodDescriptor.Parameters {
+		converted[offset+i], err = convertValue(parameter, args[offset+i])
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", offset+i, err)
+		}
+	}
+
+	return converted, nil
+}
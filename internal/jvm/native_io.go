@@ -0,0 +1,307 @@
+package jvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+)
+
+var (
+	fileDescriptorFdField   = loader.FieldKey{Class: "java/io/FileDescriptor", Name: "fd", Descriptor: "I"}
+	fileOutputStreamFdField = loader.FieldKey{Class: "java/io/FileOutputStream", Name: "fd", Descriptor: "Ljava/io/FileDescriptor;"}
+	fileInputStreamFdField  = loader.FieldKey{Class: "java/io/FileInputStream", Name: "fd", Descriptor: "Ljava/io/FileDescriptor;"}
+)
+
+// registerIONatives registers the natives of the file streams, backed by the runner's file table.
+func registerIONatives(natives *NativeRegistry) {
+	natives.Register("java/io/FileDescriptor", "initIDs", "()V", noop)
+	natives.Register("java/io/FileDescriptor", "getHandle", "(I)J", fileDescriptorGetHandle)
+	natives.Register("java/io/FileDescriptor", "getAppend", "(I)Z", returnFalse)
+	natives.Register("java/io/FileDescriptor", "close0", "()V", fileDescriptorClose)
+	natives.Register("java/io/FileCleanable", "cleanupClose0", "(IJ)V", fileCleanableCleanupClose)
+
+	natives.Register("java/io/FileOutputStream", "initIDs", "()V", noop)
+	natives.Register("java/io/FileOutputStream", "open0", "(Ljava/lang/String;Z)V", fileOutputStreamOpen)
+	natives.Register("java/io/FileOutputStream", "write", "(IZ)V", fileOutputStreamWrite)
+	natives.Register("java/io/FileOutputStream", "writeBytes", "([BIIZ)V", fileOutputStreamWriteBytes)
+
+	natives.Register("java/io/FileInputStream", "initIDs", "()V", noop)
+	natives.Register("java/io/FileInputStream", "open0", "(Ljava/lang/String;)V", fileInputStreamOpen)
+	natives.Register("java/io/FileInputStream", "read0", "()I", fileInputStreamRead)
+	natives.Register("java/io/FileInputStream", "readBytes", "([BII)I", fileInputStreamReadBytes)
+	natives.Register("java/io/FileInputStream", "available0", "()I", fileInputStreamAvailable)
+}
+
+// streamFd returns the FileDescriptor object of a file stream and the descriptor it holds.
+func (r *Runner) streamFd(stream stack.Value, key loader.FieldKey) (*Object, int32, error) {
+	object, err := r.heap.GetObject(referenceOf(stream))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fdObject, err := object.GetFieldValue(key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return r.descriptor(fdObject)
+}
+
+func (r *Runner) descriptor(fdObject stack.Value) (*Object, int32, error) {
+	descriptor, err := r.heap.GetObject(referenceOf(fdObject))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fd, err := descriptor.GetFieldValue(fileDescriptorFdField)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	n, ok := intOf(fd)
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid file descriptor %v", fd)
+	}
+
+	return descriptor, int32(n), nil
+}
+
+// ioError converts a Go I/O error to an IOException.
+func (r *Runner) ioError(ctx context.Context, err error) error {
+	return r.newThrowable(ctx, "java/io/IOException", err.Error(), nil)
+}
+
+func fileDescriptorGetHandle(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	// handles only exist on Windows
+	return stack.LongValue{Value: uint64(0xffffffffffffffff)}, nil
+}
+
+func fileDescriptorClose(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	descriptor, fd, err := r.descriptor(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if fd == -1 {
+		return nil, nil
+	}
+
+	err = descriptor.SetFieldValue(fileDescriptorFdField, stack.IntValue{Value: -1})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = r.files.Close(fd); err != nil {
+		return nil, r.ioError(ctx, err)
+	}
+
+	return nil, nil
+}
+
+func fileCleanableCleanupClose(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	fd, err := intArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if fd == -1 {
+		return nil, nil
+	}
+
+	if err := r.files.Close(int32(fd)); err != nil {
+		return nil, r.ioError(ctx, err)
+	}
+
+	return nil, nil
+}
+
+// openStream opens the file name and stores its descriptor in the stream's FileDescriptor.
+func (r *Runner) openStream(ctx context.Context, stream stack.Value, key loader.FieldKey, nameValue stack.Value, flag int) error {
+	descriptor, _, err := r.streamFd(stream, key)
+	if err != nil {
+		return err
+	}
+
+	name, err := r.goString(referenceOf(nameValue))
+	if err != nil {
+		return err
+	}
+
+	fd, err := r.files.Open(name, flag)
+	if err != nil {
+		reason := err.Error()
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			reason = pathErr.Err.Error()
+		}
+
+		return r.newThrowable(ctx, "java/io/FileNotFoundException", name+" ("+reason+")", nil)
+	}
+
+	return descriptor.SetFieldValue(fileDescriptorFdField, stack.IntValue{Value: fd})
+}
+
+func fileOutputStreamOpen(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if boolOf(args[2]) {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	return nil, r.openStream(ctx, args[0], fileOutputStreamFdField, args[1], flag)
+}
+
+func fileOutputStreamWrite(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	_, fd, err := r.streamFd(args[0], fileOutputStreamFdField)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := r.files.Writer(fd)
+	if err != nil {
+		return nil, r.ioError(ctx, err)
+	}
+
+	_, err = w.Write([]byte{uint8(numericBits(args[1]))})
+	if err != nil {
+		return nil, r.ioError(ctx, err)
+	}
+
+	return nil, nil
+}
+
+// byteRange returns the bytes of a byte[] argument between off and off+length,
+// throwing like the JDK does for null arrays and invalid ranges.
+func (r *Runner) byteRange(ctx context.Context, arrayValue stack.Value, offValue stack.Value, lengthValue stack.Value) (*Array, int, int, error) {
+	ref := referenceOf(arrayValue)
+	if ref == stack.Null {
+		return nil, 0, 0, r.newThrowable(ctx, "java/lang/NullPointerException", "", nil)
+	}
+
+	array, err := r.heap.GetArray(ref)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	offArg, offOk := intOf(offValue)
+	lengthArg, lengthOk := intOf(lengthValue)
+	if !offOk || !lengthOk {
+		return nil, 0, 0, fmt.Errorf("invalid byte range %v, %v", offValue, lengthValue)
+	}
+
+	off, length := int(offArg), int(lengthArg)
+	if off < 0 || length < 0 || off+length > len(array.items) {
+		return nil, 0, 0, r.newThrowable(ctx, "java/lang/IndexOutOfBoundsException", "", nil)
+	}
+
+	return array, off, length, nil
+}
+
+func fileOutputStreamWriteBytes(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	array, off, length, err := r.byteRange(ctx, args[1], args[2], args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	_, fd, err := r.streamFd(args[0], fileOutputStreamFdField)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := r.files.Writer(fd)
+	if err != nil {
+		return nil, r.ioError(ctx, err)
+	}
+
+	bytes := make([]byte, length)
+	for i := range bytes {
+		bytes[i] = uint8(numericBits(array.items[off+i]))
+	}
+
+	_, err = w.Write(bytes)
+	if err != nil {
+		return nil, r.ioError(ctx, err)
+	}
+
+	return nil, nil
+}
+
+func fileInputStreamOpen(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	return nil, r.openStream(ctx, args[0], fileInputStreamFdField, args[1], os.O_RDONLY)
+}
+
+func fileInputStreamRead(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	_, fd, err := r.streamFd(args[0], fileInputStreamFdField)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := r.files.Reader(fd)
+	if err != nil {
+		return nil, r.ioError(ctx, err)
+	}
+
+	var b [1]byte
+	_, err = io.ReadFull(reader, b[:])
+	if errors.Is(err, io.EOF) {
+		return stack.IntValue{Value: -1}, nil
+	}
+
+	if err != nil {
+		return nil, r.ioError(ctx, err)
+	}
+
+	return stack.IntValue{Value: int32(b[0])}, nil
+}
+
+// fileInputStreamReadBytes reads at most length bytes, returning -1 at the end of the file.
+func fileInputStreamReadBytes(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	array, off, length, err := r.byteRange(ctx, args[1], args[2], args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	if length == 0 {
+		return stack.IntValue{Value: 0}, nil
+	}
+
+	_, fd, err := r.streamFd(args[0], fileInputStreamFdField)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := r.files.Reader(fd)
+	if err != nil {
+		return nil, r.ioError(ctx, err)
+	}
+
+	bytes := make([]byte, length)
+	n, err := reader.Read(bytes)
+	if n == 0 && errors.Is(err, io.EOF) {
+		return stack.IntValue{Value: -1}, nil
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, r.ioError(ctx, err)
+	}
+
+	for i, b := range bytes[:n] {
+		array.items[off+i] = stack.ByteValue{Value: b}
+	}
+
+	return stack.IntValue{Value: int32(n)}, nil
+}
+
+func fileInputStreamAvailable(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	_, fd, err := r.streamFd(args[0], fileInputStreamFdField)
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.IntValue{Value: int32(min(r.files.Available(fd), 0x7fffffff))}, nil
+}
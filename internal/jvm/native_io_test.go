@@ -0,0 +1,148 @@
+package jvm
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+// ioClasses defines the file streams, along with the exception classes.
+func ioClasses() []*class.Class {
+	descriptor := newTestClass("java/io/FileDescriptor", "java/lang/Object").
+		field(0, "fd", "I")
+	out := newTestClass("java/io/FileOutputStream", "java/lang/Object").
+		field(0, "fd", "Ljava/io/FileDescriptor;")
+	in := newTestClass("java/io/FileInputStream", "java/lang/Object").
+		field(0, "fd", "Ljava/io/FileDescriptor;")
+
+	return append(throwableClasses(), descriptor.build(), out.build(), in.build())
+}
+
+// newStream creates a stream of className whose FileDescriptor holds fd.
+func newStream(t *testing.T, ctx context.Context, r *Runner, className string, fd int32) stack.Value {
+	layout, err := r.loader.Layout(ctx, "java/io/FileDescriptor")
+	assert.Nil(t, err)
+	descriptor, err := r.heap.AllocateObject(ctx, layout)
+	assert.Nil(t, err)
+	assert.Nil(t, r.heap.SetField(descriptor, fileDescriptorFdField, stack.IntValue{Value: fd}))
+
+	layout, err = r.loader.Layout(ctx, className)
+	assert.Nil(t, err)
+	stream, err := r.heap.AllocateObject(ctx, layout)
+	assert.Nil(t, err)
+	assert.Nil(t, r.heap.SetField(stream, loader.FieldKey{Class: className, Name: "fd", Descriptor: "Ljava/io/FileDescriptor;"}, stack.ReferenceValue{Value: descriptor}))
+
+	return stack.ReferenceValue{Value: stream}
+}
+
+func byteArray(t *testing.T, ctx context.Context, r *Runner, s string) stack.Value {
+	items := make([]stack.Value, len(s))
+	for i := range len(s) {
+		items[i] = stack.ByteValue{Value: s[i]}
+	}
+
+	ref, err := r.allocateArray(ctx, "[B", items)
+	assert.Nil(t, err)
+	return stack.ReferenceValue{Value: ref}
+}
+
+func TestWriteBytesToStdout(t *testing.T) {
+	r, ctx := newTestRunner(t, ioClasses()...)
+
+	var stdout bytes.Buffer
+	WithStdout(&stdout)(r)
+
+	out := newStream(t, ctx, r, "java/io/FileOutputStream", stdoutFd)
+	_, err := fileOutputStreamWriteBytes(ctx, r, []stack.Value{out, byteArray(t, ctx, r, ">Hello world!\n<"), stack.IntValue{Value: 1}, stack.IntValue{Value: 13}, stack.BooleanValue{}})
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello world!\n", stdout.String())
+}
+
+func TestFileStreams(t *testing.T) {
+	r, ctx := newTestRunner(t, ioClasses()...)
+	WithStdin(strings.NewReader(""))(r)
+
+	path := filepath.Join(t.TempDir(), "out.txt")
+	name, err := r.newString(ctx, path)
+	assert.Nil(t, err)
+
+	out := newStream(t, ctx, r, "java/io/FileOutputStream", -1)
+	_, err = fileOutputStreamOpen(ctx, r, []stack.Value{out, stack.ReferenceValue{Value: name}, stack.BooleanValue{}})
+	assert.Nil(t, err)
+
+	_, err = fileOutputStreamWriteBytes(ctx, r, []stack.Value{out, byteArray(t, ctx, r, "swell"), stack.IntValue{Value: 0}, stack.IntValue{Value: 5}, stack.BooleanValue{}})
+	assert.Nil(t, err)
+
+	descriptor, err := r.heap.GetObject(referenceOf(out))
+	assert.Nil(t, err)
+	fd, err := descriptor.GetFieldValue(fileOutputStreamFdField)
+	assert.Nil(t, err)
+	_, err = fileDescriptorClose(ctx, r, []stack.Value{fd})
+	assert.Nil(t, err)
+
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "swell", string(content))
+
+	in := newStream(t, ctx, r, "java/io/FileInputStream", -1)
+	_, err = fileInputStreamOpen(ctx, r, []stack.Value{in, stack.ReferenceValue{Value: name}})
+	assert.Nil(t, err)
+
+	available, err := fileInputStreamAvailable(ctx, r, []stack.Value{in})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.IntValue{Value: 5}, available)
+
+	buffer := byteArray(t, ctx, r, "........")
+	n, err := fileInputStreamReadBytes(ctx, r, []stack.Value{in, buffer, stack.IntValue{Value: 1}, stack.IntValue{Value: 7}})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.IntValue{Value: 5}, n)
+
+	n, err = fileInputStreamReadBytes(ctx, r, []stack.Value{in, buffer, stack.IntValue{Value: 1}, stack.IntValue{Value: 7}})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.IntValue{Value: -1}, n)
+
+	array, err := r.heap.GetArray(referenceOf(buffer))
+	assert.Nil(t, err)
+	assert.Equal(t, stack.ByteValue{Value: 's'}, array.items[1])
+	assert.Equal(t, stack.ByteValue{Value: 'l'}, array.items[5])
+
+	// stdin is at its end right away
+	stdin := newStream(t, ctx, r, "java/io/FileInputStream", stdinFd)
+	b, err := fileInputStreamRead(ctx, r, []stack.Value{stdin})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.IntValue{Value: -1}, b)
+
+	// bytecode passes the append flag as an int
+	appended := newStream(t, ctx, r, "java/io/FileOutputStream", -1)
+	_, err = fileOutputStreamOpen(ctx, r, []stack.Value{appended, stack.ReferenceValue{Value: name}, stack.IntValue{Value: 1}})
+	assert.Nil(t, err)
+	_, err = fileOutputStreamWriteBytes(ctx, r, []stack.Value{appended, byteArray(t, ctx, r, "!"), stack.IntValue{Value: 0}, stack.IntValue{Value: 1}, stack.IntValue{Value: 0}})
+	assert.Nil(t, err)
+
+	content, err = os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "swell!", string(content))
+}
+
+func TestOpenMissingFile(t *testing.T) {
+	r, ctx := newTestRunner(t, append(ioClasses(),
+		newTestClass("java/io/IOException", "java/lang/Exception").build(),
+		newTestClass("java/io/FileNotFoundException", "java/io/IOException").build(),
+	)...)
+
+	path := filepath.Join(t.TempDir(), "missing", "in.txt")
+	name, err := r.newString(ctx, path)
+	assert.Nil(t, err)
+
+	in := newStream(t, ctx, r, "java/io/FileInputStream", -1)
+	_, err = fileInputStreamOpen(ctx, r, []stack.Value{in, stack.ReferenceValue{Value: name}})
+	assert.Equal(t, "java.io.FileNotFoundException: "+path+" (no such file or directory)", err.Error())
+}
@@ -0,0 +1,574 @@
+package jvm
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+)
+
+// vmStart is the origin of System.nanoTime, which only has to be monotonic.
+var vmStart = time.Now()
+
+// registerLangNatives registers the native methods of java.lang and its internal helpers.
+func registerLangNatives(natives *NativeRegistry) {
+	natives.Register("java/lang/System", "registerNatives", "()V", systemRegisterNatives)
+	natives.Register("java/lang/Class", "registerNatives", "()V", noop)
+	natives.Register("java/lang/Class", "desiredAssertionStatus0", "(Ljava/lang/Class;)Z", classDesiredAssertionStatus0)
+	natives.Register("java/lang/Class", "getPrimitiveClass", "(Ljava/lang/String;)Ljava/lang/Class;", classGetPrimitiveClass)
+	natives.Register("java/lang/Class", "initClassName", "()Ljava/lang/String;", classInitClassName)
+	natives.Register("java/lang/Class", "isArray", "()Z", classIsArray)
+	natives.Register("java/lang/Class", "isPrimitive", "()Z", classIsPrimitive)
+	natives.Register("java/lang/Class", "isInterface", "()Z", classIsInterface)
+	natives.Register("java/lang/Class", "getSuperclass", "()Ljava/lang/Class;", classGetSuperclass)
+	natives.Register("java/lang/Class", "isInstance", "(Ljava/lang/Object;)Z", classIsInstance)
+	natives.Register("java/lang/Class", "isAssignableFrom", "(Ljava/lang/Class;)Z", classIsAssignableFrom)
+	natives.Register("java/lang/Class", "getModifiers", "()I", classGetModifiers)
+	natives.Register("java/lang/StringUTF16", "isBigEndian", "()Z", stringUTF16IsBigEndian)
+	natives.Register("java/lang/String", "intern", "()Ljava/lang/String;", stringIntern)
+	natives.Register("java/lang/Object", "hashCode", "()I", objectHashCode)
+	natives.Register("java/lang/Object", "getClass", "()Ljava/lang/Class;", objectGetClass)
+	natives.Register("java/lang/Object", "clone", "()Ljava/lang/Object;", objectClone)
+	natives.Register("java/lang/Object", "wait", "(J)V", objectWait)
+	natives.Register("java/lang/Object", "wait0", "(J)V", objectWait)
+	natives.Register("java/lang/Object", "notify", "()V", objectNotify)
+	natives.Register("java/lang/Object", "notifyAll", "()V", objectNotifyAll)
+	natives.Register("java/lang/System", "arraycopy", "(Ljava/lang/Object;ILjava/lang/Object;II)V", systemArraycopy)
+	natives.Register("java/lang/System", "identityHashCode", "(Ljava/lang/Object;)I", objectHashCode)
+	natives.Register("java/lang/System", "nanoTime", "()J", systemNanoTime)
+	natives.Register("java/lang/System", "currentTimeMillis", "()J", systemCurrentTimeMillis)
+	natives.Register("java/lang/Float", "floatToRawIntBits", "(F)I", floatToRawIntBits)
+	natives.Register("java/lang/Double", "doubleToRawLongBits", "(D)J", doubleToRawLongBits)
+	natives.Register("java/lang/Thread", "registerNatives", "()V", noop)
+	natives.Register("java/lang/Thread", "currentThread", "()Ljava/lang/Thread;", threadCurrentThread)
+	natives.Register("java/lang/Thread", "currentCarrierThread", "()Ljava/lang/Thread;", threadCurrentThread)
+	natives.Register("java/lang/Thread", "start0", "()V", threadStart0)
+	natives.Register("java/lang/Thread", "isAlive", "()Z", threadIsAlive)
+	natives.Register("java/lang/Thread", "setPriority0", "(I)V", noop)
+	natives.Register("java/lang/Thread", "setNativeName", "(Ljava/lang/String;)V", noop)
+	natives.Register("java/lang/Thread", "yield", "()V", threadYield)
+	natives.Register("java/lang/Thread", "yield0", "()V", threadYield)
+	natives.Register("java/lang/Thread", "sleep", "(J)V", threadSleep)
+	natives.Register("java/lang/Thread", "sleep0", "(J)V", threadSleep0)
+	natives.Register("java/lang/Thread", "getNextThreadIdOffset", "()J", threadGetNextThreadIdOffset)
+	natives.Register("java/lang/Thread", "interrupt0", "()V", threadInterrupt0)
+	natives.Register("java/lang/Thread", "holdsLock", "(Ljava/lang/Object;)Z", threadHoldsLock)
+	natives.Register("java/lang/Runtime", "availableProcessors", "()I", runtimeAvailableProcessors)
+}
+
+func noop(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	return nil, nil
+}
+
+// systemRegisterNatives starts the first phase of the system initialization right away.
+func systemRegisterNatives(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	c, err := r.loader.Load(ctx, "java/lang/System")
+	if err != nil {
+		return nil, err
+	}
+
+	method, ok, err := c.GetMethod("initPhase1", "()V")
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, errors.New("method 'initPhase1' not found")
+	}
+
+	return nil, r.invokeMethod(ctx, c, method, nil)
+}
+
+func classDesiredAssertionStatus0(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	return stack.BooleanValue{Value: true}, nil
+}
+
+func stringUTF16IsBigEndian(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	return stack.BooleanValue{Value: true}, nil
+}
+
+func classGetPrimitiveClass(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	name, err := r.goString(referenceOf(args[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := primitiveNames[name]; !ok {
+		return nil, r.newThrowable(ctx, "java/lang/IllegalArgumentException", "Not a primitive type: "+name, nil)
+	}
+
+	return r.classMirror(ctx, name)
+}
+
+// objectHashCode implements Object.hashCode and System.identityHashCode, which is 0 for null.
+func objectHashCode(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	ref := referenceOf(args[0])
+	if ref == stack.Null {
+		return stack.IntValue{Value: 0}, nil
+	}
+
+	hash, err := r.heap.IdentityHash(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.IntValue{Value: hash}, nil
+}
+
+func objectGetClass(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	name, err := r.runtimeClassName(referenceOf(args[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	return r.classMirror(ctx, name)
+}
+
+// objectWait implements Object.wait(long timeoutMillis), called wait0 since JDK 21.
+func objectWait(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	millis, err := intArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if millis < 0 {
+		return nil, r.newThrowable(ctx, "java/lang/IllegalArgumentException", "timeout value is negative", nil)
+	}
+
+	return nil, r.monitorWait(ctx, referenceOf(args[0]), time.Duration(millis)*time.Millisecond)
+}
+
+func objectNotify(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	return nil, r.monitorNotify(ctx, referenceOf(args[0]), false)
+}
+
+func objectNotifyAll(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	return nil, r.monitorNotify(ctx, referenceOf(args[0]), true)
+}
+
+// objectClone makes a shallow copy of an array or of an instance of a class implementing Cloneable.
+func objectClone(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	ref := referenceOf(args[0])
+
+	className, err := r.runtimeClassName(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if isClassName(className) {
+		cloneable, err := r.implements(ctx, className, "java/lang/Cloneable")
+		if err != nil {
+			return nil, err
+		}
+
+		if !cloneable {
+			return nil, r.newThrowable(ctx, "java/lang/CloneNotSupportedException", dotted(className), nil)
+		}
+	}
+
+	clone, err := r.heap.Clone(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.ReferenceValue{Value: clone}, nil
+}
+
+// runtimeClassName returns the name of the class of a heap object or array.
+func (r *Runner) runtimeClassName(ref stack.Reference) (string, error) {
+	item, err := r.heap.get(ref)
+	if err != nil {
+		return "", err
+	}
+
+	switch item := item.(type) {
+	case *Object:
+		return item.ClassName(), nil
+	case *Array:
+		return item.ClassName(), nil
+	default:
+		return "", fmt.Errorf("no class for %T", item)
+	}
+}
+
+// classInitClassName computes the name behind Class.getName and caches it in the name field of the mirror.
+func classInitClassName(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	name, err := r.mirrorName(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := r.internString(ctx, dotted(name))
+	if err != nil {
+		return nil, err
+	}
+
+	mirror, err := r.heap.GetObject(referenceOf(args[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	value := stack.ReferenceValue{Value: ref}
+	setFieldIfPresent(mirror, classNameField, value)
+	return value, nil
+}
+
+func classIsArray(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	name, err := r.mirrorName(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.BooleanValue{Value: strings.HasPrefix(name, "[")}, nil
+}
+
+func classIsPrimitive(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	name, err := r.mirrorName(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	_, ok := primitiveNames[name]
+	return stack.BooleanValue{Value: ok}, nil
+}
+
+func classIsInterface(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	name, err := r.mirrorName(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if !isClassName(name) {
+		return stack.BooleanValue{Value: false}, nil
+	}
+
+	c, err := r.loader.Load(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.BooleanValue{Value: c.IsInterface()}, nil
+}
+
+// classGetSuperclass returns null for Object, interfaces and primitive types, and Object for arrays.
+func classGetSuperclass(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	name, err := r.mirrorName(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(name, "[") {
+		return r.classMirror(ctx, "java/lang/Object")
+	}
+
+	if !isClassName(name) {
+		return stack.ReferenceValue{Value: stack.Null}, nil
+	}
+
+	c, err := r.loader.Load(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	superName, ok, err := c.SuperClassName()
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok || c.IsInterface() {
+		return stack.ReferenceValue{Value: stack.Null}, nil
+	}
+
+	return r.classMirror(ctx, superName)
+}
+
+func classIsInstance(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	name, err := r.mirrorName(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	ref := referenceOf(args[1])
+	if ref == stack.Null {
+		return stack.BooleanValue{Value: false}, nil
+	}
+
+	className, err := r.runtimeClassName(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := r.isAssignable(ctx, className, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.BooleanValue{Value: ok}, nil
+}
+
+func classIsAssignableFrom(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	name, err := r.mirrorName(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if referenceOf(args[1]) == stack.Null {
+		return nil, r.newThrowable(ctx, "java/lang/NullPointerException", "", nil)
+	}
+
+	from, err := r.mirrorName(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := r.isAssignable(ctx, from, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.BooleanValue{Value: ok}, nil
+}
+
+// classGetModifiers returns the access flags of a class. Arrays are public if their element type is, primitive
+// types always are, and both are final and abstract.
+func classGetModifiers(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	name, err := r.mirrorName(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	element := name
+	for strings.HasPrefix(element, "[") {
+		element = componentName(element)
+	}
+
+	if !isClassName(element) {
+		return stack.IntValue{Value: class.AccPublic | class.AccFinal | class.AccAbstract}, nil
+	}
+
+	c, err := r.loader.Load(ctx, element)
+	if err != nil {
+		return nil, err
+	}
+
+	// ACC_SUPER is not a modifier of the Java language
+	flags := int32(c.AccessFlags) &^ class.AccSuper
+	if element != name {
+		flags = flags&class.AccPublic | class.AccFinal | class.AccAbstract
+	}
+
+	return stack.IntValue{Value: flags}, nil
+}
+
+// systemArraycopy copies like memmove, so overlapping ranges of the same array are handled.
+func systemArraycopy(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	srcRef, destRef := referenceOf(args[0]), referenceOf(args[2])
+
+	srcArg, err := intArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	destArg, err := intArg(args, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	lengthArg, err := intArg(args, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	srcPos, destPos, length := int(srcArg), int(destArg), int(lengthArg)
+
+	if srcRef == stack.Null || destRef == stack.Null {
+		return nil, r.newThrowable(ctx, "java/lang/NullPointerException", "", nil)
+	}
+
+	src, err := r.heap.GetArray(srcRef)
+	if err != nil {
+		return nil, r.newThrowable(ctx, "java/lang/ArrayStoreException", "arraycopy: source type is not an array", nil)
+	}
+
+	dest, err := r.heap.GetArray(destRef)
+	if err != nil {
+		return nil, r.newThrowable(ctx, "java/lang/ArrayStoreException", "arraycopy: destination type is not an array", nil)
+	}
+
+	srcComponent, destComponent := componentName(src.ClassName()), componentName(dest.ClassName())
+	_, srcPrimitive := primitiveNames[srcComponent]
+	_, destPrimitive := primitiveNames[destComponent]
+	if (srcPrimitive || destPrimitive) && srcComponent != destComponent {
+		message := fmt.Sprintf("arraycopy: type mismatch: can not copy %s into %s", dotted(src.ClassName()), dotted(dest.ClassName()))
+		return nil, r.newThrowable(ctx, "java/lang/ArrayStoreException", message, nil)
+	}
+
+	var message string
+	switch {
+	case length < 0:
+		message = fmt.Sprintf("arraycopy: length %d is negative", length)
+	case srcPos < 0:
+		message = fmt.Sprintf("arraycopy: source index %d out of bounds for length %d", srcPos, len(src.items))
+	case destPos < 0:
+		message = fmt.Sprintf("arraycopy: destination index %d out of bounds for length %d", destPos, len(dest.items))
+	case srcPos+length > len(src.items):
+		message = fmt.Sprintf("arraycopy: last source index %d out of bounds for length %d", srcPos+length, len(src.items))
+	case destPos+length > len(dest.items):
+		message = fmt.Sprintf("arraycopy: last destination index %d out of bounds for length %d", destPos+length, len(dest.items))
+	}
+
+	if message != "" {
+		return nil, r.newThrowable(ctx, "java/lang/ArrayIndexOutOfBoundsException", message, nil)
+	}
+
+	covariant, err := r.isAssignable(ctx, src.ClassName(), dest.ClassName())
+	if err != nil {
+		return nil, err
+	}
+
+	if covariant {
+		copy(dest.items[destPos:destPos+length], src.items[srcPos:srcPos+length])
+		return nil, nil
+	}
+
+	// every element has to be checked, the ones before a mismatch are still copied
+	for i := 0; i < length; i++ {
+		value := src.items[srcPos+i]
+		ok, err := r.isArrayElement(ctx, value, dest)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			message := fmt.Sprintf("arraycopy: element type mismatch: can not cast one of the elements of %s to the type of the destination array, %s",
+				dotted(src.ClassName()), dotted(destComponent))
+			return nil, r.newThrowable(ctx, "java/lang/ArrayStoreException", message, nil)
+		}
+
+		dest.items[destPos+i] = value
+	}
+
+	return nil, nil
+}
+
+func systemNanoTime(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	return stack.LongValue{Value: uint64(time.Since(vmStart).Nanoseconds())}, nil
+}
+
+func systemCurrentTimeMillis(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	return stack.LongValue{Value: uint64(time.Now().UnixMilli())}, nil
+}
+
+func floatToRawIntBits(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	f, err := floatArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.IntValue{Value: int32(math.Float32bits(f))}, nil
+}
+
+func doubleToRawLongBits(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	d, err := doubleArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.LongValue{Value: math.Float64bits(d)}, nil
+}
+
+func threadCurrentThread(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	ref, err := r.currentThread(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.ReferenceValue{Value: ref}, nil
+}
+
+func threadStart0(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	r.startThread(ctx, referenceOf(args[0]))
+	return nil, nil
+}
+
+func threadIsAlive(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	status, ok := r.threadField(referenceOf(args[0]), threadStatusField, holderThreadStatusField)
+	n, _ := intOf(status)
+	alive := ok && n&threadStatusAlive != 0
+	return stack.BooleanValue{Value: alive}, nil
+}
+
+func threadInterrupt0(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	r.interrupt(referenceOf(args[0]))
+	return nil, nil
+}
+
+func threadHoldsLock(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	ref := referenceOf(args[0])
+	if ref == stack.Null {
+		return nil, r.newThrowable(ctx, "java/lang/NullPointerException", "", nil)
+	}
+
+	m, err := r.heap.monitor(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.BooleanValue{Value: m.owner == r}, nil
+}
+
+func threadYield(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	r.yield()
+	return nil, nil
+}
+
+// threadSleep implements Thread.sleep(long millis) of JDK 17 and earlier.
+func threadSleep(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	millis, err := intArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if millis < 0 {
+		return nil, r.newThrowable(ctx, "java/lang/IllegalArgumentException", "timeout value is negative", nil)
+	}
+
+	return nil, r.sleep(ctx, time.Duration(millis)*time.Millisecond)
+}
+
+// threadSleep0 implements Thread.sleep0(long nanos) of later JDKs, which check the argument in Java.
+func threadSleep0(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	nanos, err := intArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, r.sleep(ctx, time.Duration(nanos))
+}
+
+// threadGetNextThreadIdOffset returns the address of the thread id counter ThreadIdentifiers increments with Unsafe.
+func threadGetNextThreadIdOffset(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	if r.nextThreadID == 0 {
+		r.nextThreadID = r.memory.Allocate(8)
+
+		bytes, err := r.memory.Bytes(r.nextThreadID, 8)
+		if err != nil {
+			return nil, err
+		}
+
+		binary.BigEndian.PutUint64(bytes, firstThreadID)
+	}
+
+	return stack.LongValue{Value: r.nextThreadID}, nil
+}
+
+func runtimeAvailableProcessors(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	return stack.IntValue{Value: int32(runtime.NumCPU())}, nil
+}
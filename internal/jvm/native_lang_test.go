@@ -0,0 +1,115 @@
+package jvm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/stretchr/testify/assert"
+)
+
+func intArray(t *testing.T, r *Runner, values ...int32) stack.Reference {
+	items := make([]stack.Value, len(values))
+	for i, value := range values {
+		items[i] = stack.IntValue{Value: value}
+	}
+
+	ref, err := r.allocateArray(testContext(t), "[I", items)
+	assert.Nil(t, err)
+	return ref
+}
+
+func TestObjectHashCode(t *testing.T) {
+	r, ctx := newTestRunner(t, append(throwableClasses(), newTestClass("java/lang/Class", "java/lang/Object").build())...)
+
+	a := stack.ReferenceValue{Value: intArray(t, r, 1)}
+	b := stack.ReferenceValue{Value: intArray(t, r, 1)}
+
+	hashA, err := objectHashCode(ctx, r, []stack.Value{a})
+	assert.Nil(t, err)
+	hashB, err := objectHashCode(ctx, r, []stack.Value{b})
+	assert.Nil(t, err)
+	assert.NotEqual(t, hashA, hashB)
+
+	again, err := objectHashCode(ctx, r, []stack.Value{a})
+	assert.Nil(t, err)
+	assert.Equal(t, hashA, again)
+
+	null, err := objectHashCode(ctx, r, []stack.Value{stack.ReferenceValue{Value: stack.Null}})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.IntValue{Value: 0}, null)
+
+	classA, err := objectGetClass(ctx, r, []stack.Value{a})
+	assert.Nil(t, err)
+	classB, err := objectGetClass(ctx, r, []stack.Value{b})
+	assert.Nil(t, err)
+	assert.Equal(t, classA, classB)
+}
+
+func TestSystemArraycopy(t *testing.T) {
+	r, ctx := newTestRunner(t, append(throwableClasses(),
+		newTestClass("java/lang/IndexOutOfBoundsException", "java/lang/RuntimeException").build(),
+		newTestClass("java/lang/ArrayIndexOutOfBoundsException", "java/lang/IndexOutOfBoundsException").build(),
+	)...)
+
+	ref := intArray(t, r, 1, 2, 3, 4, 5)
+	array := stack.ReferenceValue{Value: ref}
+
+	// overlapping ranges of the same array
+	_, err := systemArraycopy(ctx, r, []stack.Value{array, stack.IntValue{Value: 0}, array, stack.IntValue{Value: 1}, stack.IntValue{Value: 3}})
+	assert.Nil(t, err)
+
+	items, err := r.heap.GetArray(ref)
+	assert.Nil(t, err)
+	assert.Equal(t, []stack.Value{
+		stack.IntValue{Value: 1}, stack.IntValue{Value: 1}, stack.IntValue{Value: 2}, stack.IntValue{Value: 3}, stack.IntValue{Value: 5},
+	}, items.items)
+
+	_, err = systemArraycopy(ctx, r, []stack.Value{array, stack.IntValue{Value: 3}, array, stack.IntValue{Value: 0}, stack.IntValue{Value: 3}})
+	assert.Equal(t, "java.lang.ArrayIndexOutOfBoundsException: arraycopy: last source index 6 out of bounds for length 5", err.Error())
+	assert.NotEqual(t, stack.Null, err.(*ThrowableError).Ref)
+
+	// positions may be held by any integral Value, others are rejected instead of crashing the VM
+	_, err = systemArraycopy(ctx, r, []stack.Value{array, stack.ByteValue{Value: 4}, array, stack.ShortValue{Value: 0}, stack.IntValue{Value: 1}})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.IntValue{Value: 5}, items.items[0])
+
+	_, err = systemArraycopy(ctx, r, []stack.Value{array, array, array, stack.IntValue{Value: 0}, stack.IntValue{Value: 1}})
+	assert.ErrorContains(t, err, "not an integral value")
+}
+
+func TestClassGetPrimitiveClass(t *testing.T) {
+	str := newTestClass("java/lang/String", "java/lang/Object").
+		field(0, "value", "[B").
+		field(0, "coder", "B")
+	r, ctx := newTestRunner(t, str.build(), newTestClass("java/lang/Class", "java/lang/Object").build())
+
+	name, err := r.newString(ctx, "int")
+	assert.Nil(t, err)
+
+	intClass, err := classGetPrimitiveClass(ctx, r, []stack.Value{stack.ReferenceValue{Value: name}})
+	assert.Nil(t, err)
+
+	mirror, err := r.classMirror(ctx, "int")
+	assert.Nil(t, err)
+	assert.Equal(t, mirror, intClass)
+	assert.Nil(t, mirror.Class)
+}
+
+func TestRawBits(t *testing.T) {
+	r, ctx := newTestRunner(t)
+
+	value, err := floatToRawIntBits(ctx, r, []stack.Value{stack.FloatValue{Value: float32(math.NaN())}})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.IntValue{Value: int32(math.Float32bits(float32(math.NaN())))}, value)
+
+	value, err = doubleToRawLongBits(ctx, r, []stack.Value{stack.DoubleValue{Value: 1.5}})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.LongValue{Value: 0x3ff8000000000000}, value)
+
+	_, err = floatToRawIntBits(ctx, r, []stack.Value{stack.IntValue{Value: 1}})
+	assert.ErrorContains(t, err, "not a float")
+
+	_, err = doubleToRawLongBits(ctx, r, []stack.Value{stack.FloatValue{Value: 1.5}})
+	assert.ErrorContains(t, err, "not a double")
+}
@@ -0,0 +1,100 @@
+package jvm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNativeStatic(t *testing.T) {
+	main := newTestClass("Main", "java/lang/Object")
+	main.method(class.AccStatic|class.AccNative, "twice", "(I)I")
+	code := append([]byte{BiPush, 21, InvokeStaticOp}, u2(main.ref("Main", "twice", "(I)I"))...)
+	main.method(class.AccStatic, "call", "()I", append(code, IReturn)...)
+
+	r, ctx := newTestRunner(t, main.build())
+	WithNative("Main", "twice", "(I)I", func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+		return stack.IntValue{Value: 2 * args[0].(stack.IntValue).Value}, nil
+	})(r)
+
+	value, err := invokeTestMethod(t, ctx, r, "Main", "call", "()I")
+	assert.Nil(t, err)
+	assert.Equal(t, stack.IntValue{Value: 42}, value)
+}
+
+func TestNativeArgumentTypes(t *testing.T) {
+	main := newTestClass("Main", "java/lang/Object")
+	main.method(class.AccStatic|class.AccNative, "take", "(ZBCSI)V")
+	code := []byte{IConst1, IConstM1, IConstM1, IConstM1, IConstM1, InvokeStaticOp}
+	code = append(code, u2(main.ref("Main", "take", "(ZBCSI)V"))...)
+	main.method(class.AccStatic, "call", "()V", append(code, RetOp)...)
+
+	r, ctx := newTestRunner(t, main.build())
+
+	// bytecode passes all of them as ints
+	var args []stack.Value
+	WithNative("Main", "take", "(ZBCSI)V", func(ctx context.Context, r *Runner, a []stack.Value) (stack.Value, error) {
+		args = a
+		return nil, nil
+	})(r)
+
+	_, err := invokeTestMethod(t, ctx, r, "Main", "call", "()V")
+	assert.Nil(t, err)
+	assert.Equal(t, []stack.Value{
+		stack.BooleanValue{Value: true},
+		stack.ByteValue{Value: 0xff},
+		stack.CharValue{Value: 0xffff},
+		stack.ShortValue{Value: 0xffff},
+		stack.IntValue{Value: -1},
+	}, args)
+}
+
+func TestNativeVirtual(t *testing.T) {
+	base := newTestClass("Base", "java/lang/Object").
+		method(class.AccPublic, "id", "()I", IConst1, IReturn)
+	sub := newTestClass("Sub", "Base").
+		method(class.AccPublic|class.AccNative, "id", "()I")
+
+	main := newTestClass("Main", "java/lang/Object")
+	code := append([]byte{NewOp}, u2(main.classRef("Sub"))...)
+	code = append(code, DupOp, InvokeSpecialOp)
+	code = append(code, u2(main.ref("Sub", "<init>", "()V"))...)
+	code = append(code, InvokeVirtual)
+	code = append(code, u2(main.ref("Base", "id", "()I"))...)
+	main.method(class.AccStatic, "call", "()I", append(code, IReturn)...)
+
+	r, ctx := newTestRunner(t, base.build(), sub.build(), main.build())
+
+	var this stack.Value
+	r.natives.Register("Sub", "id", "()I", func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+		this = args[0]
+		return stack.IntValue{Value: 7}, nil
+	})
+
+	value, err := invokeTestMethod(t, ctx, r, "Main", "call", "()I")
+	assert.Nil(t, err)
+	assert.Equal(t, stack.IntValue{Value: 7}, value)
+
+	object, err := r.heap.GetObject(referenceOf(this))
+	assert.Nil(t, err)
+	assert.Equal(t, "Sub", object.ClassName())
+}
+
+func TestNativeMissing(t *testing.T) {
+	main := newTestClass("Main", "java/lang/Object")
+	main.method(class.AccStatic|class.AccNative, "missing", "()V")
+	code := append([]byte{InvokeStaticOp}, u2(main.ref("Main", "missing", "()V"))...)
+	main.method(class.AccStatic, "call", "()V", append(code, RetOp)...)
+
+	classes := append(throwableClasses(), newTestClass("java/lang/UnsatisfiedLinkError", "java/lang/LinkageError").build(), main.build())
+	r, ctx := newTestRunner(t, classes...)
+
+	_, err := invokeTestMethod(t, ctx, r, "Main", "call", "()V")
+	throwable, ok := err.(*ThrowableError)
+	assert.True(t, ok)
+	assert.NotEqual(t, stack.Null, throwable.Ref)
+	assert.Equal(t, "java.lang.UnsatisfiedLinkError: Main.missing()V\n\tMain.call()", err.Error())
+}
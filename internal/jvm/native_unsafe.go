@@ -0,0 +1,779 @@
+package jvm
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+)
+
+const unsafeClassName = "jdk/internal/misc/Unsafe"
+
+// Offsets handed out by Unsafe. Instance fields and array elements start after the object header,
+// static fields are addressed through the class mirror in a separate range.
+const (
+	fieldsOffset       = headerSize
+	fieldSlotSize      = 8
+	arrayBaseOffset    = headerSize
+	referenceSize      = 4
+	staticFieldsOffset = 1 << 20
+)
+
+var unsafeKinds = []struct {
+	name       string
+	descriptor string
+	template   stack.Value
+}{
+	{"Boolean", "Z", stack.BooleanValue{}},
+	{"Byte", "B", stack.ByteValue{}},
+	{"Short", "S", stack.ShortValue{}},
+	{"Char", "C", stack.CharValue{}},
+	{"Int", "I", stack.IntValue{}},
+	{"Long", "J", stack.LongValue{}},
+	{"Float", "F", stack.FloatValue{}},
+	{"Double", "D", stack.DoubleValue{}},
+	{"Reference", "Ljava/lang/Object;", stack.ReferenceValue{}},
+}
+
+var (
+	fieldClazzField     = loader.FieldKey{Class: "java/lang/reflect/Field", Name: "clazz", Descriptor: "Ljava/lang/Class;"}
+	fieldNameField      = loader.FieldKey{Class: "java/lang/reflect/Field", Name: "name", Descriptor: "Ljava/lang/String;"}
+	fieldModifiersField = loader.FieldKey{Class: "java/lang/reflect/Field", Name: "modifiers", Descriptor: "I"}
+)
+
+// registerUnsafeNatives registers the natives of jdk.internal.misc.Unsafe.
+// Memory barriers are no-ops, every access is sequentially consistent.
+func registerUnsafeNatives(natives *NativeRegistry) {
+	natives.Register(unsafeClassName, "registerNatives", "()V", noop)
+	natives.Register(unsafeClassName, "fullFence", "()V", noop)
+	natives.Register(unsafeClassName, "loadFence", "()V", noop)
+	natives.Register(unsafeClassName, "storeFence", "()V", noop)
+
+	natives.Register(unsafeClassName, "arrayBaseOffset0", "(Ljava/lang/Class;)I", unsafeArrayBaseOffset)
+	natives.Register(unsafeClassName, "arrayIndexScale0", "(Ljava/lang/Class;)I", unsafeArrayIndexScale)
+	natives.Register(unsafeClassName, "objectFieldOffset0", "(Ljava/lang/reflect/Field;)J", unsafeObjectFieldOffset0)
+	natives.Register(unsafeClassName, "objectFieldOffset1", "(Ljava/lang/Class;Ljava/lang/String;)J", unsafeObjectFieldOffset1)
+	natives.Register(unsafeClassName, "staticFieldOffset0", "(Ljava/lang/reflect/Field;)J", unsafeObjectFieldOffset0)
+	natives.Register(unsafeClassName, "staticFieldBase0", "(Ljava/lang/reflect/Field;)Ljava/lang/Object;", unsafeStaticFieldBase)
+
+	natives.Register(unsafeClassName, "shouldBeInitialized0", "(Ljava/lang/Class;)Z", unsafeShouldBeInitialized)
+	natives.Register(unsafeClassName, "ensureClassInitialized0", "(Ljava/lang/Class;)V", unsafeEnsureClassInitialized)
+	natives.Register(unsafeClassName, "allocateInstance", "(Ljava/lang/Class;)Ljava/lang/Object;", unsafeAllocateInstance)
+
+	natives.Register(unsafeClassName, "allocateMemory0", "(J)J", unsafeAllocateMemory)
+	natives.Register(unsafeClassName, "reallocateMemory0", "(JJ)J", unsafeReallocateMemory)
+	natives.Register(unsafeClassName, "freeMemory0", "(J)V", unsafeFreeMemory)
+	natives.Register(unsafeClassName, "setMemory0", "(Ljava/lang/Object;JJB)V", unsafeSetMemory)
+	natives.Register(unsafeClassName, "copyMemory0", "(Ljava/lang/Object;JLjava/lang/Object;JJ)V", unsafeCopyMemory)
+
+	for _, kind := range unsafeKinds {
+		for _, suffix := range []string{"", "Volatile"} {
+			natives.Register(unsafeClassName, "get"+kind.name+suffix, "(Ljava/lang/Object;J)"+kind.descriptor, unsafeGetter(kind.template))
+			natives.Register(unsafeClassName, "put"+kind.name+suffix, "(Ljava/lang/Object;J"+kind.descriptor+")V", unsafePut)
+		}
+	}
+
+	for _, kind := range unsafeKinds {
+		if kind.name != "Int" && kind.name != "Long" && kind.name != "Reference" {
+			continue
+		}
+
+		d := kind.descriptor
+		natives.Register(unsafeClassName, "compareAndSet"+kind.name, "(Ljava/lang/Object;J"+d+d+")Z", unsafeCompareAndSet(kind.template))
+		natives.Register(unsafeClassName, "compareAndExchange"+kind.name, "(Ljava/lang/Object;J"+d+d+")"+d, unsafeCompareAndExchange(kind.template))
+	}
+}
+
+func unsafeArrayBaseOffset(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	return stack.IntValue{Value: arrayBaseOffset}, nil
+}
+
+func unsafeArrayIndexScale(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	name, err := r.mirrorName(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(name) < 2 || name[0] != '[' {
+		return nil, r.newThrowable(ctx, "java/lang/IllegalArgumentException", "not an array class: "+name, nil)
+	}
+
+	return stack.IntValue{Value: int32(indexScale(name))}, nil
+}
+
+// indexScale returns the size of the elements of an array class like [I, which Unsafe offsets are
+// based on. It depends on the declared component type only, not on the Values the array holds.
+func indexScale(arrayClassName string) int64 {
+	switch arrayClassName[1] {
+	case 'Z', 'B':
+		return 1
+	case 'C', 'S':
+		return 2
+	case 'I', 'F':
+		return 4
+	case 'J', 'D':
+		return 8
+	default:
+		return referenceSize
+	}
+}
+
+// fieldOffset returns the Unsafe offset of the field declared by c with the given name.
+func (r *Runner) fieldOffset(ctx context.Context, c *class.Class, name string) (int64, error) {
+	for i, field := range c.Fields {
+		fieldName, err := c.ConstantPool.GetUtf8(field.NameIndex)
+		if err != nil {
+			return 0, err
+		}
+
+		if fieldName != name {
+			continue
+		}
+
+		if field.IsStatic() {
+			return staticFieldsOffset + int64(i)*fieldSlotSize, nil
+		}
+
+		descriptor, err := c.ConstantPool.GetUtf8(field.DescriptorIndex)
+		if err != nil {
+			return 0, err
+		}
+
+		layout, err := r.loader.Layout(ctx, c.Name)
+		if err != nil {
+			return 0, err
+		}
+
+		index, ok := layout.Index(loader.FieldKey{Class: c.Name, Name: name, Descriptor: descriptor})
+		if !ok {
+			return 0, fmt.Errorf("field %s missing in layout of %s", name, c.Name)
+		}
+
+		return fieldsOffset + int64(index)*fieldSlotSize, nil
+	}
+
+	return 0, r.newThrowable(ctx, "java/lang/InternalError", name, nil)
+}
+
+// reflectedField returns the declaring class and name of a java.lang.reflect.Field.
+func (r *Runner) reflectedField(ctx context.Context, value stack.Value) (*class.Class, string, error) {
+	field, err := r.heap.GetObject(referenceOf(value))
+	if err != nil {
+		return nil, "", err
+	}
+
+	clazz, err := field.GetFieldValue(fieldClazzField)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nameValue, err := field.GetFieldValue(fieldNameField)
+	if err != nil {
+		return nil, "", err
+	}
+
+	name, err := r.goString(referenceOf(nameValue))
+	if err != nil {
+		return nil, "", err
+	}
+
+	className, err := r.mirrorName(clazz)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c, err := r.loader.Load(ctx, className)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return c, name, nil
+}
+
+func unsafeObjectFieldOffset0(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	c, name, err := r.reflectedField(ctx, args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := r.fieldOffset(ctx, c, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.LongValue{Value: uint64(offset)}, nil
+}
+
+func unsafeObjectFieldOffset1(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	className, err := r.mirrorName(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := r.loader.Load(ctx, className)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := r.goString(referenceOf(args[2]))
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := r.fieldOffset(ctx, c, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.LongValue{Value: uint64(offset)}, nil
+}
+
+func unsafeStaticFieldBase(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	field, err := r.heap.GetObject(referenceOf(args[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	modifiers, err := field.GetFieldValue(fieldModifiersField)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, _ := intOf(modifiers); n&class.AccStatic == 0 {
+		return stack.ReferenceValue{Value: stack.Null}, nil
+	}
+
+	return field.GetFieldValue(fieldClazzField)
+}
+
+func unsafeShouldBeInitialized(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	name, err := r.mirrorName(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.BooleanValue{Value: r.initState(name) != classInitialized}, nil
+}
+
+func unsafeEnsureClassInitialized(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	name, err := r.mirrorName(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, r.initializeClass(ctx, name)
+}
+
+func unsafeAllocateInstance(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	name, err := r.mirrorName(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, primitive := primitiveNames[name]; primitive || name[0] == '[' {
+		return nil, r.newThrowable(ctx, "java/lang/InstantiationException", dotted(name), nil)
+	}
+
+	err = r.initializeClass(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	layout, err := r.loader.Layout(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := r.heap.AllocateObject(ctx, layout)
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.ReferenceValue{Value: ref}, nil
+}
+
+func unsafeAllocateMemory(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	size, err := intArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.LongValue{Value: r.memory.Allocate(int(size))}, nil
+}
+
+func unsafeReallocateMemory(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	address, err := intArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := intArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	newAddress, err := r.memory.Reallocate(uint64(address), int(size))
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.LongValue{Value: newAddress}, nil
+}
+
+func unsafeFreeMemory(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	address, err := intArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, r.memory.Free(uint64(address))
+}
+
+func unsafeSetMemory(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	offset, err := intArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := intArg(args, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	value := args[4]
+
+	for i := range size {
+		err := r.unsafePutBits(args[1], offset+i, 1, numericBits(value))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+func unsafeCopyMemory(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	srcOffset, err := intArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	destOffset, err := intArg(args, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := intArg(args, 5)
+	if err != nil {
+		return nil, err
+	}
+
+	// the bytes are buffered, so the range has to be checked before allocating for it
+	err = r.unsafeCheckRange(args[1], srcOffset, size)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.unsafeCheckRange(args[3], destOffset, size)
+	if err != nil {
+		return nil, err
+	}
+
+	bytes := make([]uint64, size)
+	for i := range size {
+		b, err := r.unsafeGetBits(args[1], srcOffset+i, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		bytes[i] = b
+	}
+
+	for i, b := range bytes {
+		err := r.unsafePutBits(args[3], destOffset+int64(i), 1, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+func unsafeGetter(template stack.Value) NativeMethod {
+	return func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+		offset, err := intArg(args, 2)
+		if err != nil {
+			return nil, err
+		}
+
+		return r.unsafeGet(ctx, args[1], offset, template)
+	}
+}
+
+func unsafePut(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	offset, err := intArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, r.unsafeSet(ctx, args[1], offset, args[3])
+}
+
+func unsafeCompareAndSet(template stack.Value) NativeMethod {
+	return func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+		witness, err := r.unsafeCompareAndExchange(ctx, args, template)
+		if err != nil {
+			return nil, err
+		}
+
+		return stack.BooleanValue{Value: sameValue(witness, args[3])}, nil
+	}
+}
+
+func unsafeCompareAndExchange(template stack.Value) NativeMethod {
+	return func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+		return r.unsafeCompareAndExchange(ctx, args, template)
+	}
+}
+
+// unsafeCompareAndExchange sets the location to args[4] if it holds args[3], and returns the previous value.
+func (r *Runner) unsafeCompareAndExchange(ctx context.Context, args []stack.Value, template stack.Value) (stack.Value, error) {
+	offset, err := intArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	witness, err := r.unsafeGet(ctx, args[1], offset, template)
+	if err != nil {
+		return nil, err
+	}
+
+	if sameValue(witness, args[3]) {
+		err = r.unsafeSet(ctx, args[1], offset, args[4])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return witness, nil
+}
+
+// sameValue compares references by identity and primitives by their bits, like the CAS instructions do.
+func sameValue(a stack.Value, b stack.Value) bool {
+	if isReferenceValue(a) || isReferenceValue(b) {
+		return referenceOf(a) == referenceOf(b)
+	}
+
+	return numericBits(a) == numericBits(b)
+}
+
+func isReferenceValue(value stack.Value) bool {
+	switch value.(type) {
+	case stack.ReferenceValue, stack.ClassReferenceValue:
+		return true
+	default:
+		return false
+	}
+}
+
+// unsafeGet reads the value of the kind of template at base and offset.
+// A null base addresses off-heap memory, offset being the absolute address.
+func (r *Runner) unsafeGet(ctx context.Context, base stack.Value, offset int64, template stack.Value) (stack.Value, error) {
+	if !isReferenceValue(template) {
+		bits, err := r.unsafeGetBits(base, offset, valueSize(template))
+		if err != nil {
+			return nil, err
+		}
+
+		return valueOfBits(template, bits), nil
+	}
+
+	slot, err := r.unsafeSlot(base, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return slot.load()
+}
+
+func (r *Runner) unsafeSet(ctx context.Context, base stack.Value, offset int64, value stack.Value) error {
+	if !isReferenceValue(value) {
+		return r.unsafePutBits(base, offset, valueSize(value), numericBits(value))
+	}
+
+	slot, err := r.unsafeSlot(base, offset)
+	if err != nil {
+		return err
+	}
+
+	return slot.store(value)
+}
+
+// slot is a field, static field or array element addressed through Unsafe.
+type slot interface {
+	load() (stack.Value, error)
+	store(value stack.Value) error
+}
+
+type valueSlot struct {
+	value *stack.Value
+}
+
+func (s valueSlot) load() (stack.Value, error) {
+	return *s.value, nil
+}
+
+func (s valueSlot) store(value stack.Value) error {
+	*s.value = value
+	return nil
+}
+
+type staticSlot struct {
+	loader *loader.Loader
+	key    loader.FieldKey
+}
+
+func (s staticSlot) load() (stack.Value, error) {
+	return s.loader.GetField(s.key)
+}
+
+func (s staticSlot) store(value stack.Value) error {
+	return s.loader.SetField(s.key, value)
+}
+
+// unsafeSlot returns the field, static field or array element at base and offset.
+func (r *Runner) unsafeSlot(base stack.Value, offset int64) (slot, error) {
+	ref := referenceOf(base)
+	if ref == stack.Null {
+		return nil, fmt.Errorf("no value slot in off-heap memory at %#x", offset)
+	}
+
+	item, err := r.heap.get(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	switch item := item.(type) {
+	case *Object:
+		if offset >= staticFieldsOffset {
+			return r.staticFieldSlot(base, offset)
+		}
+
+		index := (offset - fieldsOffset) / fieldSlotSize
+		if offset < fieldsOffset || index >= int64(len(item.fields)) {
+			return nil, fmt.Errorf("invalid field offset %d for %s", offset, item.ClassName())
+		}
+
+		return valueSlot{value: &item.fields[index]}, nil
+	case *Array:
+		scale := indexScale(item.ClassName())
+		index := (offset - arrayBaseOffset) / scale
+		if offset < arrayBaseOffset || index >= int64(len(item.items)) {
+			return nil, fmt.Errorf("invalid array offset %d for length %d", offset, len(item.items))
+		}
+
+		return valueSlot{value: &item.items[index]}, nil
+	default:
+		return nil, fmt.Errorf("unsafe access not supported for %T", item)
+	}
+}
+
+// staticSlot returns the static field of the class mirror at offset, as returned by staticFieldOffset.
+func (r *Runner) staticFieldSlot(mirror stack.Value, offset int64) (slot, error) {
+	className, err := r.mirrorName(mirror)
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := r.mirrors[className]
+	if !ok || c.Class == nil {
+		return nil, fmt.Errorf("no static fields in %s", className)
+	}
+
+	index := (offset - staticFieldsOffset) / fieldSlotSize
+	if index >= int64(len(c.Class.Fields)) {
+		return nil, fmt.Errorf("invalid static field offset %d for %s", offset, className)
+	}
+
+	field := c.Class.Fields[index]
+
+	name, err := c.Class.ConstantPool.GetUtf8(field.NameIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptor, err := c.Class.ConstantPool.GetUtf8(field.DescriptorIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	return staticSlot{loader: &r.loader, key: loader.FieldKey{Class: className, Name: name, Descriptor: descriptor}}, nil
+}
+
+// unsafeGetBits reads size bytes at base and offset, in big endian order like the UTF16 strings.
+// unsafeCheckRange returns an error unless size bytes starting at offset of base, an array,
+// an object or off-heap memory, can be accessed.
+func (r *Runner) unsafeCheckRange(base stack.Value, offset int64, size int64) error {
+	if size < 0 {
+		return fmt.Errorf("negative size %d", size)
+	}
+
+	ref := referenceOf(base)
+	if ref == stack.Null {
+		_, err := r.memory.Bytes(uint64(offset), int(size))
+		return err
+	}
+
+	item, err := r.heap.get(ref)
+	if err != nil {
+		return err
+	}
+
+	var start, length int64
+	switch item := item.(type) {
+	case *Array:
+		start, length = arrayBaseOffset, int64(len(item.items))*indexScale(item.ClassName())
+	case *Object:
+		start, length = fieldsOffset, int64(len(item.fields))*fieldSlotSize
+	default:
+		return fmt.Errorf("no memory at %s", ref)
+	}
+
+	if offset < start || size > length || offset-start > length-size {
+		return fmt.Errorf("%d bytes at offset %d out of bounds of %s", size, offset, ref)
+	}
+
+	return nil
+}
+
+func (r *Runner) unsafeGetBits(base stack.Value, offset int64, size int) (uint64, error) {
+	ref := referenceOf(base)
+	if ref == stack.Null {
+		bytes, err := r.memory.Bytes(uint64(offset), size)
+		if err != nil {
+			return 0, err
+		}
+
+		var buf [8]byte
+		copy(buf[8-size:], bytes)
+		return binary.BigEndian.Uint64(buf[:]), nil
+	}
+
+	if array, err := r.heap.GetArray(ref); err == nil {
+		return arrayBits(array, offset-arrayBaseOffset, size)
+	}
+
+	slot, err := r.unsafeSlot(base, offset)
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := slot.load()
+	if err != nil {
+		return 0, err
+	}
+
+	return numericBits(value), nil
+}
+
+func (r *Runner) unsafePutBits(base stack.Value, offset int64, size int, bits uint64) error {
+	ref := referenceOf(base)
+	if ref == stack.Null {
+		bytes, err := r.memory.Bytes(uint64(offset), size)
+		if err != nil {
+			return err
+		}
+
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], bits)
+		copy(bytes, buf[8-size:])
+		return nil
+	}
+
+	if array, err := r.heap.GetArray(ref); err == nil {
+		return setArrayBits(array, offset-arrayBaseOffset, size, bits)
+	}
+
+	slot, err := r.unsafeSlot(base, offset)
+	if err != nil {
+		return err
+	}
+
+	value, err := slot.load()
+	if err != nil {
+		return err
+	}
+
+	if isReferenceValue(value) {
+		return fmt.Errorf("primitive write to reference at offset %d", offset)
+	}
+
+	return slot.store(valueOfBits(value, bits))
+}
+
+// arrayBits reads size bytes starting at byte offset of a primitive array.
+// The bytes may span several elements, like a long read from a byte[].
+func arrayBits(array *Array, offset int64, size int) (uint64, error) {
+	scale := indexScale(array.ClassName())
+	if int64(size) == scale && offset%scale == 0 && offset >= 0 && offset/scale < int64(len(array.items)) {
+		return numericBits(array.items[offset/scale]), nil
+	}
+
+	var bits uint64
+	for i := range int64(size) {
+		position := offset + i
+		index := position / scale
+		if position < 0 || index >= int64(len(array.items)) {
+			return 0, fmt.Errorf("invalid array offset %d for length %d", offset, len(array.items))
+		}
+
+		shift := 8 * (scale - 1 - position%scale)
+		bits = bits<<8 | (numericBits(array.items[index])>>shift)&0xff
+	}
+
+	return bits, nil
+}
+
+func setArrayBits(array *Array, offset int64, size int, bits uint64) error {
+	scale := indexScale(array.ClassName())
+	for i := range int64(size) {
+		position := offset + i
+		index := position / scale
+		if position < 0 || index >= int64(len(array.items)) {
+			return fmt.Errorf("invalid array offset %d for length %d", offset, len(array.items))
+		}
+
+		shift := 8 * (scale - 1 - position%scale)
+		b := (bits >> (8 * (int64(size) - 1 - i))) & 0xff
+		old := numericBits(array.items[index])
+		array.items[index] = valueOfBits(array.items[index], old&^(0xff<<shift)|b<<shift)
+	}
+
+	return nil
+}
+
+// valueOfBits creates a value of the same kind as template from its bit pattern.
+func valueOfBits(template stack.Value, bits uint64) stack.Value {
+	switch template.(type) {
+	case stack.BooleanValue:
+		return stack.BooleanValue{Value: bits&0xff != 0}
+	case stack.ByteValue:
+		return stack.ByteValue{Value: uint8(bits)}
+	case stack.CharValue:
+		return stack.CharValue{Value: rune(uint16(bits))}
+	case stack.ShortValue:
+		return stack.ShortValue{Value: uint16(bits)}
+	case stack.LongValue:
+		return stack.LongValue{Value: bits}
+	case stack.FloatValue:
+		return stack.FloatValue{Value: math.Float32frombits(uint32(bits))}
+	case stack.DoubleValue:
+		return stack.DoubleValue{Value: math.Float64frombits(bits)}
+	default:
+		return stack.IntValue{Value: int32(uint32(bits))}
+	}
+}
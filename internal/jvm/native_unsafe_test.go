@@ -0,0 +1,152 @@
+package jvm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+var counterNextField = loader.FieldKey{Class: "Counter", Name: "next", Descriptor: "LCounter;"}
+var counterCountField = loader.FieldKey{Class: "Counter", Name: "count", Descriptor: "J"}
+
+// callUnsafe invokes an Unsafe native, passing a null receiver.
+func callUnsafe(t *testing.T, ctx context.Context, r *Runner, name string, descriptor string, args ...stack.Value) stack.Value {
+	native, ok := r.natives.Lookup(NativeKey{Class: unsafeClassName, Name: name, Descriptor: descriptor})
+	assert.True(t, ok, name)
+
+	value, err := native(ctx, r, append([]stack.Value{stack.ReferenceValue{Value: stack.Null}}, args...))
+	assert.Nil(t, err)
+	return value
+}
+
+func long(value int64) stack.LongValue {
+	return stack.LongValue{Value: uint64(value)}
+}
+
+func TestUnsafeFields(t *testing.T) {
+	str := newTestClass("java/lang/String", "java/lang/Object").
+		field(0, "value", "[B").
+		field(0, "coder", "B")
+	counter := newTestClass("Counter", "java/lang/Object").
+		field(class.AccStatic, "count", "J").
+		field(0, "value", "I").
+		field(0, "next", "LCounter;")
+
+	r, ctx := newTestRunner(t, str.build(), newTestClass("java/lang/Class", "java/lang/Object").build(), counter.build())
+
+	mirror, err := r.classMirror(ctx, "Counter")
+	assert.Nil(t, err)
+
+	name, err := r.newString(ctx, "value")
+	assert.Nil(t, err)
+	offset := callUnsafe(t, ctx, r, "objectFieldOffset1", "(Ljava/lang/Class;Ljava/lang/String;)J", mirror, stack.ReferenceValue{Value: name})
+
+	layout, err := r.loader.Layout(ctx, "Counter")
+	assert.Nil(t, err)
+	ref, err := r.heap.AllocateObject(ctx, layout)
+	assert.Nil(t, err)
+	object := stack.ReferenceValue{Value: ref}
+
+	callUnsafe(t, ctx, r, "putInt", "(Ljava/lang/Object;JI)V", object, offset, stack.IntValue{Value: 41})
+
+	set := callUnsafe(t, ctx, r, "compareAndSetInt", "(Ljava/lang/Object;JII)Z", object, offset, stack.IntValue{Value: 41}, stack.IntValue{Value: 42})
+	assert.Equal(t, stack.BooleanValue{Value: true}, set)
+
+	set = callUnsafe(t, ctx, r, "compareAndSetInt", "(Ljava/lang/Object;JII)Z", object, offset, stack.IntValue{Value: 41}, stack.IntValue{Value: 43})
+	assert.Equal(t, stack.BooleanValue{Value: false}, set)
+
+	value := callUnsafe(t, ctx, r, "getIntVolatile", "(Ljava/lang/Object;J)I", object, offset)
+	assert.Equal(t, stack.IntValue{Value: 42}, value)
+
+	name, err = r.newString(ctx, "next")
+	assert.Nil(t, err)
+	offset = callUnsafe(t, ctx, r, "objectFieldOffset1", "(Ljava/lang/Class;Ljava/lang/String;)J", mirror, stack.ReferenceValue{Value: name})
+
+	witness := callUnsafe(t, ctx, r, "compareAndExchangeReference", "(Ljava/lang/Object;JLjava/lang/Object;Ljava/lang/Object;)Ljava/lang/Object;",
+		object, offset, stack.ReferenceValue{Value: stack.Null}, object)
+	assert.Equal(t, stack.ReferenceValue{Value: stack.Null}, witness)
+
+	counterObject, err := r.heap.GetObject(ref)
+	assert.Nil(t, err)
+	next, err := counterObject.GetFieldValue(counterNextField)
+	assert.Nil(t, err)
+	assert.Equal(t, object, next)
+
+	// static fields are addressed through the mirror
+	name, err = r.newString(ctx, "count")
+	assert.Nil(t, err)
+	offset = callUnsafe(t, ctx, r, "objectFieldOffset1", "(Ljava/lang/Class;Ljava/lang/String;)J", mirror, stack.ReferenceValue{Value: name})
+
+	callUnsafe(t, ctx, r, "putLong", "(Ljava/lang/Object;JJ)V", mirror, offset, long(7))
+	count, err := r.loader.GetField(counterCountField)
+	assert.Nil(t, err)
+	assert.Equal(t, long(7), count)
+}
+
+func TestUnsafeArrays(t *testing.T) {
+	r, ctx := newTestRunner(t, newTestClass("java/lang/Class", "java/lang/Object").build())
+
+	bytesMirror, err := r.classMirror(ctx, "[B")
+	assert.Nil(t, err)
+	scale := callUnsafe(t, ctx, r, "arrayIndexScale0", "(Ljava/lang/Class;)I", bytesMirror)
+	assert.Equal(t, stack.IntValue{Value: 1}, scale)
+	base := callUnsafe(t, ctx, r, "arrayBaseOffset0", "(Ljava/lang/Class;)I", bytesMirror)
+
+	ref, err := r.allocateDefaultArray(ctx, "[B", 8)
+	assert.Nil(t, err)
+	array := stack.ReferenceValue{Value: ref}
+	offset := int64(base.(stack.IntValue).Value)
+
+	callUnsafe(t, ctx, r, "putLong", "(Ljava/lang/Object;JJ)V", array, long(offset), long(0x0102030405060708))
+	value := callUnsafe(t, ctx, r, "getByte", "(Ljava/lang/Object;J)B", array, long(offset+1))
+	assert.Equal(t, stack.ByteValue{Value: 2}, value)
+
+	value = callUnsafe(t, ctx, r, "getInt", "(Ljava/lang/Object;J)I", array, long(offset+2))
+	assert.Equal(t, stack.IntValue{Value: 0x03040506}, value)
+
+	// offsets follow the component type even if the elements are held by wider Values
+	ref, err = r.heap.AllocateArray(ctx, "[B", []stack.Value{stack.IntValue{Value: 1}, stack.IntValue{Value: 2}})
+	assert.Nil(t, err)
+	value = callUnsafe(t, ctx, r, "getByte", "(Ljava/lang/Object;J)B", stack.ReferenceValue{Value: ref}, long(offset+1))
+	assert.Equal(t, stack.ByteValue{Value: 2}, value)
+}
+
+func TestUnsafeMemory(t *testing.T) {
+	r, ctx := newTestRunner(t)
+
+	address := callUnsafe(t, ctx, r, "allocateMemory0", "(J)J", long(16))
+	callUnsafe(t, ctx, r, "setMemory0", "(Ljava/lang/Object;JJB)V", stack.ReferenceValue{Value: stack.Null}, address, long(16), stack.ByteValue{Value: 0xff})
+	callUnsafe(t, ctx, r, "putInt", "(Ljava/lang/Object;JI)V", stack.ReferenceValue{Value: stack.Null}, address, stack.IntValue{Value: 0x01020304})
+
+	value := callUnsafe(t, ctx, r, "getLong", "(Ljava/lang/Object;J)J", stack.ReferenceValue{Value: stack.Null}, address)
+	assert.Equal(t, long(0x01020304ffffffff), value)
+
+	ref, err := r.allocateDefaultArray(ctx, "[I", 4)
+	assert.Nil(t, err)
+	callUnsafe(t, ctx, r, "copyMemory0", "(Ljava/lang/Object;JLjava/lang/Object;JJ)V",
+		stack.ReferenceValue{Value: stack.Null}, address, stack.ReferenceValue{Value: ref}, long(arrayBaseOffset), long(8))
+
+	array, err := r.heap.GetArray(ref)
+	assert.Nil(t, err)
+	assert.Equal(t, []stack.Value{stack.IntValue{Value: 0x01020304}, stack.IntValue{Value: -1}, stack.IntValue{}, stack.IntValue{}}, array.items)
+
+	// sizes beyond the source or destination fail before anything is copied
+	copyMemory, _ := r.natives.Lookup(NativeKey{Class: unsafeClassName, Name: "copyMemory0", Descriptor: "(Ljava/lang/Object;JLjava/lang/Object;JJ)V"})
+	for _, size := range []int64{-1, 17, 1 << 62} {
+		_, err = copyMemory(ctx, r, []stack.Value{stack.ReferenceValue{Value: stack.Null},
+			stack.ReferenceValue{Value: stack.Null}, address, stack.ReferenceValue{Value: ref}, long(arrayBaseOffset), long(size)})
+		assert.NotNil(t, err, "%d", size)
+	}
+
+	_, err = copyMemory(ctx, r, []stack.Value{stack.ReferenceValue{Value: stack.Null},
+		stack.ReferenceValue{Value: stack.Null}, address, stack.ReferenceValue{Value: ref}, long(arrayBaseOffset + 4), long(16)})
+	assert.NotNil(t, err)
+
+	callUnsafe(t, ctx, r, "freeMemory0", "(J)V", address)
+	_, err = r.memory.Bytes(address.(stack.LongValue).Value, 1)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,104 @@
+package jvm
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+)
+
+const unsafeConstantsClassName = "jdk/internal/misc/UnsafeConstants"
+
+// registerVMNatives registers the natives of jdk.internal.misc.VM and CDS.
+// Class data sharing is not supported, so there is never an archive to initialize from.
+func registerVMNatives(natives *NativeRegistry) {
+	natives.Register("jdk/internal/misc/VM", "initialize", "()V", noop)
+	natives.Register("jdk/internal/misc/VM", "initializeFromArchive", "(Ljava/lang/Class;)V", noop)
+	natives.Register("jdk/internal/misc/VM", "getNanoTimeAdjustment", "(J)J", vmGetNanoTimeAdjustment)
+	natives.Register("jdk/internal/misc/VM", "getRuntimeArguments", "()[Ljava/lang/String;", vmGetRuntimeArguments)
+	natives.Register("jdk/internal/misc/VM", "latestUserDefinedLoader0", "()Ljava/lang/ClassLoader;", returnNull)
+
+	natives.Register("jdk/internal/misc/CDS", "initializeFromArchive", "(Ljava/lang/Class;)V", noop)
+	natives.Register("jdk/internal/misc/CDS", "isDumpingClassList0", "()Z", returnFalse)
+	natives.Register("jdk/internal/misc/CDS", "isDumpingArchive0", "()Z", returnFalse)
+	natives.Register("jdk/internal/misc/CDS", "isSharingEnabled0", "()Z", returnFalse)
+	natives.Register("jdk/internal/misc/CDS", "getRandomSeedForDumping", "()J", vmRandomSeedForDumping)
+}
+
+func returnNull(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	return stack.ReferenceValue{Value: stack.Null}, nil
+}
+
+func returnFalse(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	return stack.BooleanValue{Value: false}, nil
+}
+
+// vmGetNanoTimeAdjustment returns the current time in nanoseconds relative to offset seconds,
+// or -1 if the difference doesn't fit, see jdk.internal.misc.VM.
+func vmGetNanoTimeAdjustment(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	offset, err := intArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	seconds := now.Unix() - offset
+	if seconds > 0xffffffff || seconds < -0xffffffff {
+		return stack.LongValue{Value: uint64(0xffffffffffffffff)}, nil
+	}
+
+	return stack.LongValue{Value: uint64(seconds*int64(time.Second) + int64(now.Nanosecond()))}, nil
+}
+
+func vmGetRuntimeArguments(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	ref, err := r.allocateArray(ctx, "[Ljava/lang/String;", make([]stack.Value, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	return stack.ReferenceValue{Value: ref}, nil
+}
+
+func vmRandomSeedForDumping(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	return stack.LongValue{Value: 0}, nil
+}
+
+// injectVMFields sets the static fields the VM provides once their class is initialized.
+func (r *Runner) injectVMFields(className string) error {
+	switch className {
+	case unsafeConstantsClassName:
+		return r.injectUnsafeConstants()
+	case "java/lang/String":
+		return r.injectCompactStrings()
+	default:
+		return nil
+	}
+}
+
+// injectUnsafeConstants sets the fields of UnsafeConstants after its initialization, like HotSpot does.
+// Unsafe combines bytes in big endian order and unaligned access is left to the Java fallbacks.
+func (r *Runner) injectUnsafeConstants() error {
+	constant := func(name string, descriptor string) loader.FieldKey {
+		return loader.FieldKey{Class: unsafeConstantsClassName, Name: name, Descriptor: descriptor}
+	}
+
+	constants := map[loader.FieldKey]stack.Value{
+		constant("ADDRESS_SIZE0", "I"):              stack.IntValue{Value: 8},
+		constant("PAGE_SIZE", "I"):                  stack.IntValue{Value: int32(os.Getpagesize())},
+		constant("BIG_ENDIAN", "Z"):                 stack.BooleanValue{Value: true},
+		constant("UNALIGNED_ACCESS", "Z"):           stack.BooleanValue{Value: false},
+		constant("DATA_CACHE_LINE_FLUSH_SIZE", "I"): stack.IntValue{Value: 0},
+	}
+
+	for key, value := range constants {
+		err := r.loader.SetField(key, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
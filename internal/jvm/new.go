@@ -30,15 +30,15 @@ func new(r *Runner, ctx context.Context, code []byte) error {
 		return err
 	}
 
-	c, err := r.loader.Load(ctx, className)
+	layout, err := r.loader.Layout(ctx, className)
 	if err != nil {
 		return err
 	}
 
-	id, err := r.heap.AllocateObject(ctx, c)
+	ref, err := r.heap.AllocateObject(ctx, layout)
 	if err != nil {
 		return err
 	}
 
-	return r.stack.PushOperand(ctx, stack.ReferenceValue{Value: id})
+	return r.stack.PushOperand(ctx, stack.ReferenceValue{Value: ref})
 }
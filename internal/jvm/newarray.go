@@ -7,15 +7,17 @@ import (
 	"github.com/m4tthewde/swell/internal/jvm/stack"
 )
 
+// arrayTypes maps the atype operand of newarray to the array class it creates.
+var arrayTypes = map[byte]string{
+	4: "[Z", 5: "[C", 6: "[F", 7: "[D", 8: "[B", 9: "[S", 10: "[I", 11: "[J",
+}
+
 func newArray(r *Runner, ctx context.Context, code []byte) error {
 	aType := code[r.pc+1]
 	r.pc += 2
 
-	var defaultValue stack.Value
-	switch aType {
-	case 8:
-		defaultValue = stack.ByteValue{Value: 0}
-	default:
+	className, ok := arrayTypes[aType]
+	if !ok {
 		return fmt.Errorf("invalid atype: %v", aType)
 	}
 
@@ -36,10 +38,10 @@ func newArray(r *Runner, ctx context.Context, code []byte) error {
 		return fmt.Errorf("count has to be >= 0, is  %s", count)
 	}
 
-	id, err := r.heap.AllocateDefaultArray(ctx, int(count.Value), defaultValue)
+	ref, err := r.allocateDefaultArray(ctx, className, int(count.Value))
 	if err != nil {
 		return err
 	}
 
-	return r.stack.PushOperand(ctx, stack.ReferenceValue{Value: id})
+	return r.stack.PushOperand(ctx, stack.ReferenceValue{Value: ref})
 }
@@ -0,0 +1,146 @@
+package jvm
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+
+	"go.uber.org/zap"
+)
+
+type Option func(*Runner)
+
+// WithMaxHeapSize limits the heap to size bytes, like -Xmx.
+// Allocations that do not fit after a collection raise an OutOfMemoryError.
+func WithMaxHeapSize(size int) Option {
+	return func(r *Runner) {
+		r.heap.SetLimit(size)
+	}
+}
+
+// WithGCLog writes a line for every garbage collection to w, like -Xlog:gc.
+func WithGCLog(w io.Writer) Option {
+	return func(r *Runner) {
+		r.heap.SetGCLog(w)
+	}
+}
+
+// WithHeapDumpOnOutOfMemory writes an HPROF heap dump to path when the first OutOfMemoryError is raised.
+func WithHeapDumpOnOutOfMemory(path string) Option {
+	return func(r *Runner) {
+		r.heap.onOutOfMemory = func(ctx context.Context) {
+			r.dumpHeapToFile(ctx, path)
+		}
+	}
+}
+
+// WithHeapDumpOnExit writes an HPROF heap dump to path once the main method has finished.
+func WithHeapDumpOnExit(path string) Option {
+	return func(r *Runner) {
+		r.heapDumpOnExit = path
+	}
+}
+
+// WithNative registers a Go implementation of a native method, replacing a built-in one with the same key.
+func WithNative(className string, name string, descriptor string, method NativeMethod) Option {
+	return func(r *Runner) {
+		r.natives.Register(className, name, descriptor, method)
+	}
+}
+
+// WithStdout sets the writer behind file descriptor 1, which System.out writes to.
+func WithStdout(w io.Writer) Option {
+	return func(r *Runner) {
+		r.files.Set(stdoutFd, w)
+	}
+}
+
+// WithStderr sets the writer behind file descriptor 2, which System.err writes to.
+func WithStderr(w io.Writer) Option {
+	return func(r *Runner) {
+		r.files.Set(stderrFd, w)
+	}
+}
+
+// WithStdin sets the reader behind file descriptor 0, which System.in reads from.
+func WithStdin(reader io.Reader) Option {
+	return func(r *Runner) {
+		r.files.Set(stdinFd, reader)
+	}
+}
+
+// WithCompactStrings sets whether strings that fit are stored as LATIN1, like -XX:+CompactStrings.
+func WithCompactStrings(enabled bool) Option {
+	return func(r *Runner) {
+		r.compactStrings = enabled
+	}
+}
+
+// WithLogger logs the work of the VM to log instead of the logger on the contexts passed in.
+func WithLogger(log *zap.SugaredLogger) Option {
+	return func(r *Runner) {
+		r.logger = log
+	}
+}
+
+// WithJDWP runs a JDWP agent a debugger like jdb can attach to through listener, like
+// -agentlib:jdwp=transport=dt_socket,server=y. With suspend set RunMain waits for the debugger
+// to attach and resume the VM before it runs the main method.
+func WithJDWP(listener net.Listener, suspend bool) Option {
+	return func(r *Runner) {
+		r.jdwp = newJDWPAgent(listener, suspend)
+	}
+}
+
+// WithDebugger runs the program under the interactive command-line debugger, which reads
+// commands from in and writes to out. RunMain lets the user set breakpoints before main runs.
+func WithDebugger(in io.Reader, out io.Writer) Option {
+	return func(r *Runner) {
+		r.debugger = newDebugger(in, out)
+	}
+}
+
+// WithTracer reports the execution to t, e.g. a NewTextTracer wrapped in a TraceFilter.
+func WithTracer(t Tracer) Option {
+	return func(r *Runner) {
+		r.tracer = t
+		r.heap.onAllocate = func(ctx context.Context, ref stack.Reference, item HeapItem) {
+			if r.current != nil {
+				r.current.traceAllocation(ref, item)
+			}
+		}
+	}
+}
+
+// WithInstructionBudget stops the VM with a BudgetExceededError after n bytecode instructions
+// in one call to RunMain or InvokeStatic, counted over all threads.
+func WithInstructionBudget(n int64) Option {
+	return func(r *Runner) {
+		r.budgets.instructions = n
+	}
+}
+
+// WithTimeBudget stops the VM with a BudgetExceededError once RunMain has been running for d.
+func WithTimeBudget(d time.Duration) Option {
+	return func(r *Runner) {
+		r.budgets.wallTime = d
+	}
+}
+
+// WithHeapBudget stops the VM with a BudgetExceededError when the heap would grow beyond size bytes.
+// Unlike WithMaxHeapSize the program can not catch it as an OutOfMemoryError.
+func WithHeapBudget(size int) Option {
+	return func(r *Runner) {
+		r.heap.SetBudget(size)
+	}
+}
+
+// WithStackDepthBudget stops the VM with a BudgetExceededError when a thread calls more than depth methods deep.
+func WithStackDepthBudget(depth int) Option {
+	return func(r *Runner) {
+		r.budgets.stackDepth = depth
+	}
+}
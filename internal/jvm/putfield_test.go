@@ -0,0 +1,62 @@
+package jvm
+
+import (
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPutFieldNarrowsInts(t *testing.T) {
+	holder := newTestClass("Holder", "java/lang/Object").
+		field(0, "flag", "Z").
+		field(0, "b", "B").
+		field(0, "c", "C").
+		field(0, "s", "S").
+		field(class.AccStatic, "FLAG", "Z")
+
+	code := []byte{}
+	for _, field := range []struct {
+		name       string
+		descriptor string
+	}{{"b", "B"}, {"c", "C"}, {"s", "S"}} {
+		code = append(code, Aload0, IConstM1, PutField)
+		code = append(code, u2(holder.ref("Holder", field.name, field.descriptor))...)
+	}
+
+	// booleans keep the lowest bit only
+	code = append(code, Aload0, IConst2, PutField)
+	code = append(code, u2(holder.ref("Holder", "flag", "Z"))...)
+	code = append(code, IConst3, PutStatic)
+	code = append(code, u2(holder.ref("Holder", "FLAG", "Z"))...)
+	holder.method(class.AccStatic, "set", "(LHolder;)V", append(code, RetOp)...)
+
+	r, ctx := newTestRunner(t, holder.build())
+
+	layout, err := r.loader.Layout(ctx, "Holder")
+	assert.NoError(t, err)
+	ref, err := r.heap.AllocateObject(ctx, layout)
+	assert.NoError(t, err)
+
+	_, err = invokeTestMethod(t, ctx, r, "Holder", "set", "(LHolder;)V", stack.ReferenceValue{Value: ref})
+	assert.NoError(t, err)
+
+	object, err := r.heap.GetObject(ref)
+	assert.NoError(t, err)
+	for key, expected := range map[loader.FieldKey]stack.Value{
+		{Class: "Holder", Name: "flag", Descriptor: "Z"}: stack.BooleanValue{Value: false},
+		{Class: "Holder", Name: "b", Descriptor: "B"}:    stack.ByteValue{Value: 0xff},
+		{Class: "Holder", Name: "c", Descriptor: "C"}:    stack.CharValue{Value: 0xffff},
+		{Class: "Holder", Name: "s", Descriptor: "S"}:    stack.ShortValue{Value: 0xffff},
+	} {
+		value, err := object.GetFieldValue(key)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, value, key.Name)
+	}
+
+	value, err := r.loader.GetField(loader.FieldKey{Class: "Holder", Name: "FLAG", Descriptor: "Z"})
+	assert.NoError(t, err)
+	assert.Equal(t, stack.BooleanValue{Value: true}, value)
+}
@@ -2,58 +2,38 @@ package jvm
 
 import (
 	"context"
+
+	"github.com/m4tthewde/swell/internal/class"
 )
 
 func putstatic(r *Runner, ctx context.Context, code []byte) error {
 	index := (uint16(code[r.pc+1])<<8 | uint16(code[r.pc+2]))
 	r.pc += 3
 
-	pool, err := r.stack.CurrentConstantPool()
-	if err != nil {
-		return err
-	}
-
-	fieldRef, err := pool.Ref(index)
-	if err != nil {
-		return err
-	}
-
-	classInfo, err := pool.Class(fieldRef.ClassIndex)
-	if err != nil {
-		return err
-	}
-
-	className, err := pool.GetUtf8(classInfo.NameIndex)
-	if err != nil {
-		return err
-	}
-
-	_, err = r.loader.Load(ctx, className)
+	key, _, err := resolveFieldRef(r, ctx, index)
 	if err != nil {
 		return err
 	}
 
-	nameAndType, err := pool.NameAndType(fieldRef.NameAndTypeIndex)
+	err = r.initializeClass(ctx, key.Class)
 	if err != nil {
 		return err
 	}
 
-	fieldName, err := pool.GetUtf8(nameAndType.NameIndex)
+	operands, err := r.stack.PopOperands(1)
 	if err != nil {
 		return err
 	}
 
-	err = r.initializeClass(ctx, className)
+	fieldType, err := class.NewFieldType(key.Descriptor)
 	if err != nil {
 		return err
 	}
 
-	operands, err := r.stack.PopOperands(1)
+	value, err := convertValue(fieldType, operands[0])
 	if err != nil {
 		return err
 	}
 
-	value := operands[0]
-
-	return r.loader.SetField(className, fieldName, value)
+	return r.loader.SetField(key, value)
 }
@@ -0,0 +1,96 @@
+package jvm
+
+import (
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+)
+
+// yieldInterval is the number of instructions a thread executes before giving other threads a turn.
+const yieldInterval = 1000
+
+// scheduler runs Java threads as goroutines. Only the thread holding the interpreter lock executes
+// bytecode or touches the heap and loader, it is released every yieldInterval instructions and
+// around blocking operations like Thread.sleep.
+type scheduler struct {
+	lock    sync.Mutex
+	threads []*Runner
+	// nonDaemon counts the running non-daemon threads the VM waits for before exiting
+	nonDaemon sync.WaitGroup
+	// nextThreadID is the address of the counter behind Thread.getNextThreadIdOffset
+	nextThreadID uint64
+	// current is the thread holding the interpreter lock
+	current *Runner
+	// external counts the goroutines other than Java threads waiting for the interpreter lock,
+	// like the SIGQUIT handler. A thread running alone only yields while there are some.
+	external atomic.Int32
+}
+
+// newThread creates a runner for the Java thread object ref that shares the vm.
+func (v *vm) newThread(ref stack.Reference) *Runner {
+	t := &Runner{vm: v, stack: stack.NewStack(), thread: ref, wakeup: make(chan struct{}, 1)}
+	v.threads = append(v.threads, t)
+	return t
+}
+
+// removeThread forgets a terminated thread, the caller has to hold the interpreter lock.
+func (v *vm) removeThread(t *Runner) {
+	v.threads = slices.DeleteFunc(v.threads, func(other *Runner) bool {
+		return other == t
+	})
+}
+
+// acquire takes the interpreter lock and installs the allocation handles of the thread.
+func (r *Runner) acquire() {
+	r.lock.Lock()
+	r.holding = true
+	r.current = r
+	r.heap.swapHandles(r.handles)
+	r.handles = nil
+}
+
+// release saves the allocation handles of the thread and gives up the interpreter lock.
+func (r *Runner) release() {
+	r.handles = r.heap.swapHandles(nil)
+	r.holding = false
+	r.lock.Unlock()
+}
+
+// enter takes the interpreter lock for a call from the host into the VM, unless the thread
+// holds it already, and returns the function giving it back.
+func (r *Runner) enter() func() {
+	if r.holding {
+		return func() {}
+	}
+
+	r.acquire()
+	return r.release
+}
+
+// lockExternal takes the interpreter lock for a goroutine that is not a Java thread, the running
+// thread hands it over at its next safepoint. It is given back with s.lock.Unlock.
+func (s *scheduler) lockExternal() {
+	s.external.Add(1)
+	defer s.external.Add(-1)
+
+	s.lock.Lock()
+}
+
+// blocking runs fn without the interpreter lock, so other threads can run while it blocks.
+func (r *Runner) blocking(fn func()) {
+	r.release()
+	defer r.acquire()
+
+	fn()
+}
+
+// yield lets other threads run.
+func (r *Runner) yield() {
+	r.steps = 0
+	if len(r.threads) > 1 || r.external.Load() > 0 {
+		r.blocking(runtime.Gosched)
+	}
+}
@@ -15,6 +15,19 @@ type Frame struct {
 	constantPool   class.ConstantPool
 	operands       []Value
 	localVariables []Value
+	// pc is the instruction the frame is executing, for caller frames the invoke instruction
+	pc int
+}
+
+// TraceFrame describes a frame of a stack trace.
+type TraceFrame struct {
+	ClassName  string
+	MethodName string
+	Method     class.Method
+	Pc         int
+	// LocalVariables and Operands are shared with the frame, they must not be modified
+	LocalVariables []Value
+	Operands       []Value
 }
 
 func NewFrame(
@@ -74,6 +87,18 @@ func (s *Stack) PopOperands(count int) ([]Value, error) {
 	return operands, nil
 }
 
+// ClearOperands empties the operand stack of the active frame, as done when an exception is caught.
+func (s *Stack) ClearOperands() error {
+	frame, err := s.activeFrame()
+	if err != nil {
+		return err
+	}
+
+	frame.operands = frame.operands[:0]
+	s.frames[len(s.frames)-1] = *frame
+	return nil
+}
+
 func (s *Stack) PushOperand(ctx context.Context, operand Value) error {
 	frame, err := s.activeFrame()
 	if err != nil {
@@ -117,6 +142,20 @@ func (s *Stack) PushOperandInvoker(ctx context.Context, operand Value) error {
 	return nil
 }
 
+// InvokerOperand returns the top operand of the invoker, e.g. the value pushed by PushOperandInvoker.
+func (s *Stack) InvokerOperand() (Value, error) {
+	if len(s.frames) < 2 {
+		return nil, errors.New("stack has no invoker")
+	}
+
+	frame := s.frames[len(s.frames)-2]
+	if len(frame.operands) == 0 {
+		return nil, errors.New("invoker has no operands")
+	}
+
+	return frame.operands[len(frame.operands)-1], nil
+}
+
 func (s *Stack) GetOperand() (Value, error) {
 	frame, err := s.activeFrame()
 	if err != nil {
@@ -199,3 +238,59 @@ func (s *Stack) Operands() ([]Value, error) {
 
 	return frame.operands, nil
 }
+
+// SetPc records the instruction the active frame is executing.
+func (s *Stack) SetPc(pc int) {
+	if n := len(s.frames); n > 0 {
+		s.frames[n-1].pc = pc
+	}
+}
+
+// Depth returns the number of frames.
+func (s *Stack) Depth() int {
+	return len(s.frames)
+}
+
+// Trace returns the frames starting with the active one.
+func (s *Stack) Trace() []TraceFrame {
+	trace := make([]TraceFrame, 0, len(s.frames))
+	for i := len(s.frames) - 1; i >= 0; i-- {
+		frame := s.frames[i]
+		name, _ := frame.constantPool.GetUtf8(frame.method.NameIndex)
+		trace = append(trace, TraceFrame{ClassName: frame.className, MethodName: name, Method: frame.method, Pc: frame.pc,
+			LocalVariables: frame.localVariables, Operands: frame.operands})
+	}
+
+	return trace
+}
+
+// VisitValues calls visit for every operand and local variable of every frame.
+func (s *Stack) VisitValues(visit func(Value)) {
+	for _, frame := range s.frames {
+		for _, operand := range frame.operands {
+			visit(operand)
+		}
+
+		for _, localVariable := range frame.localVariables {
+			if localVariable != nil {
+				visit(localVariable)
+			}
+		}
+	}
+}
+
+// VisitFrameValues is like VisitValues, but also passes the depth of the frame, 0 being the active one.
+func (s *Stack) VisitFrameValues(visit func(depth int, value Value)) {
+	for i, frame := range s.frames {
+		depth := len(s.frames) - 1 - i
+		for _, operand := range frame.operands {
+			visit(depth, operand)
+		}
+
+		for _, localVariable := range frame.localVariables {
+			if localVariable != nil {
+				visit(depth, localVariable)
+			}
+		}
+	}
+}
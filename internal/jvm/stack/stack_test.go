@@ -22,7 +22,7 @@ func TestStackPushPop(t *testing.T) {
 }
 
 func TestStackPushOperand(t *testing.T) {
-	log, err := logger.NewLogger()
+	log, err := logger.NewLogger("")
 	assert.Nil(t, err)
 
 	ctx := logger.OnContext(t.Context(), log)
@@ -46,7 +46,7 @@ func TestStackPushOperand(t *testing.T) {
 }
 
 func TestStackPopOperand(t *testing.T) {
-	log, err := logger.NewLogger()
+	log, err := logger.NewLogger("")
 	assert.Nil(t, err)
 
 	ctx := logger.OnContext(t.Context(), log)
@@ -77,7 +77,7 @@ func TestStackPopOperand(t *testing.T) {
 }
 
 func TestStackPushOperandInvoker(t *testing.T) {
-	log, err := logger.NewLogger()
+	log, err := logger.NewLogger("")
 	assert.Nil(t, err)
 
 	ctx := logger.OnContext(t.Context(), log)
@@ -117,7 +117,7 @@ func TestStackSetLocalVariable(t *testing.T) {
 
 	value := BooleanValue{Value: false}
 
-	log, err := logger.NewLogger()
+	log, err := logger.NewLogger("")
 	assert.Nil(t, err)
 
 	ctx := logger.OnContext(t.Context(), log)
@@ -132,7 +132,7 @@ func TestStackSetLocalVariable(t *testing.T) {
 
 /*
 func TestStackPopMultipleOperands(t *testing.T) {
-	log, err := logger.NewLogger()
+	log, err := logger.NewLogger("")
 	assert.Nil(t, err)
 
 	ctx := logger.OnContext(t.Context(), log)
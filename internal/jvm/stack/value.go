@@ -3,7 +3,6 @@ package stack
 import (
 	"fmt"
 
-	"github.com/google/uuid"
 	"github.com/m4tthewde/swell/internal/class"
 )
 
@@ -14,11 +13,11 @@ type Value interface {
 
 func DefaultValue(typ class.FieldType) (Value, error) {
 	if _, ok := typ.(class.ObjectType); ok {
-		return ReferenceValue{Value: nil}, nil
+		return ReferenceValue{Value: Null}, nil
 	}
 
 	if _, ok := typ.(class.ArrayType); ok {
-		return ReferenceValue{Value: nil}, nil
+		return ReferenceValue{Value: Null}, nil
 	}
 
 	switch typ {
@@ -32,6 +31,12 @@ func DefaultValue(typ class.FieldType) (Value, error) {
 		return ByteValue{Value: 0}, nil
 	case class.BaseType('C'):
 		return CharValue{Value: 0}, nil
+	case class.BaseType('S'):
+		return ShortValue{Value: 0}, nil
+	case class.BaseType('F'):
+		return FloatValue{Value: 0}, nil
+	case class.BaseType('D'):
+		return DoubleValue{Value: 0}, nil
 	default:
 		// TODO: add DefaultValue function to FieldType interface so that switch becomes obsolete
 		return nil, fmt.Errorf("unknown field type %s", typ)
@@ -102,8 +107,22 @@ func (v DoubleValue) String() string {
 	return fmt.Sprintf("Double=%f", v.Value)
 }
 
+// Reference is the index of an item on the heap.
+// The zero value is never allocated and represents null.
+type Reference uint32
+
+const Null Reference = 0
+
+func (r Reference) String() string {
+	if r == Null {
+		return "null"
+	}
+
+	return fmt.Sprintf("%#x", uint32(r))
+}
+
 type ReferenceValue struct {
-	Value *uuid.UUID
+	Value Reference
 }
 
 func (v ReferenceValue) String() string {
@@ -111,16 +130,21 @@ func (v ReferenceValue) String() string {
 }
 
 func (v ReferenceValue) IsNull() bool {
-	return v.Value == nil
+	return v.Value == Null
 }
 
 type ClassReferenceValue struct {
 	// reference to the Class object
-	Value *uuid.UUID
+	Value Reference
+	// Class is nil for primitive types and arrays
 	Class *class.Class
 }
 
 func (v ClassReferenceValue) String() string {
+	if v.Class == nil {
+		return fmt.Sprintf("ClassReference=%s", v.Value)
+	}
+
 	return fmt.Sprintf("ClassReference=%s", v.Class.Name)
 }
 
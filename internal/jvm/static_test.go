@@ -0,0 +1,61 @@
+package jvm
+
+import (
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticFieldsArePrepared(t *testing.T) {
+	base := newTestClass("Base", "java/lang/Object")
+	base.field(class.AccStatic, "count", "I").
+		field(class.AccStatic, "count", "J").
+		field(class.AccStatic, "name", "Ljava/lang/String;")
+
+	r, _ := newTestRunner(t, base.build())
+
+	value, err := r.loader.GetField(loader.FieldKey{Class: "Base", Name: "count", Descriptor: "I"})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.IntValue{Value: 0}, value)
+
+	value, err = r.loader.GetField(loader.FieldKey{Class: "Base", Name: "count", Descriptor: "J"})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.LongValue{Value: 0}, value)
+
+	value, err = r.loader.GetField(loader.FieldKey{Class: "Base", Name: "name", Descriptor: "Ljava/lang/String;"})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.ReferenceValue{Value: stack.Null}, value)
+
+	err = r.loader.SetField(loader.FieldKey{Class: "Base", Name: "missing", Descriptor: "I"}, stack.IntValue{Value: 1})
+	assert.ErrorIs(t, err, loader.ErrNoSuchField)
+}
+
+func TestGetStaticConstantFromSuperclass(t *testing.T) {
+	base := newTestClass("Base", "java/lang/Object")
+	base.field(class.AccStatic|class.AccFinal, "MAX", "I",
+		class.ConstantValueAttribute{ConstantValueIndex: base.add(class.IntegerInfo{Value: 7})})
+	// not final, so the ConstantValue attribute is ignored
+	base.field(class.AccStatic, "MIN", "I",
+		class.ConstantValueAttribute{ConstantValueIndex: base.add(class.IntegerInfo{Value: 3})})
+
+	sub := newTestClass("Sub", "Base")
+
+	main := newTestClass("Main", "java/lang/Object")
+	maxRef := main.ref("Sub", "MAX", "I")
+	minRef := main.ref("Sub", "MIN", "I")
+	main.method(class.AccPublic|class.AccStatic, "max", "()I", append([]byte{GetStaticOp}, append(u2(maxRef), IReturn)...)...)
+	main.method(class.AccPublic|class.AccStatic, "min", "()I", append([]byte{GetStaticOp}, append(u2(minRef), IReturn)...)...)
+
+	r, ctx := newTestRunner(t, base.build(), sub.build(), main.build())
+
+	value, err := invokeTestMethod(t, ctx, r, "Main", "max", "()I")
+	assert.Nil(t, err)
+	assert.Equal(t, stack.IntValue{Value: 7}, value)
+
+	value, err = invokeTestMethod(t, ctx, r, "Main", "min", "()I")
+	assert.Nil(t, err)
+	assert.Equal(t, stack.IntValue{Value: 0}, value)
+}
@@ -0,0 +1,163 @@
+package jvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+)
+
+var stringValueField = loader.FieldKey{Class: "java/lang/String", Name: "value", Descriptor: "[B"}
+var stringCoderField = loader.FieldKey{Class: "java/lang/String", Name: "coder", Descriptor: "B"}
+var stringCompactStringsField = loader.FieldKey{Class: "java/lang/String", Name: "COMPACT_STRINGS", Descriptor: "Z"}
+
+const (
+	stringCoderLatin1 = 0
+	stringCoderUTF16  = 1
+)
+
+// newString creates a java.lang.String. Like with -XX:+CompactStrings, text that fits is stored as LATIN1,
+// everything else as UTF16 in big endian order, matching StringUTF16.isBigEndian.
+func (r *Runner) newString(ctx context.Context, value string) (stack.Reference, error) {
+	layout, err := r.loader.Layout(ctx, "java/lang/String")
+	if err != nil {
+		return stack.Null, err
+	}
+
+	strRef, err := r.heap.AllocateObject(ctx, layout)
+	if err != nil {
+		return stack.Null, err
+	}
+
+	units := class.EncodeUTF16(value)
+
+	coder := uint8(stringCoderLatin1)
+	for _, unit := range units {
+		if unit > 0xff || !r.compactStrings {
+			coder = stringCoderUTF16
+			break
+		}
+	}
+
+	byteArray := make([]stack.Value, 0, len(units))
+	for _, unit := range units {
+		if coder == stringCoderUTF16 {
+			byteArray = append(byteArray, stack.ByteValue{Value: uint8(unit >> 8)})
+		}
+
+		byteArray = append(byteArray, stack.ByteValue{Value: uint8(unit)})
+	}
+
+	arrayRef, err := r.allocateArray(ctx, "[B", byteArray)
+	if err != nil {
+		return stack.Null, err
+	}
+
+	err = r.heap.SetField(strRef, stringValueField, stack.ReferenceValue{Value: arrayRef})
+	if err != nil {
+		return stack.Null, err
+	}
+
+	err = r.heap.SetField(strRef, stringCoderField, stack.ByteValue{Value: coder})
+	if err != nil {
+		return stack.Null, err
+	}
+
+	return strRef, nil
+}
+
+// goString returns the contents of a java.lang.String.
+func (r *Runner) goString(ref stack.Reference) (string, error) {
+	str, err := r.heap.GetObject(ref)
+	if err != nil {
+		return "", err
+	}
+
+	value, err := str.GetFieldValue(stringValueField)
+	if err != nil {
+		return "", err
+	}
+
+	coder, err := str.GetFieldValue(stringCoderField)
+	if err != nil {
+		return "", err
+	}
+
+	array, err := r.heap.GetArray(referenceOf(value))
+	if err != nil {
+		return "", err
+	}
+
+	// fields and elements written by bytecode hold IntValues rather than ByteValues
+	bytes := make([]uint8, len(array.items))
+	for i, item := range array.items {
+		b, ok := intOf(item)
+		if !ok {
+			return "", fmt.Errorf("string value has to be a byte array, contains %s", item)
+		}
+
+		bytes[i] = uint8(b)
+	}
+
+	if n, _ := intOf(coder); n == stringCoderLatin1 {
+		runes := make([]rune, len(bytes))
+		for i, b := range bytes {
+			runes[i] = rune(b)
+		}
+
+		return string(runes), nil
+	}
+
+	units := make([]uint16, len(bytes)/2)
+	for i := range units {
+		units[i] = uint16(bytes[2*i])<<8 | uint16(bytes[2*i+1])
+	}
+
+	return class.DecodeUTF16(units), nil
+}
+
+// internString returns the canonical String with the given contents, creating it on first use.
+// String literals are interned, so equal literals are the same object.
+func (r *Runner) internString(ctx context.Context, value string) (stack.Reference, error) {
+	if ref, ok := r.strings[value]; ok {
+		return ref, nil
+	}
+
+	ref, err := r.newString(ctx, value)
+	if err != nil {
+		return stack.Null, err
+	}
+
+	r.strings[value] = ref
+	return ref, nil
+}
+
+// stringIntern implements String.intern, the receiver becomes the canonical instance if there is none yet.
+func stringIntern(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+	ref := referenceOf(args[0])
+
+	value, err := r.goString(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if interned, ok := r.strings[value]; ok {
+		return stack.ReferenceValue{Value: interned}, nil
+	}
+
+	r.strings[value] = ref
+	return stack.ReferenceValue{Value: ref}, nil
+}
+
+// injectCompactStrings sets String.COMPACT_STRINGS after its initialization, like HotSpot does.
+func (r *Runner) injectCompactStrings() error {
+	err := r.loader.SetField(stringCompactStringsField, stack.BooleanValue{Value: r.compactStrings})
+	if errors.Is(err, loader.ErrNoSuchField) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,150 @@
+package jvm
+
+import (
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/stretchr/testify/assert"
+)
+
+func stringClass() *class.Class {
+	return newTestClass("java/lang/String", "java/lang/Object").
+		field(0, "value", "[B").
+		field(0, "coder", "B").
+		field(class.AccStatic|class.AccFinal, "COMPACT_STRINGS", "Z").
+		build()
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	for _, compact := range []bool{true, false} {
+		r, ctx := newTestRunner(t, stringClass())
+		WithCompactStrings(compact)(r)
+
+		for _, s := range []string{"", "main", "grüße", "日本", "a\x00b", "🙂", "\xed\xa0\x80 lone surrogate"} {
+			ref, err := r.newString(ctx, s)
+			assert.Nil(t, err)
+
+			value, err := r.goString(ref)
+			assert.Nil(t, err)
+			assert.Equal(t, s, value)
+		}
+	}
+}
+
+func TestStringCoder(t *testing.T) {
+	r, ctx := newTestRunner(t, stringClass())
+
+	coder := func(s string) stack.Value {
+		ref, err := r.newString(ctx, s)
+		assert.Nil(t, err)
+
+		str, err := r.heap.GetObject(ref)
+		assert.Nil(t, err)
+		value, err := str.GetFieldValue(stringCoderField)
+		assert.Nil(t, err)
+		return value
+	}
+
+	assert.Equal(t, stack.ByteValue{Value: stringCoderLatin1}, coder("grüße"))
+	assert.Equal(t, stack.ByteValue{Value: stringCoderUTF16}, coder("日本"))
+
+	assert.Nil(t, r.initializeClass(ctx, "java/lang/String"))
+	compact, err := r.loader.GetField(stringCompactStringsField)
+	assert.Nil(t, err)
+	assert.Equal(t, stack.BooleanValue{Value: true}, compact)
+
+	WithCompactStrings(false)(r)
+	assert.Equal(t, stack.ByteValue{Value: stringCoderUTF16}, coder("main"))
+}
+
+// TestStringBuiltByBytecode reads a String whose fields were set with putfield, which stores the
+// coder and, like bastore, the bytes as IntValues.
+func TestStringBuiltByBytecode(t *testing.T) {
+	builder := newTestClass("Builder", "java/lang/Object")
+	str := builder.classRef("java/lang/String")
+	value := builder.ref("java/lang/String", "value", "[B")
+	coder := builder.ref("java/lang/String", "coder", "B")
+	// String s = new String(); s.value = bytes; s.coder = 0; return s;
+	code := append([]byte{NewOp}, u2(str)...)
+	code = append(append(code, DupOp, Aload0, PutField), u2(value)...)
+	code = append(append(code, DupOp, IConst0, PutField), u2(coder)...)
+	builder.method(class.AccStatic, "build", "([B)Ljava/lang/String;", append(code, AReturn)...)
+
+	r, ctx := newTestRunner(t, stringClass(), builder.build())
+
+	var bytes []stack.Value
+	for _, b := range []byte("hello") {
+		bytes = append(bytes, stack.IntValue{Value: int32(b)})
+	}
+
+	array, err := r.heap.AllocateArray(ctx, "[B", bytes)
+	assert.Nil(t, err)
+
+	ref, err := invokeTestMethod(t, ctx, r, "Builder", "build", "([B)Ljava/lang/String;", stack.ReferenceValue{Value: array})
+	assert.Nil(t, err)
+
+	s, err := r.goString(referenceOf(ref))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", s)
+}
+
+func TestStringIntern(t *testing.T) {
+	main := newTestClass("Main", "java/lang/Object")
+	hello := main.add(class.StringInfo{StringIndex: main.utf8("hello")})
+	main.method(class.AccStatic, "hello", "()Ljava/lang/String;", append([]byte{LdcWide}, append(u2(hello), AReturn)...)...)
+
+	r, ctx := newTestRunner(t, stringClass(), main.build())
+
+	first, err := invokeTestMethod(t, ctx, r, "Main", "hello", "()Ljava/lang/String;")
+	assert.Nil(t, err)
+	second, err := invokeTestMethod(t, ctx, r, "Main", "hello", "()Ljava/lang/String;")
+	assert.Nil(t, err)
+	assert.Equal(t, first, second)
+
+	other, err := r.newString(ctx, "hello")
+	assert.Nil(t, err)
+	assert.NotEqual(t, referenceOf(first), other)
+
+	interned, err := stringIntern(ctx, r, []stack.Value{stack.ReferenceValue{Value: other}})
+	assert.Nil(t, err)
+	assert.Equal(t, first, interned)
+
+	fresh, err := r.newString(ctx, "fresh")
+	assert.Nil(t, err)
+	interned, err = stringIntern(ctx, r, []stack.Value{stack.ReferenceValue{Value: fresh}})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.ReferenceValue{Value: fresh}, interned)
+
+	ref, err := r.internString(ctx, "fresh")
+	assert.Nil(t, err)
+	assert.Equal(t, fresh, ref)
+}
+
+func TestStringLiteralModifiedUTF8(t *testing.T) {
+	content, err := class.DecodeModifiedUTF8([]byte{'a', 0xc0, 0x80, 0xed, 0xa0, 0xbd, 0xed, 0xb8, 0x80})
+	assert.Nil(t, err)
+
+	main := newTestClass("Main", "java/lang/Object")
+	literal := main.add(class.StringInfo{StringIndex: main.utf8(content)})
+	main.method(class.AccStatic, "literal", "()Ljava/lang/String;", append([]byte{LdcWide}, append(u2(literal), AReturn)...)...)
+
+	r, ctx := newTestRunner(t, stringClass(), main.build())
+
+	value, err := invokeTestMethod(t, ctx, r, "Main", "literal", "()Ljava/lang/String;")
+	assert.Nil(t, err)
+
+	str, err := r.heap.GetObject(referenceOf(value))
+	assert.Nil(t, err)
+	bytes, err := str.GetFieldValue(stringValueField)
+	assert.Nil(t, err)
+	array, err := r.heap.GetArray(referenceOf(bytes))
+	assert.Nil(t, err)
+
+	// 'a', NUL and the surrogate pair of U+1F600 as big endian UTF16
+	expected := []uint8{0x00, 'a', 0x00, 0x00, 0xd8, 0x3d, 0xde, 0x00}
+	assert.Len(t, array.items, len(expected))
+	for i, b := range expected {
+		assert.Equal(t, stack.ByteValue{Value: b}, array.items[i])
+	}
+}
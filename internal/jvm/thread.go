@@ -0,0 +1,379 @@
+package jvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+	"github.com/m4tthewde/swell/internal/logger"
+)
+
+const normPriority = 5
+
+// The thread states are JVMTI thread state flags, see jdk.internal.misc.VM.toThreadState.
+const (
+	threadStatusAlive = 0x1
+	// threadStatusTerminated is JVMTI_THREAD_STATE_TERMINATED.
+	threadStatusTerminated = 0x2
+	// threadStatusRunnable is JVMTI_THREAD_STATE_ALIVE | JVMTI_THREAD_STATE_RUNNABLE.
+	threadStatusRunnable = 0x5
+)
+
+// threadState is what a thread is doing, as shown by thread dumps and Thread.getState.
+type threadState int
+
+const (
+	threadRunnable threadState = iota
+	threadBlocked
+	threadWaiting
+	threadTimedWaiting
+	threadSleeping
+)
+
+// status returns the JVMTI thread state flags for Thread.threadStatus.
+func (s threadState) status() int32 {
+	switch s {
+	case threadBlocked:
+		// ALIVE | BLOCKED_ON_MONITOR_ENTER
+		return 0x401
+	case threadWaiting:
+		// ALIVE | WAITING | WAITING_INDEFINITELY | IN_OBJECT_WAIT
+		return 0x191
+	case threadTimedWaiting:
+		// ALIVE | WAITING | WAITING_WITH_TIMEOUT | IN_OBJECT_WAIT
+		return 0x1a1
+	case threadSleeping:
+		// ALIVE | WAITING | WAITING_WITH_TIMEOUT | SLEEPING
+		return 0xe1
+	default:
+		return threadStatusRunnable
+	}
+}
+
+func (s threadState) String() string {
+	switch s {
+	case threadBlocked:
+		return "BLOCKED (on object monitor)"
+	case threadWaiting:
+		return "WAITING (on object monitor)"
+	case threadTimedWaiting:
+		return "TIMED_WAITING (on object monitor)"
+	case threadSleeping:
+		return "TIMED_WAITING (sleeping)"
+	default:
+		return "RUNNABLE"
+	}
+}
+
+// setState records what the thread is doing, in the Thread object as well.
+func (r *Runner) setState(state threadState) {
+	r.state = state
+	if r.thread != stack.Null {
+		r.setThreadField(r.thread, threadStatusField, holderThreadStatusField, stack.IntValue{Value: state.status()})
+	}
+}
+
+// firstThreadID is the id given to the first thread started from Java, 1 belongs to the main thread.
+const firstThreadID = 2
+
+// The thread fields moved into Thread.FieldHolder in JDK 19, both layouts are supported.
+var (
+	threadNameField         = loader.FieldKey{Class: "java/lang/Thread", Name: "name", Descriptor: "Ljava/lang/String;"}
+	threadTidField          = loader.FieldKey{Class: "java/lang/Thread", Name: "tid", Descriptor: "J"}
+	threadHolderField       = loader.FieldKey{Class: "java/lang/Thread", Name: "holder", Descriptor: "Ljava/lang/Thread$FieldHolder;"}
+	threadPriorityField     = loader.FieldKey{Class: "java/lang/Thread", Name: "priority", Descriptor: "I"}
+	threadDaemonField       = loader.FieldKey{Class: "java/lang/Thread", Name: "daemon", Descriptor: "Z"}
+	threadStatusField       = loader.FieldKey{Class: "java/lang/Thread", Name: "threadStatus", Descriptor: "I"}
+	threadEetopField        = loader.FieldKey{Class: "java/lang/Thread", Name: "eetop", Descriptor: "J"}
+	holderPriorityField     = loader.FieldKey{Class: "java/lang/Thread$FieldHolder", Name: "priority", Descriptor: "I"}
+	holderDaemonField       = loader.FieldKey{Class: "java/lang/Thread$FieldHolder", Name: "daemon", Descriptor: "Z"}
+	holderThreadStatusField = loader.FieldKey{Class: "java/lang/Thread$FieldHolder", Name: "threadStatus", Descriptor: "I"}
+)
+
+// currentThread returns the java.lang.Thread object of the runner, creating it on first use.
+// Like HotSpot does for the main thread, the object is set up without running a constructor.
+func (r *Runner) currentThread(ctx context.Context) (stack.Reference, error) {
+	if r.thread != stack.Null {
+		return r.thread, nil
+	}
+
+	ref, err := r.newThreadObject(ctx, "main", 1)
+	if err != nil {
+		return stack.Null, err
+	}
+
+	r.thread = ref
+	return ref, nil
+}
+
+func (r *Runner) newThreadObject(ctx context.Context, name string, tid int64) (stack.Reference, error) {
+	err := r.initializeClass(ctx, "java/lang/Thread")
+	if err != nil {
+		return stack.Null, err
+	}
+
+	layout, err := r.loader.Layout(ctx, "java/lang/Thread")
+	if err != nil {
+		return stack.Null, err
+	}
+
+	ref, err := r.heap.AllocateObject(ctx, layout)
+	if err != nil {
+		return stack.Null, err
+	}
+
+	thread, err := r.heap.GetObject(ref)
+	if err != nil {
+		return stack.Null, err
+	}
+
+	nameRef, err := r.newString(ctx, name)
+	if err != nil {
+		return stack.Null, err
+	}
+
+	setFieldIfPresent(thread, threadNameField, stack.ReferenceValue{Value: nameRef})
+	setFieldIfPresent(thread, threadTidField, stack.LongValue{Value: uint64(tid)})
+	setFieldIfPresent(thread, threadPriorityField, stack.IntValue{Value: normPriority})
+	setFieldIfPresent(thread, threadDaemonField, stack.BooleanValue{Value: false})
+	setFieldIfPresent(thread, threadStatusField, stack.IntValue{Value: threadStatusRunnable})
+
+	if _, ok := layout.Index(threadHolderField); ok {
+		holderLayout, err := r.loader.Layout(ctx, "java/lang/Thread$FieldHolder")
+		if err != nil {
+			return stack.Null, err
+		}
+
+		holderRef, err := r.heap.AllocateObject(ctx, holderLayout)
+		if err != nil {
+			return stack.Null, err
+		}
+
+		holder, err := r.heap.GetObject(holderRef)
+		if err != nil {
+			return stack.Null, err
+		}
+
+		setFieldIfPresent(holder, holderPriorityField, stack.IntValue{Value: normPriority})
+		setFieldIfPresent(holder, holderDaemonField, stack.BooleanValue{Value: false})
+		setFieldIfPresent(holder, holderThreadStatusField, stack.IntValue{Value: threadStatusRunnable})
+		setFieldIfPresent(thread, threadHolderField, stack.ReferenceValue{Value: holderRef})
+	}
+
+	return ref, nil
+}
+
+// setFieldIfPresent sets a field that only exists in some JDK versions.
+func setFieldIfPresent(object *Object, key loader.FieldKey, value stack.Value) {
+	if _, ok := object.layout.Index(key); ok {
+		_ = object.SetFieldValue(key, value)
+	}
+}
+
+// threadField reads a field of a thread object, looking in its Thread.FieldHolder if the field moved there.
+// ok is false if the field exists in neither.
+func (r *Runner) threadField(ref stack.Reference, key loader.FieldKey, holderKey loader.FieldKey) (stack.Value, bool) {
+	object, err := r.heap.GetObject(ref)
+	if err != nil {
+		return nil, false
+	}
+
+	if value, err := object.GetFieldValue(key); err == nil {
+		return value, true
+	}
+
+	holder, err := object.GetFieldValue(threadHolderField)
+	if err != nil {
+		return nil, false
+	}
+
+	holderObject, err := r.heap.GetObject(referenceOf(holder))
+	if err != nil {
+		return nil, false
+	}
+
+	value, err := holderObject.GetFieldValue(holderKey)
+	return value, err == nil
+}
+
+// setThreadField sets a field of a thread object, or of its Thread.FieldHolder if the field moved there.
+func (r *Runner) setThreadField(ref stack.Reference, key loader.FieldKey, holderKey loader.FieldKey, value stack.Value) {
+	object, err := r.heap.GetObject(ref)
+	if err != nil {
+		return
+	}
+
+	if _, ok := object.layout.Index(key); ok {
+		_ = object.SetFieldValue(key, value)
+		return
+	}
+
+	holder, err := object.GetFieldValue(threadHolderField)
+	if err != nil {
+		return
+	}
+
+	if holderObject, err := r.heap.GetObject(referenceOf(holder)); err == nil {
+		setFieldIfPresent(holderObject, holderKey, value)
+	}
+}
+
+// threadName returns the name of the thread, for messages.
+func (r *Runner) threadName() string {
+	if r.thread == stack.Null {
+		return "main"
+	}
+
+	value, ok := r.threadField(r.thread, threadNameField, threadNameField)
+	if !ok {
+		return ""
+	}
+
+	name, err := r.goString(referenceOf(value))
+	if err != nil {
+		return ""
+	}
+
+	return name
+}
+
+// startThread starts the goroutine of a new Java thread. Like HotSpot, the VM only waits for
+// non-daemon threads before it exits.
+func (r *Runner) startThread(ctx context.Context, ref stack.Reference) {
+	daemon := false
+	if value, ok := r.threadField(ref, threadDaemonField, holderDaemonField); ok {
+		daemon = boolOf(value)
+	}
+
+	r.setThreadField(ref, threadStatusField, holderThreadStatusField, stack.IntValue{Value: threadStatusRunnable})
+	r.setThreadField(ref, threadEetopField, threadEetopField, stack.LongValue{Value: uint64(ref)})
+
+	t := r.newThread(ref)
+	if !daemon {
+		r.nonDaemon.Add(1)
+	}
+
+	go t.runThread(ctx, daemon)
+}
+
+// runThread is the body of the goroutine of a started thread: it calls Thread.run and terminates the thread.
+func (r *Runner) runThread(ctx context.Context, daemon bool) {
+	r.acquire()
+	defer func() {
+		r.removeThread(r)
+		r.release()
+
+		if !daemon {
+			r.nonDaemon.Done()
+		}
+	}()
+
+	var err error
+	if r.jdwp != nil {
+		err = r.jdwp.threadEvent(ctx, r, jdwpEventThreadStart)
+	}
+
+	if err == nil {
+		err = r.callVirtual(ctx, r.thread, "run", "()V")
+	}
+
+	if err != nil && !r.stopping(ctx, err) {
+		r.uncaughtException(ctx, err)
+	}
+
+	r.exitThread(ctx)
+
+	if r.jdwp != nil {
+		r.jdwp.threadEvent(ctx, r, jdwpEventThreadDeath)
+	}
+}
+
+// uncaughtException hands an exception that terminated the thread to Thread.dispatchUncaughtException,
+// which calls the uncaught exception handler. Without it the exception is printed like the default handler does.
+func (r *Runner) uncaughtException(ctx context.Context, err error) {
+	log := logger.FromContext(ctx)
+
+	var throwable *ThrowableError
+	if errors.As(err, &throwable) && throwable.Ref != stack.Null {
+		dispatchErr := r.callVirtual(ctx, r.thread, "dispatchUncaughtException", "(Ljava/lang/Throwable;)V",
+			stack.ReferenceValue{Value: throwable.Ref})
+		if dispatchErr == nil {
+			return
+		}
+
+		if !errors.Is(dispatchErr, loader.ErrNoSuchMethod) {
+			// exceptions thrown by the handler are ignored, like in HotSpot
+			log.Warnw("uncaught exception handler failed", "thread", r.threadName(), "error", dispatchErr)
+			return
+		}
+	}
+
+	w, writerErr := r.files.Writer(stderrFd)
+	if writerErr != nil {
+		log.Errorw("uncaught exception", "thread", r.threadName(), "error", err)
+		return
+	}
+
+	fmt.Fprintf(w, "Exception in thread \"%s\" %v\n", r.threadName(), err)
+}
+
+// exitThread lets Thread.exit clean up and marks the thread as terminated.
+func (r *Runner) exitThread(ctx context.Context) {
+	err := r.callVirtual(ctx, r.thread, "exit", "()V")
+	if err != nil && !errors.Is(err, loader.ErrNoSuchMethod) {
+		logger.FromContext(ctx).Warnw("thread exit failed", "thread", r.threadName(), "error", err)
+	}
+
+	r.setThreadField(r.thread, threadStatusField, holderThreadStatusField, stack.IntValue{Value: threadStatusTerminated})
+	r.setThreadField(r.thread, threadEetopField, threadEetopField, stack.LongValue{Value: 0})
+
+	// Thread.join waits on the thread object until it is no longer alive
+	err = r.monitorEnter(ctx, r.thread)
+	if err == nil {
+		err = r.monitorNotify(ctx, r.thread, true)
+		err = errors.Join(err, r.monitorExit(ctx, r.thread))
+	}
+
+	if err != nil {
+		logger.FromContext(ctx).Warnw("notifying joining threads failed", "thread", r.threadName(), "error", err)
+	}
+}
+
+// sleep blocks the thread for d unless it is interrupted, which throws an InterruptedException.
+func (r *Runner) sleep(ctx context.Context, d time.Duration) error {
+	if r.takeInterrupt() {
+		return r.newThrowable(ctx, "java/lang/InterruptedException", "sleep interrupted", nil)
+	}
+
+	r.setState(threadSleeping)
+	defer r.setState(threadRunnable)
+
+	r.blocking(func() {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-r.wakeup:
+		case <-ctx.Done():
+		}
+	})
+
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
+	if r.takeInterrupt() {
+		return r.newThrowable(ctx, "java/lang/InterruptedException", "sleep interrupted", nil)
+	}
+
+	return nil
+}
+
+// waitForThreads blocks until all non-daemon threads have terminated, which is when the VM exits.
+func (r *Runner) waitForThreads() {
+	r.blocking(r.nonDaemon.Wait)
+}
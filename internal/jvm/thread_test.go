@@ -0,0 +1,171 @@
+package jvm
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func threadClasses() []*class.Class {
+	thread := newTestClass("java/lang/Thread", "java/lang/Object").
+		field(0, "name", "Ljava/lang/String;").
+		field(0, "tid", "J").
+		field(0, "priority", "I").
+		field(0, "daemon", "Z").
+		field(0, "threadStatus", "I").
+		field(0, "eetop", "J").
+		field(0, "interrupted", "Z").
+		method(class.AccStatic|class.AccNative, "currentThread", "()Ljava/lang/Thread;").
+		method(class.AccNative, "start0", "()V").
+		method(class.AccNative, "isAlive", "()Z")
+
+	// Worker remembers the thread running it in a static field
+	worker := newTestClass("Worker", "java/lang/Thread").
+		field(class.AccStatic, "seen", "Ljava/lang/Thread;")
+	currentThread := worker.ref("java/lang/Thread", "currentThread", "()Ljava/lang/Thread;")
+	seen := worker.ref("Worker", "seen", "Ljava/lang/Thread;")
+	worker.method(class.AccPublic, "run", "()V",
+		append(append(append([]byte{InvokeStaticOp}, u2(currentThread)...), PutStatic), append(u2(seen), RetOp)...)...)
+
+	failing := newTestClass("Failing", "java/lang/Thread")
+	failing.method(class.AccPublic, "run", "()V", throwCode(failing, "java/lang/RuntimeException")...)
+
+	blocked := newTestClass("Blocked", "java/lang/Thread").
+		method(class.AccPublic|class.AccNative, "run", "()V")
+
+	classes := append(throwableClasses(), thread.build(), worker.build(), failing.build(), blocked.build())
+	for _, names := range [][2]string{
+		{"java/lang/IllegalMonitorStateException", "java/lang/RuntimeException"},
+		{"java/lang/InterruptedException", "java/lang/Exception"},
+	} {
+		c := newTestClass(names[0], names[1]).
+			method(class.AccPublic, "<init>", "()V", RetOp)
+		classes = append(classes, c.build())
+	}
+
+	return classes
+}
+
+func newThreadObject(t *testing.T, ctx context.Context, r *Runner, className string, name string) stack.Reference {
+	layout, err := r.loader.Layout(ctx, className)
+	assert.Nil(t, err)
+	ref, err := r.heap.AllocateObject(ctx, layout)
+	assert.Nil(t, err)
+
+	nameRef, err := r.newString(ctx, name)
+	assert.Nil(t, err)
+	assert.Nil(t, r.heap.SetField(ref, threadNameField, stack.ReferenceValue{Value: nameRef}))
+
+	// keep the thread reachable while the test holds it
+	r.heap.AddHandle(ref)
+	return ref
+}
+
+func TestThreadStart(t *testing.T) {
+	r, ctx := newTestRunner(t, threadClasses()...)
+
+	worker := newThreadObject(t, ctx, r, "Worker", "worker")
+	alive, err := threadIsAlive(ctx, r, []stack.Value{stack.ReferenceValue{Value: worker}})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.BooleanValue{Value: false}, alive)
+
+	_, err = threadStart0(ctx, r, []stack.Value{stack.ReferenceValue{Value: worker}})
+	assert.Nil(t, err)
+	r.waitForThreads()
+
+	seen, err := r.loader.GetField(loader.FieldKey{Class: "Worker", Name: "seen", Descriptor: "Ljava/lang/Thread;"})
+	assert.Nil(t, err)
+	assert.Equal(t, worker, referenceOf(seen))
+
+	status, ok := r.threadField(worker, threadStatusField, holderThreadStatusField)
+	assert.True(t, ok)
+	assert.Equal(t, stack.IntValue{Value: threadStatusTerminated}, status)
+	assert.Len(t, r.threads, 1)
+
+	alive, err = threadIsAlive(ctx, r, []stack.Value{stack.ReferenceValue{Value: worker}})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.BooleanValue{Value: false}, alive)
+}
+
+func TestThreadUncaughtException(t *testing.T) {
+	r, ctx := newTestRunner(t, threadClasses()...)
+	var stderr bytes.Buffer
+	WithStderr(&stderr)(r)
+
+	failing := newThreadObject(t, ctx, r, "Failing", "failing")
+	_, err := threadStart0(ctx, r, []stack.Value{stack.ReferenceValue{Value: failing}})
+	assert.Nil(t, err)
+	r.waitForThreads()
+
+	assert.Equal(t, "Exception in thread \"failing\" java.lang.RuntimeException\n\tFailing.run()\n", stderr.String())
+}
+
+// TestDaemonFieldSetByBytecode starts a thread whose daemon field putfield stored as an int.
+func TestDaemonFieldSetByBytecode(t *testing.T) {
+	starter := newTestClass("Starter", "java/lang/Object")
+	daemonField := starter.ref("java/lang/Thread", "daemon", "Z")
+	start0 := starter.ref("java/lang/Thread", "start0", "()V")
+	// thread.daemon = true; thread.start0();
+	code := append([]byte{Aload0, IConst1, PutField}, u2(daemonField)...)
+	code = append(append(append(code, Aload0, InvokeVirtual), u2(start0)...), RetOp)
+	starter.method(class.AccStatic, "start", "(Ljava/lang/Thread;)V", code...)
+
+	r, ctx := newTestRunner(t, append(threadClasses(), starter.build())...)
+
+	unblock := make(chan struct{})
+	done := make(chan struct{})
+	r.natives.Register("Blocked", "run", "()V", func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+		r.blocking(func() {
+			<-unblock
+		})
+
+		close(done)
+		return nil, nil
+	})
+
+	daemon := newThreadObject(t, ctx, r, "Blocked", "daemon")
+	_, err := invokeTestMethod(t, ctx, r, "Starter", "start", "(Ljava/lang/Thread;)V", stack.ReferenceValue{Value: daemon})
+	assert.Nil(t, err)
+	r.waitForThreads()
+
+	close(unblock)
+	r.blocking(func() {
+		<-done
+	})
+}
+
+func TestDaemonThreadDoesNotBlockExit(t *testing.T) {
+	r, ctx := newTestRunner(t, threadClasses()...)
+
+	unblock := make(chan struct{})
+	done := make(chan struct{})
+	r.natives.Register("Blocked", "run", "()V", func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+		r.blocking(func() {
+			<-unblock
+		})
+
+		close(done)
+		return nil, nil
+	})
+
+	daemon := newThreadObject(t, ctx, r, "Blocked", "daemon")
+	assert.Nil(t, r.heap.SetField(daemon, threadDaemonField, stack.BooleanValue{Value: true}))
+
+	_, err := threadStart0(ctx, r, []stack.Value{stack.ReferenceValue{Value: daemon}})
+	assert.Nil(t, err)
+	r.waitForThreads()
+
+	alive, err := threadIsAlive(ctx, r, []stack.Value{stack.ReferenceValue{Value: daemon}})
+	assert.Nil(t, err)
+	assert.Equal(t, stack.BooleanValue{Value: true}, alive)
+
+	close(unblock)
+	r.blocking(func() {
+		<-done
+	})
+}
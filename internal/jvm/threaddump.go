@@ -0,0 +1,214 @@
+package jvm
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+)
+
+// DumpThreads writes the Java stack of every thread and any monitor deadlocks to w, like a
+// HotSpot thread dump on SIGQUIT. It waits for the running thread to reach a safepoint and
+// takes the interpreter lock, so it must not be called from a Java thread.
+func (r *Runner) DumpThreads(ctx context.Context, w io.Writer) error {
+	r.lockExternal()
+	defer r.lock.Unlock()
+
+	return r.writeThreadDump(r.withLogger(ctx), w)
+}
+
+func (r *Runner) writeThreadDump(ctx context.Context, w io.Writer) error {
+	b := bufio.NewWriter(w)
+
+	fmt.Fprintf(b, "Full thread dump swell:\n\n")
+	for _, t := range r.threads {
+		t.writeThreadHeader(b)
+		fmt.Fprintf(b, "   java.lang.Thread.State: %s\n", t.state)
+		t.writeThreadStack(ctx, b)
+		fmt.Fprintln(b)
+	}
+
+	r.writeDeadlocks(ctx, b)
+
+	return b.Flush()
+}
+
+func (r *Runner) writeThreadHeader(b *bufio.Writer) {
+	tid, priority, daemon := int64(1), int64(5), false
+	if r.thread != stack.Null {
+		if value, ok := r.threadField(r.thread, threadTidField, threadTidField); ok {
+			tid, _ = intOf(value)
+		}
+
+		if value, ok := r.threadField(r.thread, threadPriorityField, holderPriorityField); ok {
+			priority, _ = intOf(value)
+		}
+
+		if value, ok := r.threadField(r.thread, threadDaemonField, holderDaemonField); ok {
+			daemon = boolOf(value)
+		}
+	}
+
+	fmt.Fprintf(b, "%q #%d ", r.threadName(), tid)
+	if daemon {
+		fmt.Fprint(b, "daemon ")
+	}
+
+	fmt.Fprintf(b, "prio=%d\n", priority)
+}
+
+// writeThreadStack writes the frames of the thread innermost first, each followed by the
+// monitors it is blocked or waiting on and the monitors it holds.
+func (r *Runner) writeThreadStack(ctx context.Context, b *bufio.Writer) {
+	trace := r.stack.Trace()
+	if len(trace) == 0 {
+		r.writeMonitors(b, 0, 0)
+		return
+	}
+
+	for i, frame := range trace {
+		fmt.Fprintf(b, "\tat %s.%s(%s)\n", dotted(frame.ClassName), frame.MethodName, r.frameLocation(ctx, frame))
+		r.writeMonitors(b, len(trace)-1-i, len(trace))
+	}
+}
+
+// writeMonitors writes the monitors of the frame at index, the innermost frame also shows the
+// monitor the thread waits for. Monitors entered by the VM outside of any frame are shown with
+// the nearest frame.
+func (r *Runner) writeMonitors(b *bufio.Writer, index int, depth int) {
+	if index >= depth-1 {
+		if r.blockedOn != stack.Null {
+			fmt.Fprintf(b, "\t- waiting to lock %s\n", r.describeMonitor(r.blockedOn))
+		}
+
+		if r.waitingOn != stack.Null {
+			fmt.Fprintf(b, "\t- waiting on %s\n", r.describeMonitor(r.waitingOn))
+		}
+	}
+
+	for i := len(r.locks) - 1; i >= 0; i-- {
+		lock := r.locks[i]
+		if min(max(lock.frame, 0), max(depth-1, 0)) == index {
+			fmt.Fprintf(b, "\t- locked %s\n", r.describeMonitor(lock.ref))
+		}
+	}
+}
+
+// frameLocation returns the source file and line of a frame, e.g. Main.java:12.
+func (r *Runner) frameLocation(ctx context.Context, frame stack.TraceFrame) string {
+	if frame.Method.IsNative() {
+		return "Native Method"
+	}
+
+	c, err := r.loader.Load(ctx, frame.ClassName)
+	if err != nil {
+		return "Unknown Source"
+	}
+
+	file, ok := c.SourceFile()
+	if !ok {
+		return "Unknown Source"
+	}
+
+	code, err := frame.Method.CodeAttribute()
+	if err != nil {
+		return file
+	}
+
+	line, ok := code.LineNumber(frame.Pc)
+	if !ok {
+		return file
+	}
+
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+func (r *Runner) describeMonitor(ref stack.Reference) string {
+	return fmt.Sprintf("<0x%016x> (a %s)", ref, r.monitorClassName(ref))
+}
+
+func (r *Runner) monitorClassName(ref stack.Reference) string {
+	className, err := r.runtimeClassName(ref)
+	if err != nil {
+		return "unknown"
+	}
+
+	return dotted(className)
+}
+
+// findDeadlocks returns the cycles of threads each blocked on a monitor owned by the next.
+func (r *Runner) findDeadlocks() [][]*Runner {
+	var deadlocks [][]*Runner
+	visited := make(map[*Runner]bool)
+
+	for _, start := range r.threads {
+		if visited[start] {
+			continue
+		}
+
+		position := make(map[*Runner]int)
+		var chain []*Runner
+		for t := start; t != nil; t = t.blockingOwner() {
+			if i, ok := position[t]; ok {
+				deadlocks = append(deadlocks, chain[i:])
+				break
+			}
+
+			if visited[t] {
+				break
+			}
+
+			visited[t] = true
+			position[t] = len(chain)
+			chain = append(chain, t)
+		}
+	}
+
+	return deadlocks
+}
+
+// blockingOwner returns the thread owning the monitor the thread is blocked on.
+func (r *Runner) blockingOwner() *Runner {
+	if r.blockedOn == stack.Null {
+		return nil
+	}
+
+	m, err := r.heap.monitor(r.blockedOn)
+	if err != nil {
+		return nil
+	}
+
+	return m.owner
+}
+
+func (r *Runner) writeDeadlocks(ctx context.Context, b *bufio.Writer) {
+	deadlocks := r.findDeadlocks()
+	for _, cycle := range deadlocks {
+		fmt.Fprintf(b, "Found one Java-level deadlock:\n%s\n", strings.Repeat("=", 29))
+		for _, t := range cycle {
+			m, _ := r.heap.monitor(t.blockedOn)
+			fmt.Fprintf(b, "%q:\n", t.threadName())
+			fmt.Fprintf(b, "  waiting to lock monitor %p (object 0x%016x, a %s),\n", m, t.blockedOn, r.monitorClassName(t.blockedOn))
+			fmt.Fprintf(b, "  which is held by %q\n\n", m.owner.threadName())
+		}
+
+		fmt.Fprintf(b, "Java stack information for the threads listed above:\n%s\n", strings.Repeat("=", 51))
+		for _, t := range cycle {
+			fmt.Fprintf(b, "%q:\n", t.threadName())
+			t.writeThreadStack(ctx, b)
+		}
+
+		fmt.Fprintln(b)
+	}
+
+	switch len(deadlocks) {
+	case 0:
+	case 1:
+		fmt.Fprintf(b, "Found 1 deadlock.\n\n")
+	default:
+		fmt.Fprintf(b, "Found %d deadlocks.\n\n", len(deadlocks))
+	}
+}
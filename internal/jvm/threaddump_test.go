@@ -0,0 +1,146 @@
+package jvm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThreadDumpDeadlock(t *testing.T) {
+	r, ctx := newTestRunner(t, threadClasses()...)
+	first, second := newLock(t, ctx, r), newLock(t, ctx, r)
+	r.heap.AddHandle(first)
+	r.heap.AddHandle(second)
+
+	// each thread takes its own lock, waits until the other did the same and then takes the other lock
+	r.natives.Register("Blocked", "run", "()V", func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+		own, other := first, second
+		if r.threadName() == "T2" {
+			own, other = second, first
+		}
+
+		err := r.monitorEnter(ctx, own)
+		if err != nil {
+			return nil, err
+		}
+
+		for {
+			m, err := r.heap.monitor(other)
+			if err != nil {
+				return nil, err
+			}
+
+			if m.owner != nil {
+				break
+			}
+
+			r.yield()
+		}
+
+		return nil, r.monitorEnter(ctx, other)
+	})
+
+	var threads []stack.Reference
+	for i, name := range []string{"T1", "T2"} {
+		thread := newThreadObject(t, ctx, r, "Blocked", name)
+		// the values the Thread constructor stores with putfield
+		assert.Nil(t, r.heap.SetField(thread, threadTidField, stack.LongValue{Value: uint64(i + 2)}))
+		assert.Nil(t, r.heap.SetField(thread, threadPriorityField, stack.IntValue{Value: normPriority}))
+		assert.Nil(t, r.heap.SetField(thread, threadDaemonField, stack.IntValue{Value: 0}))
+		_, err := threadStart0(ctx, r, []stack.Value{stack.ReferenceValue{Value: thread}})
+		assert.Nil(t, err)
+		threads = append(threads, thread)
+	}
+
+	// both threads are blocked once their status says so, checked with the interpreter lock held
+	deadline := time.Now().Add(time.Second)
+	for blocked := 0; blocked < len(threads) && time.Now().Before(deadline); {
+		assert.Nil(t, r.sleep(ctx, time.Millisecond))
+
+		blocked = 0
+		for _, thread := range threads {
+			status, _ := r.threadField(thread, threadStatusField, holderThreadStatusField)
+			if status == (stack.IntValue{Value: threadBlocked.status()}) {
+				blocked++
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	assert.Nil(t, r.writeThreadDump(ctx, &out))
+	dump := out.String()
+
+	assert.Contains(t, dump, "\"T1\" #2 prio=5\n   java.lang.Thread.State: BLOCKED (on object monitor)\n")
+	assert.Contains(t, dump, "Found one Java-level deadlock:\n=============================\n\"T1\":\n")
+	assert.Contains(t, dump, "  which is held by \"T2\"\n")
+	assert.Contains(t, dump, "  which is held by \"T1\"\n")
+	assert.Contains(t, dump, "\t- waiting to lock <0x"+hexReference(second)+"> (a java.lang.Object)\n\t- locked <0x"+hexReference(first)+"> (a java.lang.Object)\n")
+	assert.Contains(t, dump, "Found 1 deadlock.\n")
+}
+
+func TestThreadDumpWithoutDeadlock(t *testing.T) {
+	r, ctx := newTestRunner(t, threadClasses()...)
+	lock := newLock(t, ctx, r)
+	assert.Nil(t, r.monitorEnter(ctx, lock))
+
+	var out bytes.Buffer
+	assert.Nil(t, r.writeThreadDump(ctx, &out))
+
+	assert.Equal(t, "Full thread dump swell:\n\n\"main\" #1 prio=5\n   java.lang.Thread.State: RUNNABLE\n\t- locked <0x"+hexReference(lock)+"> (a java.lang.Object)\n\n", out.String())
+}
+
+// TestDumpThreadsWhileRunning dumps the threads from another goroutine while the main thread,
+// the only one, spins in a loop until the dump has been written.
+func TestDumpThreadsWhileRunning(t *testing.T) {
+	spin := newTestClass("Spin", "java/lang/Object").
+		method(class.AccStatic|class.AccNative, "done", "()Z")
+	done := spin.ref("Spin", "done", "()Z")
+	// while (!done()) {}
+	spin.method(class.AccStatic, "spin", "()V", append(append([]byte{InvokeStaticOp}, u2(done)...), IfEq, 0xff, 0xfd, RetOp)...)
+
+	r, ctx := newTestRunner(t, spin.build())
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var out bytes.Buffer
+	var dumped atomic.Bool
+	started := make(chan struct{})
+	r.natives.Register("Spin", "done", "()Z", func(ctx context.Context, r *Runner, args []stack.Value) (stack.Value, error) {
+		select {
+		case <-started:
+		default:
+			close(started)
+		}
+
+		return stack.BooleanValue{Value: dumped.Load()}, nil
+	})
+
+	go func() {
+		<-started
+		assert.Nil(t, r.DumpThreads(ctx, &out))
+		dumped.Store(true)
+	}()
+
+	_, err := invokeTestMethod(t, ctx, r, "Spin", "spin", "()V")
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), "\"main\" #1 prio=5\n   java.lang.Thread.State: RUNNABLE\n\tat Spin.spin(Unknown Source)\n")
+}
+
+func TestDumpThreadsIdle(t *testing.T) {
+	r := NewRunner(nil)
+
+	var out bytes.Buffer
+	assert.Nil(t, r.DumpThreads(testContext(t), &out))
+	assert.Equal(t, "Full thread dump swell:\n\n\"main\" #1 prio=5\n   java.lang.Thread.State: RUNNABLE\n\n", out.String())
+}
+
+func hexReference(ref stack.Reference) string {
+	return fmt.Sprintf("%016x", ref)
+}
@@ -0,0 +1,151 @@
+package jvm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/loader"
+	"github.com/m4tthewde/swell/internal/logger"
+)
+
+var throwableMessageField = loader.FieldKey{Class: "java/lang/Throwable", Name: "detailMessage", Descriptor: "Ljava/lang/String;"}
+var throwableCauseField = loader.FieldKey{Class: "java/lang/Throwable", Name: "cause", Descriptor: "Ljava/lang/Throwable;"}
+
+// ThrowableError is a Java exception or error thrown by the program or raised by the VM.
+type ThrowableError struct {
+	ClassName string
+	Message   string
+	// Ref is the exception object, Null if the VM could not create one.
+	// Only exceptions with an object can be caught by exception handlers.
+	Ref   stack.Reference
+	Cause *ThrowableError
+	// trace holds the methods the exception has propagated through so far
+	trace []string
+}
+
+func newThrowableError(className string, message string) *ThrowableError {
+	return &ThrowableError{ClassName: className, Message: message}
+}
+
+// dotted returns the binary name of a class as Class.getName reports it and stack traces show it,
+// e.g. java.lang.String, [Ljava.lang.String; or int.
+func dotted(className string) string {
+	return strings.ReplaceAll(className, "/", ".")
+}
+
+func (e *ThrowableError) Error() string {
+	var b strings.Builder
+
+	b.WriteString(dotted(e.ClassName))
+	if e.Message != "" {
+		b.WriteString(": ")
+		b.WriteString(e.Message)
+	}
+
+	for _, frame := range e.trace {
+		b.WriteString("\n\t")
+		b.WriteString(frame)
+	}
+
+	if e.Cause != nil {
+		b.WriteString("\nCaused by: ")
+		b.WriteString(e.Cause.Error())
+	}
+
+	return b.String()
+}
+
+// StackTrace returns the methods the exception has propagated through, innermost first.
+func (e *ThrowableError) StackTrace() []string {
+	return slices.Clone(e.trace)
+}
+
+func (e *ThrowableError) Unwrap() error {
+	if e.Cause == nil {
+		return nil
+	}
+
+	return e.Cause
+}
+
+// newThrowable creates an exception object of className, so that the exception can be caught.
+// Like HotSpot, the constructor is not run, the message and cause are set directly.
+// If the object can't be created, the error is still returned, but without an object.
+func (r *Runner) newThrowable(ctx context.Context, className string, message string, cause *ThrowableError) *ThrowableError {
+	log := logger.FromContext(ctx)
+
+	throwable := &ThrowableError{ClassName: className, Message: message, Cause: cause}
+
+	// the cause is no longer reachable from the frame that threw it
+	if cause != nil && cause.Ref != stack.Null {
+		r.heap.AddHandle(cause.Ref)
+	}
+
+	err := r.initializeClass(ctx, className)
+	if err != nil {
+		log.Warnw("failed to initialize exception class", "className", className, "error", err)
+		return throwable
+	}
+
+	layout, err := r.loader.Layout(ctx, className)
+	if err != nil {
+		log.Warnw("failed to create exception", "className", className, "error", err)
+		return throwable
+	}
+
+	ref, err := r.heap.AllocateObject(ctx, layout)
+	if err != nil {
+		log.Warnw("failed to create exception", "className", className, "error", err)
+		return throwable
+	}
+
+	throwable.Ref = ref
+
+	if message != "" {
+		messageRef, err := r.newString(ctx, message)
+		if err == nil {
+			err = r.heap.SetField(ref, throwableMessageField, stack.ReferenceValue{Value: messageRef})
+		}
+
+		if err != nil {
+			log.Warnw("failed to set exception message", "className", className, "error", err)
+		}
+	}
+
+	if cause != nil && cause.Ref != stack.Null {
+		err = r.heap.SetField(ref, throwableCauseField, stack.ReferenceValue{Value: cause.Ref})
+		if err != nil {
+			log.Warnw("failed to set exception cause", "className", className, "error", err)
+		}
+	}
+
+	return throwable
+}
+
+func athrow(ctx context.Context, r *Runner) error {
+	r.pc += 1
+
+	operands, err := r.stack.PopOperands(1)
+	if err != nil {
+		return err
+	}
+
+	objectRef, ok := operands[0].(stack.ReferenceValue)
+	if !ok {
+		return fmt.Errorf("objectref has to be a reference, is %s", operands[0])
+	}
+
+	if objectRef.IsNull() {
+		return r.newThrowable(ctx, "java/lang/NullPointerException", "", nil)
+	}
+
+	object, err := r.heap.GetObject(objectRef.Value)
+	if err != nil {
+		return err
+	}
+
+	return &ThrowableError{ClassName: object.ClassName(), Ref: objectRef.Value}
+}
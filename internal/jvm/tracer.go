@@ -0,0 +1,206 @@
+package jvm
+
+import (
+	"strings"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+)
+
+// Tracer is called by the Runner as the program executes, see WithTracer. The calls are
+// serialized by the interpreter lock. Class names are binary names like java.lang.String and
+// values are rendered like the debugger prints them, e.g. 42 or 0x2a java.lang.String "hello".
+type Tracer interface {
+	MethodEntry(event MethodEvent)
+	MethodExit(event MethodEvent)
+	Instruction(event InstructionEvent)
+	ClassLoad(event ClassEvent)
+	ClassInit(event ClassEvent)
+	Allocation(event AllocationEvent)
+	Throw(event ExceptionEvent)
+	Catch(event ExceptionEvent)
+}
+
+// MethodEvent is the entry or exit of a bytecode or native method.
+// Depth is the number of frames of the thread, including the one of the method.
+type MethodEvent struct {
+	Thread     string   `json:"thread"`
+	Depth      int      `json:"depth"`
+	Class      string   `json:"class"`
+	Method     string   `json:"method"`
+	Descriptor string   `json:"descriptor"`
+	Args       []string `json:"args,omitempty"`
+	// Return is the value returned, empty for void methods and methods completing abruptly
+	Return string `json:"return,omitempty"`
+	// Exception is the exception or error a method completed abruptly with
+	Exception string `json:"exception,omitempty"`
+}
+
+// InstructionEvent is the execution of the instruction at Pc.
+type InstructionEvent struct {
+	Thread      string `json:"thread"`
+	Depth       int    `json:"depth"`
+	Class       string `json:"class"`
+	Method      string `json:"method"`
+	Pc          int    `json:"pc"`
+	Instruction string `json:"instruction"`
+}
+
+// ClassEvent is the loading or the start of the initialization of a class.
+type ClassEvent struct {
+	Thread string `json:"thread"`
+	Depth  int    `json:"depth"`
+	Class  string `json:"class"`
+}
+
+// AllocationEvent is the allocation of an object or array, Type is e.g. java.lang.String or int[3].
+type AllocationEvent struct {
+	Thread    string `json:"thread"`
+	Depth     int    `json:"depth"`
+	Reference string `json:"reference"`
+	Type      string `json:"type"`
+}
+
+// ExceptionEvent is an exception thrown by the instruction at Pc or caught by the handler at Pc.
+// Pc is -1 for exceptions thrown by native methods.
+type ExceptionEvent struct {
+	Thread    string `json:"thread"`
+	Depth     int    `json:"depth"`
+	Class     string `json:"class"`
+	Method    string `json:"method"`
+	Pc        int    `json:"pc"`
+	Exception string `json:"exception"`
+}
+
+// methodEvent describes the method of the active frame.
+func (r *Runner) methodEvent(c *class.Class, method *class.Method) MethodEvent {
+	name, _ := c.ConstantPool.GetUtf8(method.NameIndex)
+	descriptor, _ := c.ConstantPool.GetUtf8(method.DescriptorIndex)
+
+	return MethodEvent{
+		Thread:     r.threadName(),
+		Depth:      r.stack.Depth(),
+		Class:      dotted(c.Name),
+		Method:     name,
+		Descriptor: descriptor,
+	}
+}
+
+// traceMethodEntry is called once the frame of the method has been pushed.
+func (r *Runner) traceMethodEntry(c *class.Class, method *class.Method, args []stack.Value) {
+	event := r.methodEvent(c, method)
+	for _, arg := range args {
+		event.Args = append(event.Args, r.describeValue(arg))
+	}
+
+	r.tracer.MethodEntry(event)
+}
+
+// traceMethodExit is called before the frame of the method is popped, result is its return value or nil.
+func (r *Runner) traceMethodExit(c *class.Class, method *class.Method, result stack.Value, err error) {
+	event := r.methodEvent(c, method)
+
+	switch {
+	case err != nil:
+		event.Exception = exceptionName(err)
+	case result != nil:
+		event.Return = r.describeValue(result)
+	}
+
+	r.tracer.MethodExit(event)
+}
+
+// returnValue returns the value a bytecode method has returned, which its return instruction
+// pushed onto the operand stack of the invoker, or nil for void methods.
+func (r *Runner) returnValue(c *class.Class, method *class.Method) stack.Value {
+	descriptor, err := c.ConstantPool.GetUtf8(method.DescriptorIndex)
+	if err != nil || strings.HasSuffix(descriptor, ")V") {
+		return nil
+	}
+
+	value, err := r.stack.InvokerOperand()
+	if err != nil {
+		return nil
+	}
+
+	return value
+}
+
+// exceptionName returns the class of an exception, or the first line of other errors.
+func exceptionName(err error) string {
+	if throwable, ok := err.(*ThrowableError); ok {
+		return dotted(throwable.ClassName)
+	}
+
+	line, _, _ := strings.Cut(err.Error(), "\n")
+	return line
+}
+
+// traceInstruction is called before the instruction at r.pc is executed.
+func (r *Runner) traceInstruction(code []byte) {
+	pool, err := r.stack.CurrentConstantPool()
+	if err != nil {
+		return
+	}
+
+	method, err := r.stack.CurrentMethod()
+	if err != nil {
+		return
+	}
+
+	name, _ := pool.GetUtf8(method.NameIndex)
+	text, _, _ := decodeInstruction(pool, code, r.pc)
+
+	r.tracer.Instruction(InstructionEvent{
+		Thread:      r.threadName(),
+		Depth:       r.stack.Depth(),
+		Class:       dotted(r.stack.CurrentClassName()),
+		Method:      name,
+		Pc:          r.pc,
+		Instruction: text,
+	})
+}
+
+func (r *Runner) classEvent(className string) ClassEvent {
+	return ClassEvent{Thread: r.threadName(), Depth: r.stack.Depth(), Class: dotted(className)}
+}
+
+func (r *Runner) traceAllocation(ref stack.Reference, item HeapItem) {
+	r.tracer.Allocation(AllocationEvent{
+		Thread:    r.threadName(),
+		Depth:     r.stack.Depth(),
+		Reference: ref.String(),
+		Type:      describeType(item),
+	})
+}
+
+// exceptionEvent describes an exception at pc of the active frame.
+func (r *Runner) exceptionEvent(throwable *ThrowableError, pc int) ExceptionEvent {
+	event := ExceptionEvent{
+		Thread:    r.threadName(),
+		Depth:     r.stack.Depth(),
+		Class:     dotted(r.stack.CurrentClassName()),
+		Pc:        pc,
+		Exception: dotted(throwable.ClassName),
+	}
+
+	pool, poolErr := r.stack.CurrentConstantPool()
+	method, methodErr := r.stack.CurrentMethod()
+	if poolErr == nil && methodErr == nil {
+		event.Method, _ = pool.GetUtf8(method.NameIndex)
+	}
+
+	return event
+}
+
+// traceThrow reports an exception thrown by the instruction at pc of the active frame, or by
+// the active native method if pc is -1. Exceptions that propagated out of a called method have
+// already been reported where they were thrown.
+func (r *Runner) traceThrow(err error, pc int) {
+	throwable, ok := err.(*ThrowableError)
+	if !ok || len(throwable.trace) > 0 {
+		return
+	}
+
+	r.tracer.Throw(r.exceptionEvent(throwable, pc))
+}
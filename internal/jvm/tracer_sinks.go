@@ -0,0 +1,220 @@
+package jvm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// textTracer writes an indented call trace, one line per event.
+type textTracer struct {
+	w io.Writer
+}
+
+// NewTextTracer returns a Tracer writing a human-readable call trace to w, like
+//
+//	[main] -> Main.add(1, 2)
+//	[main]   new 0x2a java.lang.StringBuilder
+//	[main] <- Main.add = 3
+func NewTextTracer(w io.Writer) Tracer {
+	return &textTracer{w: w}
+}
+
+// printf writes a line of thread indented by depth levels.
+func (t *textTracer) printf(thread string, depth int, format string, args ...any) {
+	fmt.Fprintf(t.w, "[%s] %s%s\n", thread, strings.Repeat("  ", max(depth, 0)), fmt.Sprintf(format, args...))
+}
+
+func (t *textTracer) MethodEntry(event MethodEvent) {
+	t.printf(event.Thread, event.Depth-1, "-> %s.%s(%s)", event.Class, event.Method, strings.Join(event.Args, ", "))
+}
+
+func (t *textTracer) MethodExit(event MethodEvent) {
+	switch {
+	case event.Exception != "":
+		t.printf(event.Thread, event.Depth-1, "<- %s.%s threw %s", event.Class, event.Method, event.Exception)
+	case event.Return != "":
+		t.printf(event.Thread, event.Depth-1, "<- %s.%s = %s", event.Class, event.Method, event.Return)
+	default:
+		t.printf(event.Thread, event.Depth-1, "<- %s.%s", event.Class, event.Method)
+	}
+}
+
+func (t *textTracer) Instruction(event InstructionEvent) {
+	t.printf(event.Thread, event.Depth, "%4d: %s", event.Pc, event.Instruction)
+}
+
+func (t *textTracer) ClassLoad(event ClassEvent) {
+	t.printf(event.Thread, event.Depth, "load %s", event.Class)
+}
+
+func (t *textTracer) ClassInit(event ClassEvent) {
+	t.printf(event.Thread, event.Depth, "init %s", event.Class)
+}
+
+func (t *textTracer) Allocation(event AllocationEvent) {
+	t.printf(event.Thread, event.Depth, "new %s %s", event.Reference, event.Type)
+}
+
+func (t *textTracer) Throw(event ExceptionEvent) {
+	t.printf(event.Thread, event.Depth, "throw %s at %s.%s@%d", event.Exception, event.Class, event.Method, event.Pc)
+}
+
+func (t *textTracer) Catch(event ExceptionEvent) {
+	t.printf(event.Thread, event.Depth, "catch %s at %s.%s@%d", event.Exception, event.Class, event.Method, event.Pc)
+}
+
+// jsonTracer writes one JSON object per event, with the kind of event in the event field.
+type jsonTracer struct {
+	encoder *json.Encoder
+}
+
+// NewJSONTracer returns a Tracer writing JSON lines to w, like
+//
+//	{"event":"entry","thread":"main","depth":2,"class":"Main","method":"add","descriptor":"(II)I","args":["1","2"]}
+//
+// The events are entry, exit, instruction, load, init, alloc, throw and catch.
+func NewJSONTracer(w io.Writer) Tracer {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return &jsonTracer{encoder: encoder}
+}
+
+func (t *jsonTracer) MethodEntry(event MethodEvent) {
+	t.encoder.Encode(struct {
+		Event string `json:"event"`
+		MethodEvent
+	}{"entry", event})
+}
+
+func (t *jsonTracer) MethodExit(event MethodEvent) {
+	t.encoder.Encode(struct {
+		Event string `json:"event"`
+		MethodEvent
+	}{"exit", event})
+}
+
+func (t *jsonTracer) Instruction(event InstructionEvent) {
+	t.encoder.Encode(struct {
+		Event string `json:"event"`
+		InstructionEvent
+	}{"instruction", event})
+}
+
+func (t *jsonTracer) ClassLoad(event ClassEvent) {
+	t.encoder.Encode(struct {
+		Event string `json:"event"`
+		ClassEvent
+	}{"load", event})
+}
+
+func (t *jsonTracer) ClassInit(event ClassEvent) {
+	t.encoder.Encode(struct {
+		Event string `json:"event"`
+		ClassEvent
+	}{"init", event})
+}
+
+func (t *jsonTracer) Allocation(event AllocationEvent) {
+	t.encoder.Encode(struct {
+		Event string `json:"event"`
+		AllocationEvent
+	}{"alloc", event})
+}
+
+func (t *jsonTracer) Throw(event ExceptionEvent) {
+	t.encoder.Encode(struct {
+		Event string `json:"event"`
+		ExceptionEvent
+	}{"throw", event})
+}
+
+func (t *jsonTracer) Catch(event ExceptionEvent) {
+	t.encoder.Encode(struct {
+		Event string `json:"event"`
+		ExceptionEvent
+	}{"catch", event})
+}
+
+// TraceFilter passes the events matching one of Patterns on to Tracer.
+// Patterns are path.Match patterns for Class.method, like java.lang.String.* or *.<clinit>,
+// class events match the class part of a pattern as well. No patterns match every event.
+// Allocations match by the class of the object, or the element class of arrays.
+type TraceFilter struct {
+	Tracer
+	Patterns []string
+	// Instructions passes on instruction events, which are dropped otherwise
+	Instructions bool
+}
+
+// matches reports whether name, a Class.method or a class, matches one of the patterns.
+func (f *TraceFilter) matches(name string, class bool) bool {
+	if len(f.Patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range f.Patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+
+		if i := strings.LastIndex(pattern, "."); class && i >= 0 {
+			if matched, _ := path.Match(pattern[:i], name); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (f *TraceFilter) MethodEntry(event MethodEvent) {
+	if f.matches(event.Class+"."+event.Method, false) {
+		f.Tracer.MethodEntry(event)
+	}
+}
+
+func (f *TraceFilter) MethodExit(event MethodEvent) {
+	if f.matches(event.Class+"."+event.Method, false) {
+		f.Tracer.MethodExit(event)
+	}
+}
+
+func (f *TraceFilter) Instruction(event InstructionEvent) {
+	if f.Instructions && f.matches(event.Class+"."+event.Method, false) {
+		f.Tracer.Instruction(event)
+	}
+}
+
+func (f *TraceFilter) ClassLoad(event ClassEvent) {
+	if f.matches(event.Class, true) {
+		f.Tracer.ClassLoad(event)
+	}
+}
+
+func (f *TraceFilter) ClassInit(event ClassEvent) {
+	if f.matches(event.Class, true) {
+		f.Tracer.ClassInit(event)
+	}
+}
+
+func (f *TraceFilter) Allocation(event AllocationEvent) {
+	element, _, _ := strings.Cut(event.Type, "[")
+	if f.matches(element, true) {
+		f.Tracer.Allocation(event)
+	}
+}
+
+func (f *TraceFilter) Throw(event ExceptionEvent) {
+	if f.matches(event.Class+"."+event.Method, false) {
+		f.Tracer.Throw(event)
+	}
+}
+
+func (f *TraceFilter) Catch(event ExceptionEvent) {
+	if f.matches(event.Class+"."+event.Method, false) {
+		f.Tracer.Catch(event)
+	}
+}
@@ -0,0 +1,100 @@
+package jvm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/stretchr/testify/assert"
+)
+
+// runTraced runs Main.caught, which catches the exception Main.thrower throws, with tracer.
+func runTraced(t *testing.T, tracer Tracer) {
+	main := newTestClass("Main", "java/lang/Object")
+	main.method(class.AccStatic, "thrower", "()V", throwCode(main, "java/lang/ArithmeticException")...)
+	caller := append([]byte{InvokeStaticOp}, u2(main.ref("Main", "thrower", "()V"))...)
+	caller = append(caller, IConst0, IReturn, IConst1, IReturn)
+	main.method(class.AccStatic, "caught", "()I", caller...).
+		catch(0, 3, 5, "java/lang/RuntimeException")
+
+	r, ctx := newTestRunner(t, append(throwableClasses(), main.build())...)
+	WithTracer(tracer)(r)
+
+	_, err := invokeTestMethod(t, ctx, r, "Main", "caught", "()I")
+	assert.NoError(t, err)
+}
+
+func TestTextTracer(t *testing.T) {
+	out := &strings.Builder{}
+	runTraced(t, NewTextTracer(out))
+
+	// the test frame invoking Main.caught is at depth 1
+	expected := `[main]   -> Main.caught()
+[main]        0: invokestatic   #14    // Main.thrower:()V
+[main]     init Main
+[main]     init java.lang.Object
+[main]     -> Main.thrower()
+[main]          0: new            #4     // java/lang/ArithmeticException
+[main]       init java.lang.ArithmeticException
+[main]       init java.lang.RuntimeException
+[main]       init java.lang.Exception
+[main]       init java.lang.Throwable
+[main]       new 0x1 java.lang.ArithmeticException
+[main]          3: dup
+[main]          4: invokespecial  #9     // java/lang/ArithmeticException.<init>:()V
+[main]       -> java.lang.ArithmeticException.<init>(0x1 java.lang.ArithmeticException)
+[main]            0: return
+[main]       <- java.lang.ArithmeticException.<init>
+[main]          7: athrow
+[main]       throw java.lang.ArithmeticException at Main.thrower@7
+[main]     <- Main.thrower threw java.lang.ArithmeticException
+[main]     catch java.lang.ArithmeticException at Main.caught@5
+[main]        5: iconst_1
+[main]        6: ireturn
+[main]   <- Main.caught = 1
+`
+	assert.Equal(t, expected, out.String())
+}
+
+func TestJSONTracerFilter(t *testing.T) {
+	out := &strings.Builder{}
+	runTraced(t, &TraceFilter{Tracer: NewJSONTracer(out), Patterns: []string{"Main.thrower", "java.lang.Arith*.*"}})
+
+	expected := `{"event":"init","thread":"main","depth":2,"class":"Main"}
+{"event":"entry","thread":"main","depth":3,"class":"Main","method":"thrower","descriptor":"()V"}
+{"event":"init","thread":"main","depth":3,"class":"java.lang.ArithmeticException"}
+{"event":"alloc","thread":"main","depth":3,"reference":"0x1","type":"java.lang.ArithmeticException"}
+{"event":"entry","thread":"main","depth":4,"class":"java.lang.ArithmeticException","method":"<init>","descriptor":"()V","args":["0x1 java.lang.ArithmeticException"]}
+{"event":"exit","thread":"main","depth":4,"class":"java.lang.ArithmeticException","method":"<init>","descriptor":"()V"}
+{"event":"throw","thread":"main","depth":3,"class":"Main","method":"thrower","pc":7,"exception":"java.lang.ArithmeticException"}
+{"event":"exit","thread":"main","depth":3,"class":"Main","method":"thrower","descriptor":"()V","exception":"java.lang.ArithmeticException"}
+`
+	assert.Equal(t, expected, out.String())
+}
+
+func TestTracerReturnValues(t *testing.T) {
+	main := newTestClass("Main", "java/lang/Object")
+	main.method(class.AccStatic, "sub", "(II)I", ILoad0, ILoad1, ISub, IReturn)
+	caller := append([]byte{BiPush, 5, BiPush, 2, InvokeStaticOp}, u2(main.ref("Main", "sub", "(II)I"))...)
+	main.method(class.AccStatic, "caller", "()I", append(caller, IReturn)...)
+	main.method(class.AccStatic, "nothing", "()V", RetOp)
+
+	r, ctx := newTestRunner(t, main.build())
+	out := &strings.Builder{}
+	WithTracer(&TraceFilter{Tracer: NewTextTracer(out), Patterns: []string{"Main.*"}})(r)
+
+	_, err := invokeTestMethod(t, ctx, r, "Main", "caller", "()I")
+	assert.NoError(t, err)
+	_, err = invokeTestMethod(t, ctx, r, "Main", "nothing", "()V")
+	assert.NoError(t, err)
+
+	expected := `[main]   -> Main.caller()
+[main]     init Main
+[main]     -> Main.sub(5, 2)
+[main]     <- Main.sub = 3
+[main]   <- Main.caller = 3
+[main]   -> Main.nothing()
+[main]   <- Main.nothing
+`
+	assert.Equal(t, expected, out.String())
+}
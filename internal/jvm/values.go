@@ -0,0 +1,101 @@
+package jvm
+
+import (
+	"fmt"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+)
+
+// convertValue converts value to fieldType, the type of a field it is stored in or of a native
+// method parameter it is passed as. Bytecode computes with ints, so boolean, byte, char and short
+// values are narrowed like putfield does (JVMS §6.5), the result always has the descriptor type,
+// e.g. a BooleanValue for Z.
+func convertValue(fieldType class.FieldType, value stack.Value) (stack.Value, error) {
+	base, primitive := fieldType.(class.BaseType)
+	if primitive == isReferenceValue(value) {
+		return nil, fmt.Errorf("type %v is incompatible with value %v", fieldType, value)
+	}
+
+	n, ok := intOf(value)
+	if !ok {
+		return value, nil
+	}
+
+	switch base {
+	case class.BOOLEAN:
+		return stack.BooleanValue{Value: n&1 != 0}, nil
+	case class.BYTE:
+		return stack.ByteValue{Value: uint8(n)}, nil
+	case class.CHAR:
+		return stack.CharValue{Value: rune(uint16(n))}, nil
+	case class.SHORT:
+		return stack.ShortValue{Value: uint16(n)}, nil
+	case class.INT:
+		return stack.IntValue{Value: int32(n)}, nil
+	default:
+		return value, nil
+	}
+}
+
+// intOf returns the value of the integral Java types, whichever of them value has. Values on the
+// operand stack are loosely typed, e.g. a boolean is an IntValue there, convertValue gives fields
+// and the arguments of natives their descriptor type.
+func intOf(value stack.Value) (int64, bool) {
+	switch v := value.(type) {
+	case stack.BooleanValue:
+		if v.Value {
+			return 1, true
+		}
+
+		return 0, true
+	case stack.ByteValue:
+		return int64(int8(v.Value)), true
+	case stack.CharValue:
+		return int64(v.Value), true
+	case stack.ShortValue:
+		return int64(int16(v.Value)), true
+	case stack.IntValue:
+		return int64(v.Value), true
+	case stack.LongValue:
+		return int64(v.Value), true
+	default:
+		return 0, false
+	}
+}
+
+// boolOf returns the value of a Java boolean, whatever integral Value holds it.
+func boolOf(value stack.Value) bool {
+	n, _ := intOf(value)
+	return n != 0
+}
+
+// intArg returns argument i of a native method, which must be of an integral type.
+func intArg(args []stack.Value, i int) (int64, error) {
+	n, ok := intOf(args[i])
+	if !ok {
+		return 0, fmt.Errorf("argument %d is %v, not an integral value", i, args[i])
+	}
+
+	return n, nil
+}
+
+// floatArg returns argument i of a native method, which must be a float.
+func floatArg(args []stack.Value, i int) (float32, error) {
+	v, ok := args[i].(stack.FloatValue)
+	if !ok {
+		return 0, fmt.Errorf("argument %d is %v, not a float", i, args[i])
+	}
+
+	return v.Value, nil
+}
+
+// doubleArg returns argument i of a native method, which must be a double.
+func doubleArg(args []stack.Value, i int) (float64, error) {
+	v, ok := args[i].(stack.DoubleValue)
+	if !ok {
+		return 0, fmt.Errorf("argument %d is %v, not a double", i, args[i])
+	}
+
+	return v.Value, nil
+}
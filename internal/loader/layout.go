@@ -0,0 +1,91 @@
+package loader
+
+import (
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+)
+
+// FieldKey identifies a field by its declaring class, name and descriptor.
+// Fields of the same name in a class and its superclass are distinct fields.
+type FieldKey struct {
+	Class      string
+	Name       string
+	Descriptor string
+}
+
+// Layout maps the instance fields of a class, including inherited ones, to slots inside an object.
+// It is computed once when the class is loaded and shared by all of its instances.
+// The slots of the superclass come first, so a slot index is valid for every subclass.
+type Layout struct {
+	ClassName string
+	Fields    []LayoutField
+	defaults  []stack.Value
+	indexes   map[FieldKey]int
+}
+
+type LayoutField struct {
+	FieldKey
+	Type class.FieldType
+}
+
+func NewLayout(c *class.Class, super *Layout) (*Layout, error) {
+	layout := &Layout{
+		ClassName: c.Name,
+		Fields:    make([]LayoutField, 0, len(c.Fields)),
+		defaults:  make([]stack.Value, 0, len(c.Fields)),
+		indexes:   make(map[FieldKey]int, len(c.Fields)),
+	}
+
+	if super != nil {
+		layout.Fields = append(layout.Fields, super.Fields...)
+		layout.defaults = append(layout.defaults, super.defaults...)
+		for key, index := range super.indexes {
+			layout.indexes[key] = index
+		}
+	}
+
+	for _, field := range c.Fields {
+		if field.IsStatic() {
+			continue
+		}
+
+		name, err := c.ConstantPool.GetUtf8(field.NameIndex)
+		if err != nil {
+			return nil, err
+		}
+
+		descriptor, err := c.ConstantPool.GetUtf8(field.DescriptorIndex)
+		if err != nil {
+			return nil, err
+		}
+
+		fieldType, err := class.NewFieldType(descriptor)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := stack.DefaultValue(fieldType)
+		if err != nil {
+			return nil, err
+		}
+
+		key := FieldKey{Class: c.Name, Name: name, Descriptor: descriptor}
+		layout.indexes[key] = len(layout.Fields)
+		layout.Fields = append(layout.Fields, LayoutField{FieldKey: key, Type: fieldType})
+		layout.defaults = append(layout.defaults, value)
+	}
+
+	return layout, nil
+}
+
+func (l *Layout) Index(key FieldKey) (int, bool) {
+	index, ok := l.indexes[key]
+	return index, ok
+}
+
+// NewFields returns the field slots of a freshly allocated instance, set to their default values.
+func (l *Layout) NewFields() []stack.Value {
+	fields := make([]stack.Value, len(l.defaults))
+	copy(fields, l.defaults)
+	return fields
+}
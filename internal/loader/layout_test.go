@@ -0,0 +1,114 @@
+package loader
+
+import (
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type testField struct {
+	name       string
+	descriptor string
+	static     bool
+}
+
+// newTestClass builds a class with the given superclass, interfaces and fields.
+func newTestClass(name string, super string, interfaces []string, fields []testField) *class.Class {
+	infos := []class.CpInfo{class.ReservedInfo{}}
+	utf8 := func(s string) uint16 {
+		infos = append(infos, class.Utf8Info{Content: s})
+		return uint16(len(infos) - 1)
+	}
+	classInfo := func(s string) uint16 {
+		nameIndex := utf8(s)
+		infos = append(infos, class.ClassInfo{NameIndex: nameIndex})
+		return uint16(len(infos) - 1)
+	}
+
+	c := &class.Class{Name: name}
+
+	if super != "" {
+		c.SuperClass = classInfo(super)
+	}
+
+	for _, i := range interfaces {
+		c.Interfaces = append(c.Interfaces, classInfo(i))
+	}
+
+	for _, f := range fields {
+		field := class.Field{NameIndex: utf8(f.name), DescriptorIndex: utf8(f.descriptor)}
+		if f.static {
+			field.AccessFlags = class.AccStatic
+		}
+
+		c.Fields = append(c.Fields, field)
+	}
+
+	c.ConstantPool = class.ConstantPool{Infos: infos}
+	return c
+}
+
+func defineTestClasses(t *testing.T, l *Loader, classes ...*class.Class) {
+	ctx := logger.OnContext(t.Context(), zap.NewNop().Sugar())
+	for _, c := range classes {
+		assert.Nil(t, l.Define(ctx, c))
+	}
+}
+
+func TestLayoutInheritance(t *testing.T) {
+	base := newTestClass("Base", "", nil, []testField{
+		{name: "x", descriptor: "I"},
+		{name: "COUNT", descriptor: "I", static: true},
+	})
+	derived := newTestClass("Derived", "Base", nil, []testField{
+		{name: "x", descriptor: "I"},
+		{name: "name", descriptor: "Ljava/lang/String;"},
+	})
+
+	baseLayout, err := NewLayout(base, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(baseLayout.Fields))
+
+	layout, err := NewLayout(derived, baseLayout)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(layout.Fields))
+
+	inherited, ok := layout.Index(FieldKey{Class: "Base", Name: "x", Descriptor: "I"})
+	assert.True(t, ok)
+	assert.Equal(t, 0, inherited)
+
+	shadowing, ok := layout.Index(FieldKey{Class: "Derived", Name: "x", Descriptor: "I"})
+	assert.True(t, ok)
+	assert.Equal(t, 1, shadowing)
+
+	_, ok = layout.Index(FieldKey{Class: "Base", Name: "COUNT", Descriptor: "I"})
+	assert.False(t, ok)
+
+	assert.Equal(t, 3, len(layout.NewFields()))
+}
+
+func TestResolveField(t *testing.T) {
+	ctx := logger.OnContext(t.Context(), zap.NewNop().Sugar())
+	l := NewLoader(nil)
+
+	defineTestClasses(t, &l,
+		newTestClass("Constants", "", nil, []testField{{name: "MAX", descriptor: "I", static: true}}),
+		newTestClass("Base", "", nil, []testField{{name: "x", descriptor: "I"}, {name: "MAX", descriptor: "I", static: true}}),
+		newTestClass("Derived", "Base", []string{"Constants"}, []testField{{name: "y", descriptor: "J"}}),
+	)
+
+	key, _, err := l.ResolveField(ctx, "Derived", "x", "I")
+	assert.Nil(t, err)
+	assert.Equal(t, FieldKey{Class: "Base", Name: "x", Descriptor: "I"}, key)
+
+	// superinterfaces are searched before the superclass
+	key, _, err = l.ResolveField(ctx, "Derived", "MAX", "I")
+	assert.Nil(t, err)
+	assert.Equal(t, "Constants", key.Class)
+
+	_, _, err = l.ResolveField(ctx, "Derived", "x", "J")
+	assert.ErrorIs(t, err, ErrNoSuchField)
+}
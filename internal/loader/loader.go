@@ -10,62 +10,156 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/m4tthewde/swell/internal/class"
 	"github.com/m4tthewde/swell/internal/jvm/stack"
 	"github.com/m4tthewde/swell/internal/logger"
 )
 
+var ErrNoSuchField = errors.New("no such field")
+var ErrNoSuchMethod = errors.New("no such method")
+
 type LoaderClass struct {
-	class  class.Class
-	fields map[string]stack.Value
+	class   class.Class
+	layout  *Layout
+	statics map[FieldKey]stack.Value
 }
 
+// DefineHook is called for every class right after it has been defined.
+type DefineHook func(ctx context.Context, c *class.Class) error
+
+// Loader is safe for concurrent use. When several goroutines load the same class at once,
+// the first one to define it wins and the others get its class.
 type Loader struct {
 	classPath []string
-	classes   map[string]LoaderClass
+	// lock guards classes and their static fields
+	lock     sync.RWMutex
+	classes  map[string]*LoaderClass
+	onDefine DefineHook
 }
 
 func NewLoader(classPath []string) Loader {
 	return Loader{
-		classes:   make(map[string]LoaderClass),
+		classes:   make(map[string]*LoaderClass),
 		classPath: classPath,
 	}
 }
 
-func (l *Loader) SetField(className string, fieldName string, value stack.Value) error {
-	loaderClass, ok := l.classes[className]
+// ClassPath returns the directories and files classes are loaded from.
+func (l *Loader) ClassPath() []string {
+	return l.classPath
+}
+
+// SetDefineHook installs the hook called for every newly defined class.
+func (l *Loader) SetDefineHook(hook DefineHook) {
+	l.onDefine = hook
+}
+
+// IsDefined reports whether a class has been defined, without trying to load it.
+func (l *Loader) IsDefined(className string) bool {
+	_, ok := l.lookup(className)
+	return ok
+}
+
+func (l *Loader) lookup(className string) (*LoaderClass, bool) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	c, ok := l.classes[className]
+	return c, ok
+}
+
+// SetField sets the static field identified by key, key.Class has to be the declaring class.
+func (l *Loader) SetField(key FieldKey, value stack.Value) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	loaderClass, ok := l.classes[key.Class]
 	if !ok {
-		return fmt.Errorf("class %s is not loaded", className)
+		return fmt.Errorf("class %s is not loaded", key.Class)
+	}
+
+	if _, ok := loaderClass.statics[key]; !ok {
+		return fmt.Errorf("%w: static %s %s in %s", ErrNoSuchField, key.Name, key.Descriptor, key.Class)
 	}
 
-	loaderClass.fields[fieldName] = value
-	l.classes[className] = loaderClass
+	loaderClass.statics[key] = value
 	return nil
 }
 
-func (l *Loader) GetField(className string, fieldName string) (stack.Value, error) {
-	loaderClass, ok := l.classes[className]
+// GetField returns the static field identified by key, key.Class has to be the declaring class.
+func (l *Loader) GetField(key FieldKey) (stack.Value, error) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	loaderClass, ok := l.classes[key.Class]
 	if !ok {
-		return nil, fmt.Errorf("class %s is not loaded", className)
+		return nil, fmt.Errorf("class %s is not loaded", key.Class)
 	}
 
-	field, ok := loaderClass.fields[fieldName]
+	value, ok := loaderClass.statics[key]
 	if !ok {
-		return nil, fmt.Errorf("field %s not found in %s", fieldName, className)
+		return nil, fmt.Errorf("%w: static %s %s in %s", ErrNoSuchField, key.Name, key.Descriptor, key.Class)
+	}
+
+	return value, nil
+}
+
+// ClassSnapshot is the state of a loaded class, used for heap dumps.
+type ClassSnapshot struct {
+	Class   *class.Class
+	Layout  *Layout
+	Statics []StaticValue
+}
+
+type StaticValue struct {
+	Name       string
+	Descriptor string
+	Value      stack.Value
+}
+
+func (l *Loader) Snapshot() ([]ClassSnapshot, error) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	snapshots := make([]ClassSnapshot, 0, len(l.classes))
+	for _, c := range l.classes {
+		statics := make([]StaticValue, 0, len(c.statics))
+		for key, value := range c.statics {
+			statics = append(statics, StaticValue{Name: key.Name, Descriptor: key.Descriptor, Value: value})
+		}
+
+		snapshots = append(snapshots, ClassSnapshot{Class: &c.class, Layout: c.layout, Statics: statics})
 	}
 
-	return field, nil
+	return snapshots, nil
+}
+
+// VisitStatics calls visit for the value of every static field of every loaded class.
+func (l *Loader) VisitStatics(visit func(stack.Value)) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	for _, c := range l.classes {
+		for _, value := range c.statics {
+			visit(value)
+		}
+	}
 }
 
 func (l *Loader) Load(ctx context.Context, className string) (*class.Class, error) {
 	log := logger.FromContext(ctx)
 
-	c, ok := l.classes[className]
+	c, ok := l.lookup(className)
 	if ok {
 		return &c.class, nil
 	}
 
+	if strings.HasPrefix(className, "[") {
+		return l.loadArrayClass(ctx, className)
+	}
+
 	log.Infow("loading", "className", className)
 
 	r, err := getReader(className, l.classPath)
@@ -82,11 +176,294 @@ func (l *Loader) Load(ctx context.Context, className string) (*class.Class, erro
 		return nil, err
 	}
 
-	l.classes[className] = LoaderClass{class: *class, fields: make(map[string]stack.Value)}
+	c, err = l.define(ctx, class, true)
+	if err != nil {
+		return nil, err
+	}
 
 	log.Infow("finished loading", "clasName", className)
 
-	return class, nil
+	return &c.class, nil
+}
+
+// loadArrayClass creates an array class on demand (JVMS §5.3.3). The element class is loaded first and
+// the array class is defined by the same loader, public if its element type is.
+func (l *Loader) loadArrayClass(ctx context.Context, className string) (*class.Class, error) {
+	component := className[1:]
+	accessFlags := uint16(class.AccPublic | class.AccFinal | class.AccAbstract)
+
+	switch {
+	case strings.HasPrefix(component, "L") && strings.HasSuffix(component, ";"):
+		component = component[1 : len(component)-1]
+		fallthrough
+	case strings.HasPrefix(component, "["):
+		element, err := l.Load(ctx, component)
+		if err != nil {
+			return nil, err
+		}
+
+		accessFlags = element.AccessFlags&class.AccPublic | class.AccFinal | class.AccAbstract
+	default:
+		if len(component) != 1 {
+			return nil, fmt.Errorf("invalid array class %s", className)
+		}
+
+		if _, err := class.NewBaseType(rune(component[0])); err != nil {
+			return nil, fmt.Errorf("invalid array class %s", className)
+		}
+	}
+
+	c, err := l.define(ctx, class.NewArrayClass(className, accessFlags), true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c.class, nil
+}
+
+// Define links a parsed class and makes it available to Load.
+// The superclass is loaded first and the static fields are prepared with their default values.
+func (l *Loader) Define(ctx context.Context, c *class.Class) error {
+	_, err := l.define(ctx, c, false)
+	return err
+}
+
+// define links and inserts a class. The lock is not held while the superclass is loaded, so another
+// goroutine may define the same class in the meantime, its class is returned if reuse is set.
+func (l *Loader) define(ctx context.Context, c *class.Class, reuse bool) (*LoaderClass, error) {
+	if existing, ok := l.lookup(c.Name); ok {
+		if reuse {
+			return existing, nil
+		}
+
+		return nil, fmt.Errorf("class %s is already defined", c.Name)
+	}
+
+	// the superclass has to be loaded first, its layout is the prefix of ours
+	var superLayout *Layout
+	superClassName, ok, err := c.SuperClassName()
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		superLayout, err = l.Layout(ctx, superClassName)
+		if err != nil {
+			return nil, fmt.Errorf("loading superclass of %s: %w", c.Name, err)
+		}
+	}
+
+	layout, err := NewLayout(c, superLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	statics, err := prepare(c)
+	if err != nil {
+		return nil, err
+	}
+
+	l.lock.Lock()
+	if existing, ok := l.classes[c.Name]; ok {
+		l.lock.Unlock()
+		if reuse {
+			return existing, nil
+		}
+
+		return nil, fmt.Errorf("class %s is already defined", c.Name)
+	}
+
+	loaderClass := &LoaderClass{class: *c, layout: layout, statics: statics}
+	l.classes[c.Name] = loaderClass
+	l.lock.Unlock()
+
+	if l.onDefine != nil {
+		err = l.onDefine(ctx, &loaderClass.class)
+		if err != nil {
+			l.lock.Lock()
+			delete(l.classes, c.Name)
+			l.lock.Unlock()
+			return nil, err
+		}
+	}
+
+	return loaderClass, nil
+}
+
+// prepare creates the static fields of a class with their default values (JVMS §5.4.2).
+func prepare(c *class.Class) (map[FieldKey]stack.Value, error) {
+	statics := make(map[FieldKey]stack.Value)
+
+	for _, field := range c.Fields {
+		if !field.IsStatic() {
+			continue
+		}
+
+		name, err := c.ConstantPool.GetUtf8(field.NameIndex)
+		if err != nil {
+			return nil, err
+		}
+
+		descriptor, err := c.ConstantPool.GetUtf8(field.DescriptorIndex)
+		if err != nil {
+			return nil, err
+		}
+
+		fieldType, err := class.NewFieldType(descriptor)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := stack.DefaultValue(fieldType)
+		if err != nil {
+			return nil, err
+		}
+
+		statics[FieldKey{Class: c.Name, Name: name, Descriptor: descriptor}] = value
+	}
+
+	return statics, nil
+}
+
+// ResolveField finds the class declaring the field referenced through className (JVMS §5.4.3.2).
+// The class itself is searched first, then its superinterfaces and then its superclass.
+func (l *Loader) ResolveField(ctx context.Context, className string, name string, descriptor string) (FieldKey, *class.Field, error) {
+	c, err := l.Load(ctx, className)
+	if err != nil {
+		return FieldKey{}, nil, err
+	}
+
+	field, ok, err := c.GetField(name, descriptor)
+	if err != nil {
+		return FieldKey{}, nil, err
+	}
+
+	if ok {
+		return FieldKey{Class: c.Name, Name: name, Descriptor: descriptor}, field, nil
+	}
+
+	interfaceNames, err := c.InterfaceNames()
+	if err != nil {
+		return FieldKey{}, nil, err
+	}
+
+	for _, interfaceName := range interfaceNames {
+		key, field, err := l.ResolveField(ctx, interfaceName, name, descriptor)
+		if err == nil {
+			return key, field, nil
+		}
+
+		if !errors.Is(err, ErrNoSuchField) {
+			return FieldKey{}, nil, err
+		}
+	}
+
+	superClassName, ok, err := c.SuperClassName()
+	if err != nil {
+		return FieldKey{}, nil, err
+	}
+
+	if ok {
+		return l.ResolveField(ctx, superClassName, name, descriptor)
+	}
+
+	return FieldKey{}, nil, fmt.Errorf("%w: %s %s", ErrNoSuchField, name, descriptor)
+}
+
+// ResolveMethod finds the method named by className, name and descriptor, see JVMS §5.4.3.3.
+// The class and its superclasses are searched first, then the superinterfaces,
+// preferring a non-abstract method over an abstract one.
+// It returns the declaring class along with the method.
+func (l *Loader) ResolveMethod(ctx context.Context, className string, name string, descriptor string) (*class.Class, *class.Method, error) {
+	interfaceNames := make([]string, 0)
+
+	for current, ok := className, true; ok; {
+		c, err := l.Load(ctx, current)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		method, found, err := c.GetMethod(name, descriptor)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if found {
+			return c, method, nil
+		}
+
+		names, err := c.InterfaceNames()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		interfaceNames = append(interfaceNames, names...)
+
+		current, ok, err = c.SuperClassName()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var abstractClass *class.Class
+	var abstractMethod *class.Method
+	visited := make(map[string]struct{})
+
+	for len(interfaceNames) > 0 {
+		interfaceName := interfaceNames[0]
+		interfaceNames = interfaceNames[1:]
+
+		if _, ok := visited[interfaceName]; ok {
+			continue
+		}
+
+		visited[interfaceName] = struct{}{}
+
+		c, err := l.Load(ctx, interfaceName)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		method, found, err := c.GetMethod(name, descriptor)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if found && !method.IsAbstract() {
+			return c, method, nil
+		}
+
+		if found && abstractMethod == nil {
+			abstractClass, abstractMethod = c, method
+		}
+
+		names, err := c.InterfaceNames()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		interfaceNames = append(interfaceNames, names...)
+	}
+
+	if abstractMethod != nil {
+		return abstractClass, abstractMethod, nil
+	}
+
+	return nil, nil, fmt.Errorf("%w: %s.%s%s", ErrNoSuchMethod, className, name, descriptor)
+}
+
+func (l *Loader) Layout(ctx context.Context, className string) (*Layout, error) {
+	_, err := l.Load(ctx, className)
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := l.lookup(className)
+	if !ok {
+		return nil, fmt.Errorf("class %s is not loaded", className)
+	}
+
+	return c.layout, nil
 }
 
 func getReader(className string, classPath []string) (io.ReadCloser, error) {
@@ -95,7 +472,7 @@ func getReader(className string, classPath []string) (io.ReadCloser, error) {
 		return nil, errors.New("JAVA_HOME not set")
 	}
 
-	reader, err := zip.OpenReader(javaHome + "jmods/java.base.jmod")
+	reader, err := zip.OpenReader(filepath.Join(javaHome, "jmods", "java.base.jmod"))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,85 @@
+package loader
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/m4tthewde/swell/internal/class"
+	"github.com/m4tthewde/swell/internal/jvm/stack"
+	"github.com/m4tthewde/swell/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestConcurrentLoad(t *testing.T) {
+	ctx := logger.OnContext(t.Context(), zap.NewNop().Sugar())
+	l := NewLoader(nil)
+
+	defineTestClasses(t, &l,
+		newTestClass("java/lang/Object", "", nil, nil),
+		newTestClass("Point", "java/lang/Object", nil, []testField{{name: "COUNT", descriptor: "I", static: true}}),
+	)
+
+	const goroutines = 16
+	arrays := make([]*class.Class, goroutines)
+	count := FieldKey{Class: "Point", Name: "COUNT", Descriptor: "I"}
+
+	var wg sync.WaitGroup
+	for i := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// all goroutines race to create the same array class, the first one to define it wins
+			array, err := l.Load(ctx, "[[LPoint;")
+			assert.Nil(t, err)
+			arrays[i] = array
+
+			assert.Nil(t, l.Define(ctx, newTestClass(fmt.Sprintf("Point%d", i), "Point", nil, nil)))
+			_, err = l.Layout(ctx, fmt.Sprintf("Point%d", i))
+			assert.Nil(t, err)
+
+			assert.Nil(t, l.SetField(count, stack.IntValue{Value: int32(i)}))
+			_, err = l.GetField(count)
+			assert.Nil(t, err)
+		}()
+	}
+
+	wg.Wait()
+
+	for _, array := range arrays {
+		assert.Same(t, arrays[0], array)
+	}
+
+	assert.True(t, l.IsDefined("[LPoint;"))
+	assert.NotNil(t, l.Define(ctx, newTestClass("Point0", "Point", nil, nil)))
+}
+
+func TestGetReaderFromJmod(t *testing.T) {
+	// JAVA_HOME usually has no trailing separator
+	javaHome := t.TempDir()
+	assert.Nil(t, os.Mkdir(filepath.Join(javaHome, "jmods"), 0o755))
+
+	f, err := os.Create(filepath.Join(javaHome, "jmods", "java.base.jmod"))
+	assert.Nil(t, err)
+	w := zip.NewWriter(f)
+	entry, err := w.Create("classes/java/lang/Object.class")
+	assert.Nil(t, err)
+	_, err = entry.Write([]byte{0xca, 0xfe, 0xba, 0xbe})
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	assert.Nil(t, f.Close())
+
+	t.Setenv("JAVA_HOME", javaHome)
+
+	r, err := getReader("java/lang/Object", nil)
+	assert.Nil(t, err)
+	data, err := io.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xca, 0xfe, 0xba, 0xbe}, data)
+}
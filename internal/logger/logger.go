@@ -13,9 +13,12 @@ type contextKeyType string
 
 var (
 	contextKey = contextKeyType("logger")
+	nop        = zap.NewNop().Sugar()
 )
 
-func NewLogger() (*zap.SugaredLogger, error) {
+// NewLogger creates the logger of the command line, which logs to stdout.
+// A non-empty logFile additionally writes the log as JSON lines to that file.
+func NewLogger(logFile string) (*zap.SugaredLogger, error) {
 	devEncoderConfig := zap.NewDevelopmentEncoderConfig()
 	devEncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 	devEncoderConfig.EncodeCaller = func(caller zapcore.EntryCaller, enc zapcore.PrimitiveArrayEncoder) {
@@ -31,24 +34,26 @@ func NewLogger() (*zap.SugaredLogger, error) {
 	jsonEncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	jsonEncoder := zapcore.NewJSONEncoder(jsonEncoderConfig)
 
-	stdoutCore := zapcore.NewCore(
+	core := zapcore.NewCore(
 		consoleEncoder,
 		zapcore.AddSync(os.Stdout),
 		logLevel,
 	)
 
-	logFile, err := os.Create("swell.log.json")
-	if err != nil {
-		return nil, fmt.Errorf("could not create log file: %w", err)
-	}
+	if logFile != "" {
+		file, err := os.Create(logFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not create log file: %w", err)
+		}
 
-	fileCore := zapcore.NewCore(
-		jsonEncoder,
-		zapcore.AddSync(logFile),
-		logLevel,
-	)
+		fileCore := zapcore.NewCore(
+			jsonEncoder,
+			zapcore.AddSync(file),
+			logLevel,
+		)
 
-	core := zapcore.NewTee(stdoutCore, fileCore)
+		core = zapcore.NewTee(core, fileCore)
+	}
 
 	logger := zap.New(
 		core,
@@ -63,14 +68,15 @@ func OnContext(ctx context.Context, logger *zap.SugaredLogger) context.Context {
 	return context.WithValue(ctx, contextKey, logger)
 }
 
+// FromContext returns the logger on ctx, or one that discards everything if there is none.
 func FromContext(ctx context.Context) *zap.SugaredLogger {
 	if ctx != nil {
-		if v := ctx.Value(contextKey); v != nil {
-			return v.(*zap.SugaredLogger)
+		if v, ok := ctx.Value(contextKey).(*zap.SugaredLogger); ok {
+			return v
 		}
 	}
 
-	panic("No logger found in context")
+	return nop
 }
 
 func getLogLevel() zap.AtomicLevel {
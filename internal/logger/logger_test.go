@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContextWithoutLogger(t *testing.T) {
+	assert.NotPanics(t, func() {
+		FromContext(context.Background()).Infow("discarded")
+	})
+}
+
+func TestFromSlog(t *testing.T) {
+	var b bytes.Buffer
+	handler := slog.NewTextHandler(&b, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+
+			return a
+		},
+	})
+	log := FromSlog(slog.New(handler))
+
+	log.Debugw("hidden")
+	log.With("thread", "main").Infow("executing main", "mainClass", "Main", "depth", 2)
+
+	assert.Equal(t, "level=INFO msg=\"executing main\" thread=main mainClass=Main depth=2\n", b.String())
+}
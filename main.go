@@ -3,29 +3,50 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"net"
 	"os"
+	"os/signal"
+	"path"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/m4tthewde/swell/internal/jvm"
 	"github.com/m4tthewde/swell/internal/logger"
 )
 
 func main() {
-	log, err := logger.NewLogger()
+	// swell debug runs the program under the command-line debugger
+	args := os.Args[1:]
+	debug := len(args) > 0 && args[0] == "debug"
+	if debug {
+		args = args[1:]
+	}
+
+	options, args, err := parseOptions(args)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	if len(os.Args) < 2 {
-		log.Fatalln("no class provided")
+	if debug {
+		options.vm = append(options.vm, jvm.WithDebugger(os.Stdin, os.Stdout))
+	}
+
+	log, err := logger.NewLogger(options.logFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	mainClassName, err := getMainClassName()
+	mainClassName, err := getMainClassName(args)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	classPath, err := getClassPath()
+	classPath, err := getClassPath(args)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -33,26 +54,281 @@ func main() {
 	log.Infow("executing main", "mainClass", mainClassName, "classPath", classPath)
 
 	ctx := logger.OnContext(context.Background(), log)
-	runner := jvm.NewRunner(classPath)
+	runner := jvm.NewRunner(classPath, options.vm...)
+	dumpThreadsOnQuit(ctx, runner)
 
 	err = runner.RunMain(ctx, mainClassName)
-	if err != nil {
+	if options.traceFile != nil {
+		closeErr := options.traceFile.Close()
+		if closeErr != nil {
+			log.Errorw("could not close trace file", "error", closeErr)
+		}
+	}
+
+	if err != nil && !errors.Is(err, jvm.ErrDebuggerQuit) {
 		log.Fatalln(err)
 	}
 }
 
-func getMainClassName() (string, error) {
-	if len(os.Args) < 2 {
+// dumpThreadsOnQuit prints a thread dump to stdout whenever the process receives SIGQUIT, like HotSpot does.
+func dumpThreadsOnQuit(ctx context.Context, runner *jvm.Runner) {
+	log := logger.FromContext(ctx)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGQUIT)
+
+	go func() {
+		for range quit {
+			err := runner.DumpThreads(ctx, os.Stdout)
+			if err != nil {
+				log.Errorw("could not dump threads", "error", err)
+			}
+		}
+	}()
+}
+
+// launcherOptions are the options given on the command line.
+type launcherOptions struct {
+	vm []jvm.Option
+	// logFile is where the log is written to as JSON lines, if not empty
+	logFile string
+	// traceFile is the file -Xtrace:file=<path> writes to, closed once the program has finished
+	traceFile io.Closer
+}
+
+// parseOptions consumes the leading VM options and returns the remaining arguments.
+// The log file defaults to $SWELL_LOG_FILE and can be set with -Xlog:file=<path>.
+// -agentlib:jdwp lets a debugger like jdb attach.
+// -Xtrace traces the execution to stderr, -Xtrace:json writes JSON lines instead, -Xtrace:file=<path>
+// writes to a file, -Xtrace:filter=<pattern>,... limits it to matching methods and classes and
+// -Xtrace:instructions includes every instruction executed.
+func parseOptions(args []string) (launcherOptions, []string, error) {
+	options := make([]jvm.Option, 0)
+	logFile := os.Getenv("SWELL_LOG_FILE")
+	heapDumpPath := fmt.Sprintf("java_pid%d.hprof", os.Getpid())
+	heapDumpOnOutOfMemory := false
+	heapDumpOnExit := false
+	trace := traceOptions{}
+
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		arg := args[0]
+		args = args[1:]
+
+		switch {
+		case strings.HasPrefix(arg, "-Xmx"):
+			size, err := parseSize(strings.TrimPrefix(arg, "-Xmx"))
+			if err != nil {
+				return launcherOptions{}, nil, fmt.Errorf("invalid maximum heap size %s: %v", arg, err)
+			}
+
+			options = append(options, jvm.WithMaxHeapSize(size))
+		case strings.HasPrefix(arg, "-Xlog:file="):
+			logFile = strings.TrimPrefix(arg, "-Xlog:file=")
+		case arg == "-Xlog:gc":
+			options = append(options, jvm.WithGCLog(os.Stderr))
+		case arg == "-XX:+CompactStrings":
+			options = append(options, jvm.WithCompactStrings(true))
+		case arg == "-XX:-CompactStrings":
+			options = append(options, jvm.WithCompactStrings(false))
+		case arg == "-XX:+HeapDumpOnOutOfMemoryError":
+			heapDumpOnOutOfMemory = true
+		case arg == "-XX:+HeapDumpOnExit":
+			heapDumpOnExit = true
+		case strings.HasPrefix(arg, "-XX:HeapDumpPath="):
+			heapDumpPath = strings.TrimPrefix(arg, "-XX:HeapDumpPath=")
+		case strings.HasPrefix(arg, "-agentlib:jdwp="):
+			option, err := parseJDWP(strings.TrimPrefix(arg, "-agentlib:jdwp="))
+			if err != nil {
+				return launcherOptions{}, nil, fmt.Errorf("invalid option %s: %v", arg, err)
+			}
+
+			options = append(options, option)
+		case arg == "-Xtrace" || strings.HasPrefix(arg, "-Xtrace:"):
+			err := trace.parse(strings.TrimPrefix(strings.TrimPrefix(arg, "-Xtrace"), ":"))
+			if err != nil {
+				return launcherOptions{}, nil, fmt.Errorf("invalid option %s: %v", arg, err)
+			}
+		default:
+			return launcherOptions{}, nil, fmt.Errorf("unknown option %s", arg)
+		}
+	}
+
+	if heapDumpOnOutOfMemory {
+		options = append(options, jvm.WithHeapDumpOnOutOfMemory(heapDumpPath))
+	}
+
+	if heapDumpOnExit {
+		options = append(options, jvm.WithHeapDumpOnExit(heapDumpPath))
+	}
+
+	var traceFile io.Closer
+	if trace.enabled {
+		option, file, err := trace.option()
+		if err != nil {
+			return launcherOptions{}, nil, err
+		}
+
+		options = append(options, option)
+		traceFile = file
+	}
+
+	return launcherOptions{vm: options, logFile: logFile, traceFile: traceFile}, args, nil
+}
+
+// traceOptions are collected from all -Xtrace options.
+type traceOptions struct {
+	enabled      bool
+	json         bool
+	file         string
+	patterns     []string
+	instructions bool
+}
+
+// parse applies the part of a -Xtrace option after the colon, empty for a plain -Xtrace.
+func (t *traceOptions) parse(spec string) error {
+	t.enabled = true
+
+	key, value, _ := strings.Cut(spec, "=")
+	switch key {
+	case "", "text":
+		t.json = false
+	case "json":
+		t.json = true
+	case "file":
+		t.file = value
+	case "filter":
+		for _, pattern := range strings.Split(value, ",") {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern %s", pattern)
+			}
+
+			t.patterns = append(t.patterns, pattern)
+		}
+	case "instructions":
+		t.instructions = true
+	default:
+		return fmt.Errorf("unknown option %s", key)
+	}
+
+	return nil
+}
+
+// option creates the tracer, along with the trace file to close once the program has finished,
+// nil when tracing to stderr.
+func (t *traceOptions) option() (jvm.Option, io.Closer, error) {
+	var out io.Writer = os.Stderr
+	var file io.Closer
+	if t.file != "" {
+		f, err := os.Create(t.file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not create trace file: %v", err)
+		}
+
+		out = f
+		file = f
+	}
+
+	tracer := jvm.NewTextTracer(out)
+	if t.json {
+		tracer = jvm.NewJSONTracer(out)
+	}
+
+	return jvm.WithTracer(&jvm.TraceFilter{Tracer: tracer, Patterns: t.patterns, Instructions: t.instructions}), file, nil
+}
+
+// parseJDWP starts listening for a debugger as configured by the options of -agentlib:jdwp, e.g.
+// transport=dt_socket,server=y,suspend=n,address=*:5005. Only server mode over sockets is supported.
+func parseJDWP(spec string) (jvm.Option, error) {
+	suspend := true
+	address := ""
+
+	for _, option := range strings.Split(spec, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "transport":
+			if value != "dt_socket" {
+				return nil, fmt.Errorf("unsupported transport %s", value)
+			}
+		case "server":
+			if value != "y" {
+				return nil, errors.New("only server=y is supported")
+			}
+		case "suspend":
+			suspend = value != "n"
+		case "address":
+			address = value
+		default:
+			return nil, fmt.Errorf("unknown option %s", key)
+		}
+	}
+
+	if address == "" {
+		return nil, errors.New("no address provided")
+	}
+
+	host, port, found := strings.Cut(address, ":")
+	if !found {
+		// like the JDK, a bare port only listens on the loopback interface
+		host, port = "localhost", address
+	} else if host == "*" {
+		host = ""
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+
+	_, listenPort, _ := net.SplitHostPort(listener.Addr().String())
+	fmt.Fprintf(os.Stderr, "Listening for transport dt_socket at address: %s\n", listenPort)
+
+	return jvm.WithJDWP(listener, suspend), nil
+}
+
+// parseSize parses sizes like 512k, 64m or 1g into bytes.
+func parseSize(s string) (int, error) {
+	if s == "" {
+		return 0, errors.New("size is empty")
+	}
+
+	multiplier := 1
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		multiplier = 1 << 10
+	case 'm', 'M':
+		multiplier = 1 << 20
+	case 'g', 'G':
+		multiplier = 1 << 30
+	}
+
+	if multiplier != 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if n <= 0 {
+		return 0, errors.New("size has to be positive")
+	}
+
+	return n * multiplier, nil
+}
+
+func getMainClassName(args []string) (string, error) {
+	if len(args) < 1 {
 		return "", errors.New("no main class provided")
 	}
 
-	return os.Args[1], nil
+	return args[0], nil
 }
 
-func getClassPath() ([]string, error) {
-	if len(os.Args) < 3 {
+func getClassPath(args []string) ([]string, error) {
+	if len(args) < 2 {
 		return nil, errors.New("no class path provided")
 	}
 
-	return strings.Split(os.Args[2], ":"), nil
+	return strings.Split(args[1], ":"), nil
 }
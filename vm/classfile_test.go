@@ -0,0 +1,113 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// testClassFile encodes class files, so the tests can define classes without a JDK.
+type testClassFile struct {
+	name       string
+	super      string
+	pool       [][]byte
+	utf8s      map[string]uint16
+	fields     [][]byte
+	methods    [][]byte
+	accessFlag uint16
+}
+
+const (
+	accPublic = 0x0001
+	accStatic = 0x0008
+	accNative = 0x0100
+)
+
+func newClassFile(name string, super string) *testClassFile {
+	return &testClassFile{name: name, super: super, utf8s: make(map[string]uint16), accessFlag: accPublic}
+}
+
+func (f *testClassFile) add(info ...byte) uint16 {
+	f.pool = append(f.pool, info)
+	return uint16(len(f.pool))
+}
+
+func (f *testClassFile) utf8(s string) uint16 {
+	if index, ok := f.utf8s[s]; ok {
+		return index
+	}
+
+	index := f.add(append(append([]byte{1}, u2(uint16(len(s)))...), s...)...)
+	f.utf8s[s] = index
+	return index
+}
+
+func (f *testClassFile) classRef(name string) uint16 {
+	return f.add(append([]byte{7}, u2(f.utf8(name))...)...)
+}
+
+// ref adds a Methodref.
+func (f *testClassFile) ref(className string, name string, descriptor string) uint16 {
+	classIndex := f.classRef(className)
+	nameAndType := f.add(append(append([]byte{12}, u2(f.utf8(name))...), u2(f.utf8(descriptor))...)...)
+	return f.add(append(append([]byte{10}, u2(classIndex)...), u2(nameAndType)...)...)
+}
+
+func (f *testClassFile) field(accessFlags uint16, name string, descriptor string) *testClassFile {
+	f.fields = append(f.fields, concat(u2(accessFlags), u2(f.utf8(name)), u2(f.utf8(descriptor)), u2(0)))
+	return f
+}
+
+func (f *testClassFile) method(accessFlags uint16, name string, descriptor string, code ...byte) *testClassFile {
+	method := concat(u2(accessFlags), u2(f.utf8(name)), u2(f.utf8(descriptor)))
+	if accessFlags&accNative != 0 {
+		f.methods = append(f.methods, concat(method, u2(0)))
+		return f
+	}
+
+	// max_stack, max_locals, code, no exception table and no attributes
+	attribute := concat(u2(16), u2(16), u4(uint32(len(code))), code, u2(0), u2(0))
+	f.methods = append(f.methods, concat(method, u2(1), u2(f.utf8("Code")), u4(uint32(len(attribute))), attribute))
+	return f
+}
+
+func (f *testClassFile) bytes() []byte {
+	this := f.classRef(f.name)
+	var super uint16
+	if f.super != "" {
+		super = f.classRef(f.super)
+	}
+
+	var b bytes.Buffer
+	b.Write([]byte{0xca, 0xfe, 0xba, 0xbe, 0, 0, 0, 65})
+	b.Write(u2(uint16(len(f.pool) + 1)))
+	for _, info := range f.pool {
+		b.Write(info)
+	}
+
+	b.Write(concat(u2(f.accessFlag), u2(this), u2(super), u2(0)))
+
+	b.Write(u2(uint16(len(f.fields))))
+	for _, field := range f.fields {
+		b.Write(field)
+	}
+
+	b.Write(u2(uint16(len(f.methods))))
+	for _, method := range f.methods {
+		b.Write(method)
+	}
+
+	b.Write(u2(0))
+	return b.Bytes()
+}
+
+func u2(n uint16) []byte {
+	return binary.BigEndian.AppendUint16(nil, n)
+}
+
+func u4(n uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, n)
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
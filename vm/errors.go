@@ -0,0 +1,79 @@
+package vm
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/m4tthewde/swell/internal/jvm"
+)
+
+// BudgetExceededError is returned once one of the Limits of the VM has been reached.
+type BudgetExceededError = jvm.BudgetExceededError
+
+// Budget is the kind of limit a BudgetExceededError reports.
+type Budget = jvm.Budget
+
+const (
+	BudgetInstructions = jvm.BudgetInstructions
+	BudgetWallTime     = jvm.BudgetWallTime
+	BudgetHeap         = jvm.BudgetHeap
+	BudgetStackDepth   = jvm.BudgetStackDepth
+)
+
+// JavaError is a Java exception that was not caught.
+type JavaError struct {
+	// ClassName is the binary name of the exception class, e.g. java.lang.IllegalStateException
+	ClassName string
+	Message   string
+	// StackTrace lists the methods the exception propagated through, innermost first
+	StackTrace []string
+	Cause      *JavaError
+	err        error
+}
+
+func (e *JavaError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the cause, if any.
+func (e *JavaError) Unwrap() error {
+	if e.Cause == nil {
+		return nil
+	}
+
+	return e.Cause
+}
+
+// javaError turns an uncaught exception into a *JavaError, other errors are returned as they are.
+func javaError(err error) error {
+	var throwable *jvm.ThrowableError
+	if !errors.As(err, &throwable) {
+		return err
+	}
+
+	return newJavaError(throwable)
+}
+
+func newJavaError(throwable *jvm.ThrowableError) *JavaError {
+	e := &JavaError{
+		ClassName:  dotted(throwable.ClassName),
+		Message:    throwable.Message,
+		StackTrace: throwable.StackTrace(),
+		err:        throwable,
+	}
+
+	if throwable.Cause != nil {
+		e.Cause = newJavaError(throwable.Cause)
+	}
+
+	return e
+}
+
+func dotted(className string) string {
+	return strings.ReplaceAll(className, "/", ".")
+}
+
+// internalName returns the name of a class with slashes, as used in class files.
+func internalName(className string) string {
+	return strings.ReplaceAll(className, ".", "/")
+}
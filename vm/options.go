@@ -0,0 +1,125 @@
+package vm
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/m4tthewde/swell/internal/jvm"
+	"github.com/m4tthewde/swell/internal/logger"
+	"go.uber.org/zap"
+)
+
+type config struct {
+	classPath     []string
+	classFiles    []classFile
+	runnerOptions []jvm.Option
+}
+
+type classFile struct {
+	className string
+	data      []byte
+}
+
+// Option configures a VM.
+type Option func(*config)
+
+// WithClassPath adds directories to search for class files, after the JDK modules in JAVA_HOME.
+func WithClassPath(dirs ...string) Option {
+	return func(c *config) {
+		c.classPath = append(c.classPath, dirs...)
+	}
+}
+
+// WithClassFile defines a class from the contents of its class file, e.g. one embedded with go:embed.
+func WithClassFile(className string, data []byte) Option {
+	return func(c *config) {
+		c.classFiles = append(c.classFiles, classFile{className: internalName(className), data: data})
+	}
+}
+
+// WithStdout sets the writer System.out writes to.
+func WithStdout(w io.Writer) Option {
+	return func(c *config) {
+		c.runnerOptions = append(c.runnerOptions, jvm.WithStdout(w))
+	}
+}
+
+// WithStderr sets the writer System.err writes to.
+func WithStderr(w io.Writer) Option {
+	return func(c *config) {
+		c.runnerOptions = append(c.runnerOptions, jvm.WithStderr(w))
+	}
+}
+
+// WithStdin sets the reader System.in reads from.
+func WithStdin(r io.Reader) Option {
+	return func(c *config) {
+		c.runnerOptions = append(c.runnerOptions, jvm.WithStdin(r))
+	}
+}
+
+// Native implements a native method with Go values, converted like the arguments and result of InvokeStatic.
+// The receiver of an instance method is not passed. A returned error ends the call into the VM with that error.
+type Native func(ctx context.Context, args []any) (any, error)
+
+// WithNative implements the native method className.name with descriptor in Go.
+func WithNative(className string, name string, descriptor string, native Native) Option {
+	return func(c *config) {
+		c.runnerOptions = append(c.runnerOptions, jvm.WithGoNative(internalName(className), name, descriptor, jvm.GoNative(native)))
+	}
+}
+
+// WithLogger logs the work of the interpreter, class loading and garbage collection to log.
+func WithLogger(log *zap.SugaredLogger) Option {
+	return func(c *config) {
+		c.runnerOptions = append(c.runnerOptions, jvm.WithLogger(log))
+	}
+}
+
+// WithSlogLogger logs like WithLogger, but to a slog.Logger.
+func WithSlogLogger(log *slog.Logger) Option {
+	return WithLogger(logger.FromSlog(log))
+}
+
+// Limits bound the resources a VM may use, zero values mean unlimited.
+// Once a limit is reached all threads are stopped with a *BudgetExceededError, which the program can not catch.
+type Limits struct {
+	// Instructions is the number of bytecode instructions executed by all threads during one call
+	Instructions int64
+	// WallTime is the time one call may take
+	WallTime time.Duration
+	// HeapSize is the number of bytes the heap may occupy
+	HeapSize int
+	// StackDepth is the number of nested method calls of a thread
+	StackDepth int
+}
+
+// WithLimits sets the limits of the VM, see Limits.
+func WithLimits(limits Limits) Option {
+	return func(c *config) {
+		if limits.Instructions > 0 {
+			c.runnerOptions = append(c.runnerOptions, jvm.WithInstructionBudget(limits.Instructions))
+		}
+
+		if limits.WallTime > 0 {
+			c.runnerOptions = append(c.runnerOptions, jvm.WithTimeBudget(limits.WallTime))
+		}
+
+		if limits.HeapSize > 0 {
+			c.runnerOptions = append(c.runnerOptions, jvm.WithHeapBudget(limits.HeapSize))
+		}
+
+		if limits.StackDepth > 0 {
+			c.runnerOptions = append(c.runnerOptions, jvm.WithStackDepthBudget(limits.StackDepth))
+		}
+	}
+}
+
+// WithMaxHeapSize limits the heap like -Xmx, allocations that do not fit throw an OutOfMemoryError.
+func WithMaxHeapSize(size int) Option {
+	return func(c *config) {
+		c.runnerOptions = append(c.runnerOptions, jvm.WithMaxHeapSize(size))
+	}
+}
@@ -0,0 +1,83 @@
+// Package vm embeds the swell Java Virtual Machine into Go programs.
+//
+// A VM loads classes from its class path and from class files passed with WithClassFile,
+// runs main methods and calls static methods with Go values as arguments:
+//
+//	v := vm.New(vm.WithClassPath("plugins"))
+//	sum, err := v.InvokeStatic(ctx, "Plugin", "sum", "([I)I", []int32{1, 2, 3})
+package vm
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/m4tthewde/swell/internal/jvm"
+)
+
+// VM is a Java Virtual Machine. Its methods may be called from several goroutines,
+// the calls are executed one after another.
+type VM struct {
+	lock   sync.Mutex
+	runner *jvm.Runner
+	// err is the first error of an option, returned by every call
+	err error
+}
+
+// New creates a VM, by default without a class path, with the standard streams of the process and without logging.
+// A logger on the contexts passed to the VM is used unless WithLogger or WithSlogLogger is given.
+func New(options ...Option) *VM {
+	c := config{}
+	for _, option := range options {
+		option(&c)
+	}
+
+	v := &VM{runner: jvm.NewRunner(c.classPath, c.runnerOptions...)}
+
+	for _, file := range c.classFiles {
+		err := v.runner.DefineClass(context.Background(), file.className, file.data)
+		if err != nil {
+			v.err = errors.Join(v.err, err)
+		}
+	}
+
+	return v
+}
+
+// RunMain runs public static void main(String[]) of className, with dots or slashes as separators,
+// and waits for the non-daemon threads it starts. An uncaught exception is returned as a *JavaError.
+func (v *VM) RunMain(ctx context.Context, className string) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.err != nil {
+		return v.err
+	}
+
+	return javaError(v.runner.RunMain(ctx, internalName(className)))
+}
+
+// InvokeStatic calls the static method name with the given descriptor, e.g. (ILjava/lang/String;)[I, and returns its result.
+//
+// Arguments are converted to the parameter types: Go integers to the integral types if they fit, floats and integers
+// to float and double, bool to boolean, strings to String or Object, slices to arrays of any type and nil to null.
+// The result is converted back: int32, int64, int16, int8, uint16 (char), bool, float32 and float64 for
+// primitives, string for strings, typed slices like []int32 for primitive arrays, []any for other arrays and
+// nil for null and void methods. Other objects can not be returned.
+//
+// An uncaught exception is returned as a *JavaError, a used up limit as a *BudgetExceededError.
+func (v *VM) InvokeStatic(ctx context.Context, className string, name string, descriptor string, args ...any) (any, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.err != nil {
+		return nil, v.err
+	}
+
+	result, err := v.runner.InvokeStatic(ctx, internalName(className), name, descriptor, args...)
+	if err != nil {
+		return nil, javaError(err)
+	}
+
+	return result, nil
+}
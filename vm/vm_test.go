@@ -0,0 +1,174 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	iconst1     = 0x04
+	iload0      = 0x1a
+	iload1      = 0x1b
+	aload0      = 0x2a
+	istore2     = 0x3d
+	isub        = 0x64
+	ireturn     = 0xac
+	areturn     = 0xb0
+	vreturn     = 0xb1
+	gotoOp      = 0xa7
+	newOp       = 0xbb
+	dup         = 0x59
+	newArray    = 0xbc
+	arrayLength = 0xbe
+	athrow      = 0xbf
+	invokeSpec  = 0xb7
+	invokeStat  = 0xb8
+	intArray    = 10
+)
+
+// runtimeClasses are the few classes of java.lang the plugin needs.
+func runtimeClasses() []Option {
+	object := newClassFile("java/lang/Object", "").
+		method(accPublic, "<init>", "()V", vreturn)
+	str := newClassFile("java/lang/String", "java/lang/Object").
+		field(0, "value", "[B").
+		field(0, "coder", "B")
+	throwable := newClassFile("java/lang/Throwable", "java/lang/Object").
+		field(0, "detailMessage", "Ljava/lang/String;").
+		field(0, "cause", "Ljava/lang/Throwable;").
+		method(accPublic, "<init>", "()V", vreturn)
+	exception := newClassFile("java/lang/Exception", "java/lang/Throwable").
+		method(accPublic, "<init>", "()V", vreturn)
+	runtimeException := newClassFile("java/lang/RuntimeException", "java/lang/Exception").
+		method(accPublic, "<init>", "()V", vreturn)
+
+	options := []Option{}
+	for _, c := range []*testClassFile{object, str, throwable, exception, runtimeException} {
+		options = append(options, WithClassFile(c.name, c.bytes()))
+	}
+
+	return options
+}
+
+func pluginClass() []byte {
+	plugin := newClassFile("Plugin", "java/lang/Object")
+
+	exception := plugin.classRef("java/lang/RuntimeException")
+	exceptionInit := plugin.ref("java/lang/RuntimeException", "<init>", "()V")
+	callback := plugin.ref("Plugin", "callback", "(I)I")
+
+	plugin.
+		method(accPublic|accStatic, "sub", "(II)I", iload0, iload1, isub, ireturn).
+		method(accPublic|accStatic, "echo", "(Ljava/lang/String;)Ljava/lang/String;", aload0, areturn).
+		method(accPublic|accStatic, "length", "([I)I", aload0, arrayLength, ireturn).
+		method(accPublic|accStatic, "fill", "(I)[I", iload0, newArray, intArray, areturn).
+		method(accPublic|accStatic, "fail", "()V", concat([]byte{newOp}, u2(exception), []byte{dup, invokeSpec}, u2(exceptionInit), []byte{athrow})...).
+		method(accStatic|accNative, "callback", "(I)I").
+		method(accPublic|accStatic, "callBack", "(I)I", concat([]byte{iload0, invokeStat}, u2(callback), []byte{ireturn})...).
+		method(accPublic|accStatic, "spin", "()V", gotoOp, 0, 0).
+		method(accPublic|accStatic, "main", "([Ljava/lang/String;)V", concat([]byte{iconst1, invokeStat}, u2(callback), []byte{istore2, vreturn})...)
+
+	return plugin.bytes()
+}
+
+func newTestVM(options ...Option) *VM {
+	options = append(runtimeClasses(), options...)
+	return New(append(options, WithClassFile("Plugin", pluginClass()))...)
+}
+
+func TestInvokeStatic(t *testing.T) {
+	v := newTestVM()
+	ctx := context.Background()
+
+	result, err := v.InvokeStatic(ctx, "Plugin", "sub", "(II)I", 5, int32(7))
+	assert.Nil(t, err)
+	assert.Equal(t, int32(-2), result)
+
+	result, err = v.InvokeStatic(ctx, "Plugin", "echo", "(Ljava/lang/String;)Ljava/lang/String;", "grüße")
+	assert.Nil(t, err)
+	assert.Equal(t, "grüße", result)
+
+	result, err = v.InvokeStatic(ctx, "Plugin", "echo", "(Ljava/lang/String;)Ljava/lang/String;", nil)
+	assert.Nil(t, err)
+	assert.Nil(t, result)
+
+	result, err = v.InvokeStatic(ctx, "Plugin", "length", "([I)I", []int{1, 2, 3})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), result)
+
+	result, err = v.InvokeStatic(ctx, "Plugin", "fill", "(I)[I", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []int32{0, 0}, result)
+}
+
+func TestInvokeStaticConversionErrors(t *testing.T) {
+	v := newTestVM()
+	ctx := context.Background()
+
+	_, err := v.InvokeStatic(ctx, "Plugin", "sub", "(II)I", 1)
+	assert.EqualError(t, err, "Plugin.sub(II)I takes 2 arguments, got 1")
+
+	_, err = v.InvokeStatic(ctx, "Plugin", "sub", "(II)I", int64(1)<<40, 1)
+	assert.EqualError(t, err, "argument 0: 1099511627776 does not fit into an int")
+
+	_, err = v.InvokeStatic(ctx, "Plugin", "sub", "(II)I", "1", 1)
+	assert.EqualError(t, err, "argument 0: can not convert string to int")
+}
+
+func TestJavaError(t *testing.T) {
+	v := newTestVM()
+
+	_, err := v.InvokeStatic(context.Background(), "Plugin", "fail", "()V")
+
+	var javaErr *JavaError
+	assert.True(t, errors.As(err, &javaErr), "%v", err)
+	assert.Equal(t, "java.lang.RuntimeException", javaErr.ClassName)
+	assert.Equal(t, []string{"Plugin.fail()"}, javaErr.StackTrace)
+	assert.Nil(t, javaErr.Cause)
+}
+
+func TestNative(t *testing.T) {
+	var called []any
+	v := newTestVM(WithNative("Plugin", "callback", "(I)I", func(ctx context.Context, args []any) (any, error) {
+		called = append(called, args...)
+		return args[0].(int32) * 2, nil
+	}))
+
+	result, err := v.InvokeStatic(context.Background(), "Plugin", "callBack", "(I)I", 21)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(42), result)
+
+	err = v.RunMain(context.Background(), "Plugin")
+	assert.Nil(t, err)
+	assert.Equal(t, []any{int32(21), int32(1)}, called)
+}
+
+func TestLimits(t *testing.T) {
+	v := newTestVM(WithLimits(Limits{Instructions: 1000}))
+
+	_, err := v.InvokeStatic(context.Background(), "Plugin", "spin", "()V")
+	var budgetErr *BudgetExceededError
+	assert.True(t, errors.As(err, &budgetErr), "%v", err)
+	assert.Equal(t, BudgetInstructions, budgetErr.Budget)
+
+	v = newTestVM(WithLimits(Limits{WallTime: 20 * time.Millisecond}))
+
+	_, err = v.InvokeStatic(context.Background(), "Plugin", "spin", "()V")
+	assert.True(t, errors.As(err, &budgetErr), "%v", err)
+	assert.Equal(t, BudgetWallTime, budgetErr.Budget)
+}
+
+func TestLimitsPerCall(t *testing.T) {
+	// each call runs 4 instructions, together they exceed the budget
+	v := newTestVM(WithLimits(Limits{Instructions: 10}))
+
+	for range 5 {
+		result, err := v.InvokeStatic(context.Background(), "Plugin", "sub", "(II)I", 3, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), result)
+	}
+}